@@ -0,0 +1,186 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// ackVectorBuffer is the acknowledgement buffer of the HC-Receiver, described in Appendix A.
+// It remembers the state of every packet received from the other side, starting with the
+// oldest packet whose acknowledgement the other side has not yet acknowledged, and
+// produces the Ack Vector options sent on outgoing acknowledgements.
+//
+// The state of the newest received packet is always retained, so that any acknowledgement
+// of it can carry an Ack Vector.
+type ackVectorBuffer struct {
+	init   bool              // Whether any packet has been recorded
+	tail   int64             // Sequence number of the oldest packet in states
+	states []byte            // states[i] is the Ack Vector state of packet tail+i
+	nonces []byte            // nonces[i] is the ECN Nonce received on packet tail+i
+	acks   []ackVectorRecord // Acks carrying Ack Vectors, in increasing order of SeqNo
+}
+
+// ackVectorRecord remembers an outgoing acknowledgement that carried an Ack Vector
+type ackVectorRecord struct {
+	SeqNo int64 // Sequence number of the packet that carried the Ack Vector
+	AckNo int64 // Newest packet whose state has been reported by that Ack Vector
+	Tail  int64 // Oldest packet whose state has been reported by that Ack Vector
+}
+
+const (
+	// ackVectorBufferMaxLen is the maximum number of packet states remembered. It equals the
+	// maximum number of packets that can be described by a single Ack Vector option.
+	ackVectorBufferMaxLen = AckVectorMaxLen * AckVectorMaxRunLen
+
+	// ackVectorMaxRecords is the maximum number of unacknowledged acks remembered
+	ackVectorMaxRecords = 256
+)
+
+// Init resets the buffer for new use
+func (b *ackVectorBuffer) Init() {
+	b.init = false
+	b.tail = 0
+	b.states = nil
+	b.nonces = nil
+	b.acks = nil
+}
+
+// head returns the sequence number of the newest packet in the buffer
+func (b *ackVectorBuffer) head() int64 {
+	return SeqAdd(b.tail, int64(len(b.states))-1)
+}
+
+// OnRead records the arrival of the packet with sequence number seqNo, whose Ack Vector
+// state is state and which carried the ECN Nonce nonce. Multiple receptions of the same packet
+// are combined as in Section 11.4.1.
+func (b *ackVectorBuffer) OnRead(seqNo int64, state, nonce byte) {
+	// A packet too far ahead to be described along with the buffered ones starts a new buffer
+	if !b.init || SeqDist(b.head(), seqNo) > ackVectorBufferMaxLen {
+		b.init = true
+		b.tail = seqNo
+		b.states = append(b.states[:0], state)
+		b.nonces = append(b.nonces[:0], nonce)
+		return
+	}
+	if SeqLess(seqNo, b.tail) {
+		// The packet is older than the Acknowledgement Window. Its acknowledgement
+		// is known to have reached the other side, or it has been forgotten.
+		return
+	}
+	if SeqLessEq(seqNo, b.head()) {
+		i := SeqDist(b.tail, seqNo)
+		if b.states[i] == AckVectorNotReceived || state == AckVectorECNMarked {
+			b.states[i] = state
+			b.nonces[i] = nonce
+		}
+		// An ack that reported this packet as lost must not allow the tail to move past
+		// it, before the other side has seen a newer ack that reports its arrival.
+		if state != AckVectorNotReceived {
+			for j := range b.acks {
+				if SeqLessEq(seqNo, b.acks[j].AckNo) {
+					b.acks[j].AckNo = SeqAdd(seqNo, -1)
+				}
+			}
+		}
+		return
+	}
+	for SeqDist(b.head(), seqNo) > 1 {
+		b.states = append(b.states, AckVectorNotReceived)
+		b.nonces = append(b.nonces, 0)
+	}
+	b.states = append(b.states, state)
+	b.nonces = append(b.nonces, nonce)
+	if k := len(b.states) - ackVectorBufferMaxLen; k > 0 {
+		b.states = append(b.states[:0], b.states[k:]...)
+		b.nonces = append(b.nonces[:0], b.nonces[k:]...)
+		b.tail = SeqAdd(b.tail, int64(k))
+	}
+}
+
+// makeOption returns an Ack Vector option describing all packets in the buffer, starting
+// from the packet with sequence number ackNo. Packets newer than the newest packet in the
+// buffer are reported as not received. If ackNo precedes the buffer, makeOption returns nil.
+// The Nonce Echo of the option is the one-bit sum of the ECN Nonces of all packets that it
+// reports as received, Section 12.2.
+func (b *ackVectorBuffer) makeOption(ackNo int64) *AckVectorOption {
+	if !b.init || SeqLess(ackNo, b.tail) {
+		return nil
+	}
+	opt := &AckVectorOption{}
+	n := 0 // Number of vector bytes needed to encode opt
+	head := b.head()
+	for seqNo := ackNo; SeqLessEq(b.tail, seqNo); seqNo = SeqAdd(seqNo, -1) {
+		state, nonce := byte(AckVectorNotReceived), byte(0)
+		if SeqLessEq(seqNo, head) {
+			i := SeqDist(b.tail, seqNo)
+			state, nonce = b.states[i], b.nonces[i]
+		}
+		k := len(opt.Runs)
+		if k > 0 && opt.Runs[k-1].State == state {
+			if opt.Runs[k-1].Length%AckVectorMaxRunLen == 0 {
+				if n == AckVectorMaxLen {
+					break
+				}
+				n++
+			}
+			opt.Runs[k-1].Length++
+		} else {
+			if n == AckVectorMaxLen {
+				break
+			}
+			n++
+			opt.Runs = append(opt.Runs, AckVectorRun{State: state, Length: 1})
+		}
+		if state == AckVectorReceived {
+			opt.Nonce ^= nonce
+		}
+	}
+	return opt
+}
+
+// OnWrite records that the outgoing packet with sequence number seqNo carries the Ack Vector
+// opt, which was produced by makeOption(ackNo)
+func (b *ackVectorBuffer) OnWrite(seqNo, ackNo int64, opt *AckVectorOption) {
+	if len(b.acks) >= ackVectorMaxRecords {
+		b.acks = append(b.acks[:0], b.acks[1:]...)
+	}
+	tail := SeqAdd(ackNo, 1-opt.Len())
+	if SeqLess(b.head(), ackNo) {
+		ackNo = b.head()
+	}
+	b.acks = append(b.acks, ackVectorRecord{SeqNo: seqNo, AckNo: ackNo, Tail: tail})
+}
+
+// OnAck is called when the other side acknowledges our packet with sequence number ackNo.
+// Acknowledgements are not cumulative, Section 11.4, so only an acknowledgement of a packet
+// that carried an Ack Vector shows that the other side has received the states reported by it.
+// OnAck then forgets these states, along with the record of that packet and all older ones,
+// Section A.3.
+func (b *ackVectorBuffer) OnAck(ackNo int64) {
+	k := 0
+	for k < len(b.acks) && SeqLess(b.acks[k].SeqNo, ackNo) {
+		k++
+	}
+	if k == len(b.acks) || b.acks[k].SeqNo != ackNo {
+		return
+	}
+	r := b.acks[k]
+	b.acks = append(b.acks[:0], b.acks[k+1:]...)
+
+	// The states older than the reported ones must be kept, since the Ack Vector was truncated
+	// before them
+	if SeqLess(b.tail, r.Tail) {
+		return
+	}
+	// Retain the newest packet's state
+	newTail := SeqAdd(r.AckNo, 1)
+	if SeqLess(b.head(), newTail) {
+		newTail = b.head()
+	}
+	if SeqLessEq(newTail, b.tail) {
+		return
+	}
+	d := SeqDist(b.tail, newTail)
+	b.states = append(b.states[:0], b.states[d:]...)
+	b.nonces = append(b.nonces[:0], b.nonces[d:]...)
+	b.tail = newTail
+}
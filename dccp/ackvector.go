@@ -0,0 +1,131 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// AckVectorOption, Section 11.4
+// The Ack Vector gives a run-length encoded history of the packets received by the
+// endpoint sending it. The first run refers to the packet indicated in the Acknowledgement
+// Number of the carrying packet; subsequent runs refer to older packets.
+type AckVectorOption struct {
+	// Nonce is the ECN Nonce Echo, 0 or 1. It determines the option type, 38 or 39.
+	Nonce byte
+	// Runs lists the runs of packet states, from the newest packet to the oldest.
+	Runs []AckVectorRun
+}
+
+// AckVectorRun describes Length consecutive packets in the same State
+type AckVectorRun struct {
+	State  byte
+	Length int64 // Number of packets in the run, at least 1
+}
+
+// Ack Vector states, Section 11.4, Table 6
+const (
+	AckVectorReceived    = 0
+	AckVectorECNMarked   = 1
+	AckVectorNotReceived = 3
+)
+
+const (
+	// AckVectorMaxLen is the maximum length of the vector of a single Ack Vector option, in bytes
+	AckVectorMaxLen = 255 - 2
+
+	// AckVectorMaxRunLen is the maximum number of packets described by a single vector byte
+	AckVectorMaxRunLen = 64
+)
+
+func (opt *AckVectorOption) Encode() (*Option, error) {
+	if opt.Nonce > 1 {
+		return nil, ErrOption
+	}
+	d := make([]byte, 0, 8)
+	for _, run := range opt.Runs {
+		if run.Length < 1 || !isAckVectorStateValid(run.State) {
+			return nil, ErrOption
+		}
+		for l := run.Length; l > 0; l -= AckVectorMaxRunLen {
+			d = append(d, (run.State<<6)|byte(min64(l, AckVectorMaxRunLen)-1))
+		}
+	}
+	if len(d) == 0 {
+		return nil, ErrOption
+	}
+	if len(d) > AckVectorMaxLen {
+		return nil, ErrOversize
+	}
+	return &Option{
+		Type:      OptionAckVectorNonce0 + opt.Nonce,
+		Data:      d,
+		Mandatory: false,
+	}, nil
+}
+
+func isAckVectorStateValid(state byte) bool {
+	return state == AckVectorReceived || state == AckVectorECNMarked || state == AckVectorNotReceived
+}
+
+// DecodeAckVectorOption decodes an Ack Vector option. Adjacent vector bytes with the same
+// state are merged into a single run.
+func DecodeAckVectorOption(opt *Option) *AckVectorOption {
+	if (opt.Type != OptionAckVectorNonce0 && opt.Type != OptionAckVectorNonce1) || len(opt.Data) == 0 {
+		return nil
+	}
+	r := &AckVectorOption{
+		Nonce: opt.Type - OptionAckVectorNonce0,
+		Runs:  make([]AckVectorRun, 0, len(opt.Data)),
+	}
+	for _, b := range opt.Data {
+		state, length := b>>6, int64(b&0x3f)+1
+		if !isAckVectorStateValid(state) {
+			return nil
+		}
+		if k := len(r.Runs); k > 0 && r.Runs[k-1].State == state {
+			r.Runs[k-1].Length += length
+		} else {
+			r.Runs = append(r.Runs, AckVectorRun{State: state, Length: length})
+		}
+	}
+	return r
+}
+
+// FindAckVectorOption returns the first well-formed Ack Vector option in opts, or nil otherwise
+func FindAckVectorOption(opts []*Option) *AckVectorOption {
+	for _, opt := range opts {
+		if av := DecodeAckVectorOption(opt); av != nil {
+			return av
+		}
+	}
+	return nil
+}
+
+// AckVectorRange describes the State of all packets with sequence numbers between Lo and Hi, inclusive
+type AckVectorRange struct {
+	Lo, Hi int64
+	State  byte
+}
+
+// Ranges returns the sequence number ranges described by the vector, from newest to oldest,
+// assuming that the vector was received on a packet with Acknowledgement Number ackNo.
+// Ranges that reach below sequence number zero continue from the top of the sequence space,
+// so Lo may exceed Hi numerically.
+func (opt *AckVectorOption) Ranges(ackNo int64) []AckVectorRange {
+	r := make([]AckVectorRange, 0, len(opt.Runs))
+	hi := ackNo
+	for _, run := range opt.Runs {
+		lo := SeqAdd(hi, 1-run.Length)
+		r = append(r, AckVectorRange{Lo: lo, Hi: hi, State: run.State})
+		hi = SeqAdd(lo, -1)
+	}
+	return r
+}
+
+// Len returns the number of packets described by the vector
+func (opt *AckVectorOption) Len() int64 {
+	var n int64
+	for _, run := range opt.Runs {
+		n += run.Length
+	}
+	return n
+}
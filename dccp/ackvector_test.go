@@ -0,0 +1,183 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+func TestAckVectorOption(t *testing.T) {
+	// The example from Section 11.4
+	opt := DecodeAckVectorOption(&Option{Type: OptionAckVectorNonce0, Data: []byte{0, 192, 3, 64, 5}})
+	if opt == nil {
+		t.Fatalf("error decoding ack vector option")
+	}
+	expect := []AckVectorRange{
+		{100, 100, AckVectorReceived},
+		{99, 99, AckVectorNotReceived},
+		{95, 98, AckVectorReceived},
+		{94, 94, AckVectorECNMarked},
+		{88, 93, AckVectorReceived},
+	}
+	ranges := opt.Ranges(100)
+	if len(ranges) != len(expect) {
+		t.Fatalf("expecting %d ranges, got %d", len(expect), len(ranges))
+	}
+	for i, r := range ranges {
+		if r != expect[i] {
+			t.Errorf("range %d: expecting %v, got %v", i, expect[i], r)
+		}
+	}
+
+	// Runs longer than a single vector byte
+	opt = &AckVectorOption{
+		Nonce: 1,
+		Runs:  []AckVectorRun{{AckVectorReceived, 130}, {AckVectorNotReceived, 1}, {AckVectorReceived, 64}},
+	}
+	enc, err := opt.Encode()
+	if err != nil {
+		t.Fatalf("error encoding ack vector option (%s)", err)
+	}
+	if enc.Type != OptionAckVectorNonce1 || len(enc.Data) != 5 {
+		t.Fatalf("unexpected encoding type=%d len=%d", enc.Type, len(enc.Data))
+	}
+	dec := DecodeAckVectorOption(enc)
+	if dec == nil || dec.Nonce != 1 || len(dec.Runs) != len(opt.Runs) {
+		t.Fatalf("ack vector option decodes to %v", dec)
+	}
+	for i, run := range dec.Runs {
+		if run != opt.Runs[i] {
+			t.Errorf("run %d: expecting %v, got %v", i, opt.Runs[i], run)
+		}
+	}
+
+	// Reserved state
+	if DecodeAckVectorOption(&Option{Type: OptionAckVectorNonce0, Data: []byte{0x80}}) != nil {
+		t.Errorf("decoded ack vector with reserved state")
+	}
+}
+
+func TestAckVectorBuffer(t *testing.T) {
+	var b ackVectorBuffer
+	b.Init()
+	for _, seqNo := range []int64{10, 11, 13, 14, 17} {
+		b.OnRead(seqNo, AckVectorReceived, 0)
+	}
+	opt := b.makeOption(17)
+	expect := []AckVectorRun{
+		{AckVectorReceived, 1},
+		{AckVectorNotReceived, 2},
+		{AckVectorReceived, 2},
+		{AckVectorNotReceived, 1},
+		{AckVectorReceived, 2},
+	}
+	checkRuns := func(opt *AckVectorOption, expect []AckVectorRun) {
+		if opt == nil || len(opt.Runs) != len(expect) {
+			t.Fatalf("expecting runs %v, got %v", expect, opt)
+		}
+		for i, run := range opt.Runs {
+			if run != expect[i] {
+				t.Errorf("run %d: expecting %v, got %v", i, expect[i], run)
+			}
+		}
+	}
+	checkRuns(opt, expect)
+
+	// Our ack with seqno 498 reports packets up to 14, but is lost. Acknowledgements are not
+	// cumulative, so acknowledging our packet 499, which carried no Ack Vector, clears nothing.
+	b.OnWrite(498, 14, b.makeOption(14))
+	b.OnAck(499)
+	checkRuns(b.makeOption(17), expect)
+
+	// Our ack with seqno 500 reports packets up to 17. A late packet 12 then arrives,
+	// so that the peer acknowledging 500 does not clear the state of 12.
+	b.OnWrite(500, 17, b.makeOption(17))
+	b.OnRead(12, AckVectorReceived, 0)
+	b.OnAck(500)
+	checkRuns(b.makeOption(17), []AckVectorRun{
+		{AckVectorReceived, 1},
+		{AckVectorNotReceived, 2},
+		{AckVectorReceived, 3},
+	})
+
+	// Acknowledging an ack covering all packets retains the newest packet only
+	b.OnWrite(501, 17, b.makeOption(17))
+	b.OnAck(501)
+	checkRuns(b.makeOption(17), []AckVectorRun{{AckVectorReceived, 1}})
+	if b.makeOption(16) != nil {
+		t.Errorf("expecting no ack vector for a forgotten packet")
+	}
+
+	// Acks that exceed the newest packet in the buffer
+	checkRuns(b.makeOption(19), []AckVectorRun{{AckVectorNotReceived, 2}, {AckVectorReceived, 1}})
+}
+
+func TestAckVectorBufferWrap(t *testing.T) {
+	var b ackVectorBuffer
+	b.Init()
+	var nonces ecnNonceBuffer
+	nonces.Init()
+	// Packets SEQNOMAX-2 through 2, except SEQNOMAX and 1, arrive
+	first := SeqAdd(0, -3)
+	for i := int64(0); i < 6; i++ {
+		seqNo := SeqAdd(first, i)
+		nonces.OnWrite(seqNo, byte(i&1))
+		if seqNo == SEQNOMAX || seqNo == 1 {
+			continue
+		}
+		b.OnRead(seqNo, AckVectorReceived, byte(i&1))
+	}
+	opt := b.makeOption(2)
+	expect := []AckVectorRange{
+		{2, 2, AckVectorReceived},
+		{1, 1, AckVectorNotReceived},
+		{0, 0, AckVectorReceived},
+		{SEQNOMAX, SEQNOMAX, AckVectorNotReceived},
+		{SEQNOMAX - 2, SEQNOMAX - 1, AckVectorReceived},
+	}
+	ranges := opt.Ranges(2)
+	if len(ranges) != len(expect) {
+		t.Fatalf("expecting ranges %v, got %v", expect, ranges)
+	}
+	for i, r := range ranges {
+		if r != expect[i] {
+			t.Errorf("range %d: expecting %v, got %v", i, expect[i], r)
+		}
+	}
+	if !nonces.Verify(opt, 2) {
+		t.Errorf("nonce echo of an ack vector across the wrap does not verify")
+	}
+	opt.Nonce ^= 1
+	if nonces.Verify(opt, 2) {
+		t.Errorf("wrong nonce echo of an ack vector across the wrap verifies")
+	}
+
+	// Acknowledging an ack that reported all packets retains the newest packet only
+	b.OnWrite(SEQNOMAX, 2, opt)
+	b.OnAck(SEQNOMAX)
+	if b.tail != 2 || len(b.states) != 1 {
+		t.Errorf("expecting buffer to retain packet 2, got tail %d and %d states", b.tail, len(b.states))
+	}
+}
+
+func TestAckVectorBufferTruncated(t *testing.T) {
+	var b ackVectorBuffer
+	b.Init()
+	// Every other packet arrives, so that an Ack Vector cannot describe all of them
+	const n = 4 * AckVectorMaxLen
+	for seqNo := int64(0); seqNo < n; seqNo += 2 {
+		b.OnRead(seqNo, AckVectorReceived, 0)
+	}
+	opt := b.makeOption(n - 2)
+	if opt.Len() >= n-1 {
+		t.Fatalf("expecting a truncated ack vector, got %d packets", opt.Len())
+	}
+	// The states, which the truncated ack vector did not report, are kept
+	b.OnWrite(1000, n-2, opt)
+	b.OnAck(1000)
+	if b.tail != 0 || len(b.states) != n-1 {
+		t.Errorf("expecting buffer to retain all packets, got tail %d and %d states", b.tail, len(b.states))
+	}
+}
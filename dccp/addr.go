@@ -6,6 +6,7 @@ package dccp
 
 import (
 	"errors"
+	"net"
 	"strconv"
 	"strings"
 )
@@ -86,3 +87,35 @@ func (addr *Addr) Write(p []byte) (n int, err error) {
 	EncodeUint16(addr.Port, p[0:2])
 	return n + 2, nil
 }
+
+// FlowAddr is the address of an endpoint of a DCCP connection. It consists of the link-layer
+// address of the endpoint and the label of its flow in the Mux. FlowAddr implements net.Addr.
+type FlowAddr struct {
+	Link  net.Addr // Link-layer address, or nil if not known
+	Label *Label   // Flow label, or nil if not known
+}
+
+// Network returns the name of the DCCP address namespace, included to conform to net.Addr
+func (addr *FlowAddr) Network() string { return "dccp" }
+
+// String returns the link-layer address and the flow label, separated by a slash
+func (addr *FlowAddr) String() string {
+	if addr.Link == nil {
+		return addr.Label.String()
+	}
+	if addr.Label == nil {
+		return addr.Link.String()
+	}
+	return addr.Link.String() + "/" + addr.Label.String()
+}
+
+// newFlowAddr returns the FlowAddr of an endpoint with link-layer address link and flow label
+// label. Zero labels, which have not been assigned yet, are omitted, and so are the labels of
+// HeaderConns that do not use flow labels, like those of EncapMux.
+func newFlowAddr(link net.Addr, label Bytes) *FlowAddr {
+	addr := &FlowAddr{Link: link}
+	if l, ok := label.(*Label); ok {
+		addr.Label, _, _ = ReadLabel(l.Bytes())
+	}
+	return addr
+}
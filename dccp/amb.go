@@ -26,8 +26,9 @@ type Amb struct {
 	labels []string
 }
 
-// A zero-value Amb has the special-case behavior of ignoring all emits
-var NoLogging *Amb = &Amb{}
+// An Amb without an Env has the special-case behavior of ignoring all emits. NoLogging has
+// no flags set.
+var NoLogging *Amb = &Amb{flags: NewFlags()}
 
 // NewAmb creates a new Amb object with a single entry in the label stack
 func NewAmb(label string, env *Env) *Amb {
@@ -63,10 +63,16 @@ type SenderCongestionControl interface {
 	// NOTE: If the CC is not active, OnRead MUST return nil.
 	OnRead(fb *FeedbackHeader) error
 
-	// Strobe blocks until a new packet can be sent without violating the
+	// Conn calls OnSlowReceiver before OnRead, when the received packet carries a Slow
+	// Receiver option. The CC SHOULD NOT increase its sending rate for approximately one
+	// round-trip time after now, Section 11.6. Slow Receiver does not indicate congestion.
+	// NOTE: If the CC is not active, OnSlowReceiver MUST return immediately.
+	OnSlowReceiver(now int64)
+
+	// Strobe blocks until a new packet of type Type can be sent without violating the
 	// congestion control rate limit. 
 	// NOTE: If the CC is not active, Strobe MUST return immediately.
-	Strobe()
+	Strobe(Type byte)
 
 	// OnIdle is called periodically, giving the CC a chance to:
 	// (a) Request a connection reset by returning a CongestionReset, or
@@ -111,6 +117,46 @@ type ReceiverCongestionControl interface {
 	Close()
 }
 
+// AckRatioSender is implemented by sender congestion controls that adjust the Ack Ratio
+// feature, Section 11.3. Conn negotiates the value returned by GetAckRatio with the other side.
+type AckRatioSender interface {
+	// GetAckRatio returns the Ack Ratio desired by the HC-Sender
+	GetAckRatio() int64
+}
+
+// AckRatioReceiver is implemented by receiver congestion controls that acknowledge data at
+// the rate set by the Ack Ratio feature. Conn calls SetAckRatio whenever the value changes.
+type AckRatioReceiver interface {
+	// SetAckRatio sets the number of data packets covered by each acknowledgement
+	SetAckRatio(ratio int64)
+}
+
+// PacketRateSender is implemented by sender congestion controls that can estimate how many
+// packets they send per round-trip time. Conn uses the estimate to size the Sequence Window
+// feature, Section 7.5.2.
+type PacketRateSender interface {
+	// GetPacketsPerRTT returns the maximum number of packets the HC-Sender expects to send in
+	// one round-trip time at its current rate, or zero if no estimate is available
+	GetPacketsPerRTT() int64
+}
+
+// PacketIntervalSender is implemented by sender congestion controls that pace packets at a
+// rate. Conn holds back Path MTU probes while the pace is too slow to afford them, Section 14.
+type PacketIntervalSender interface {
+	// GetPacketInterval returns the average time in ns between packets at the current rate
+	// of the HC-Sender, or zero if the rate is not known
+	GetPacketInterval() int64
+}
+
+// PMTUSender is implemented by sender congestion controls that derive their Congestion Control
+// Maximum Packet Size from the Path MTU, which changes as it is discovered, Section 14. Conn
+// calls SetPMTU when the congestion control is opened, and whenever the PMTU changes.
+type PMTUSender interface {
+	// SetPMTU sets the Path MTU in bytes. Data packets spend overhead bytes of it on headers
+	// and options, leaving the rest for application data.
+	SetPMTU(pmtu int32, overhead int32)
+}
+
 // PreHeader contains information that is shown to the 
 // sender and receiver congesion controls before a packet is sent.
 // PreHeader contains the parts of the DCCP header than are fixed before the
@@ -136,6 +182,9 @@ type PreHeader struct {
 	// the roundtrip time without factoring rate-related wait times in
 	// endpoint queues.
 	TimeWrite int64
+
+	// Length of application data in bytes
+	DataLen int
 }
 
 // FeedbackHeader contains information that is shown to the 
@@ -151,6 +200,10 @@ type FeedbackHeader struct {
 
 	// Time when header received
 	Time    int64
+
+	// DataDropped lists the packets that are newly reported, by the Data Dropped options of
+	// this header, as received but not delivered to the application. Section 11.7
+	DataDropped []DataDroppedRange
 }
 
 // FeedforwardHeader contains information that is shown to the 
@@ -171,10 +224,21 @@ type FeedforwardHeader struct {
 
 	// Length of application data in bytes
 	DataLen int
+
+	// NDPCount is the number of non-data packets sent immediately before this one, as
+	// reported by the NDP Count option, or zero if the option is absent. Section 7.7
+	NDPCount uint64
+
+	// ECN is the ECN codepoint of the packet. Packets marked ECNCE indicate congestion just
+	// like lost packets, Section 12
+	ECN byte
 }
 
 // CCID is a factory type that creates instances of sender and receiver CCIDs
 type CCID interface {
+	// GetID() returns the CCID of the congestion control algorithm created by this factory
+	GetID() byte
+
 	NewSender(env *Env, amb *Amb, args ...interface{}) SenderCongestionControl
 	NewReceiver(env *Env, amb *Amb, args ...interface{}) ReceiverCongestionControl
 }
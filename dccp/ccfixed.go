@@ -8,11 +8,13 @@ type CCFixed struct {
 
 }
 
-func (CCFixed) NewSender(env *Env, amb *Amb) SenderCongestionControl {
+func (CCFixed) GetID() byte { return CCID_FIXED }
+
+func (CCFixed) NewSender(env *Env, amb *Amb, args ...interface{}) SenderCongestionControl {
 	return newFixedRateSenderControl(env, 1e9) // one packet per second. sendsPerSecond
 }
 
-func (CCFixed) NewReceiver(env *Env, amb *Amb) ReceiverCongestionControl {
+func (CCFixed) NewReceiver(env *Env, amb *Amb, args ...interface{}) ReceiverCongestionControl {
 	return newFixedRateReceiverControl(env)
 }
 
@@ -60,9 +62,11 @@ func (scc *fixedRateSenderControl) OnWrite(ph *PreHeader) (ccval int8, options [
 
 func (scc *fixedRateSenderControl) OnRead(fb *FeedbackHeader) error { return nil }
 
+func (scc *fixedRateSenderControl) OnSlowReceiver(now int64) {}
+
 func (scc *fixedRateSenderControl) OnIdle(now int64) error { return nil }
 
-func (scc *fixedRateSenderControl) Strobe() {
+func (scc *fixedRateSenderControl) Strobe(Type byte) {
 	<-scc.strobeRead
 }
 
@@ -0,0 +1,86 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderAckRatio maintains the Ack Ratio of the CCID 2 sender, which controls the rate at
+// which the receiver generates DCCP-Ack packets. Its logic is described in RFC 4341,
+// Sections 6.1.1 and 6.1.2.
+type senderAckRatio struct {
+	ratio        int64 // Current Ack Ratio
+	peerSeqNo    int64 // Greatest sequence number received from the receiver
+	peerSeqNoSet bool  // Whether peerSeqNo is valid
+	ackLoss      bool  // Whether acknowledgements were lost in the current window of data
+	windowAcked  int64 // Number of data packets acknowledged in the current window of data
+	cleanWindows int64 // Consecutive windows of data without lost acknowledgements
+}
+
+// DefaultAckRatio is the Ack Ratio in effect before any adjustments, RFC 4341 Section 6
+const DefaultAckRatio = 2
+
+// Init resets the senderAckRatio for new use
+func (t *senderAckRatio) Init() {
+	t.ratio = DefaultAckRatio
+	t.peerSeqNo = 0
+	t.peerSeqNoSet = false
+	t.ackLoss = false
+	t.windowAcked = 0
+	t.cleanWindows = 0
+}
+
+// AckRatio returns the current Ack Ratio
+func (t *senderAckRatio) AckRatio() int64 { return t.ratio }
+
+// OnPeerPacket is called for every packet received from the receiver. Gaps in the
+// receiver's sequence numbers are taken as lost acknowledgements since, lacking better
+// information, every lost packet is assumed to be a non-data packet.
+func (t *senderAckRatio) OnPeerPacket(seqNo int64) {
+	if !t.peerSeqNoSet {
+		t.peerSeqNo, t.peerSeqNoSet = seqNo, true
+		return
+	}
+	if dccp.SeqDist(t.peerSeqNo, seqNo) > 1 {
+		t.ackLoss = true
+	}
+	t.peerSeqNo = dccp.SeqMax(t.peerSeqNo, seqNo)
+}
+
+// OnDataAcked is called when acked data packets are newly acknowledged. cwnd is the current
+// congestion window. OnDataAcked returns true if the Ack Ratio has changed.
+func (t *senderAckRatio) OnDataAcked(acked int64, cwnd int64) bool {
+	old := t.ratio
+	t.windowAcked += acked
+	if t.windowAcked >= cwnd {
+		t.windowAcked = 0
+		if t.ackLoss {
+			// Each window of data with lost acknowledgements doubles the Ack Ratio
+			t.ratio *= 2
+			t.cleanWindows = 0
+		} else {
+			// Every cwnd/(R^2 - R) consecutive clean windows decrease it by one
+			t.cleanWindows++
+			if t.ratio > 1 && t.cleanWindows >= max64(1, cwnd/(t.ratio*t.ratio-t.ratio)) {
+				t.ratio--
+				t.cleanWindows = 0
+			}
+		}
+		t.ackLoss = false
+	}
+	t.constrain(cwnd)
+	return t.ratio != old
+}
+
+// constrain enforces the constraints on Ack Ratio: it does not exceed cwnd/2, rounded up,
+// except that 2 is always acceptable, and it is at least 2 for windows of 4 or more packets.
+func (t *senderAckRatio) constrain(cwnd int64) {
+	t.ratio = min64(t.ratio, max64(DefaultAckRatio, (cwnd+1)/2))
+	if cwnd >= 4 {
+		t.ratio = max64(t.ratio, DefaultAckRatio)
+	}
+	t.ratio = max64(t.ratio, 1)
+}
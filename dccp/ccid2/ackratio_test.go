@@ -0,0 +1,57 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"testing"
+)
+
+// TestAckRatio checks that lost acknowledgements double the Ack Ratio, that clean windows of
+// data decrease it, and that it respects the constraints of RFC 4341, Section 6.1.2
+func TestAckRatio(t *testing.T) {
+	var r senderAckRatio
+	r.Init()
+	if r.AckRatio() != DefaultAckRatio {
+		t.Fatalf("initial ack ratio %d", r.AckRatio())
+	}
+
+	// A gap in the receiver's sequence numbers means a lost acknowledgement
+	r.OnPeerPacket(100)
+	r.OnPeerPacket(102)
+	if r.OnDataAcked(9, 10) {
+		t.Errorf("ack ratio changed before a window of data was acknowledged")
+	}
+	if !r.OnDataAcked(1, 10) || r.AckRatio() != 4 {
+		t.Errorf("expecting ack ratio 4 after ack loss, got %d", r.AckRatio())
+	}
+
+	// With cwnd 10, each clean window decreases an Ack Ratio of 4 or 3 by one
+	r.OnPeerPacket(103)
+	for _, expect := range []int64{3, 2} {
+		if !r.OnDataAcked(10, 10) || r.AckRatio() != expect {
+			t.Errorf("expecting ack ratio %d after a clean window, got %d", expect, r.AckRatio())
+		}
+	}
+	// The Ack Ratio is at least 2 for windows of 4 or more packets
+	for i := 0; i < 10; i++ {
+		r.OnDataAcked(10, 10)
+	}
+	if r.AckRatio() != 2 {
+		t.Errorf("ack ratio dropped to %d with cwnd 10", r.AckRatio())
+	}
+	// A smaller window allows an Ack Ratio of 1
+	if !r.OnDataAcked(2, 2) || r.AckRatio() != 1 {
+		t.Errorf("expecting ack ratio 1 with cwnd 2, got %d", r.AckRatio())
+	}
+
+	// The Ack Ratio does not exceed cwnd/2, rounded up
+	r.Init()
+	r.OnPeerPacket(1)
+	r.OnPeerPacket(5)
+	r.OnDataAcked(6, 6)
+	if r.AckRatio() != 3 {
+		t.Errorf("expecting ack ratio 3 with cwnd 6, got %d", r.AckRatio())
+	}
+}
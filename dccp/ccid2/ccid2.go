@@ -0,0 +1,23 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+// Package ccid2 implements CCID 2, TCP-like Congestion Control, as described in RFC 4341.
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// CCID2 is a factory for CCID 2 senders and receivers. It conforms to dccp.CCID.
+type CCID2 struct {}
+
+func (CCID2) GetID() byte { return dccp.CCID2 }
+
+func (CCID2) NewSender(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.SenderCongestionControl {
+	return newSender(env, amb)
+}
+
+func (CCID2) NewReceiver(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.ReceiverCongestionControl {
+	return newReceiver(env, amb)
+}
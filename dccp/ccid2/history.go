@@ -0,0 +1,169 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderHistory remembers the packets sent by the CCID 2 sender that have not yet been
+// acknowledged or declared lost. It is used for pipe accounting, loss inference and RTT
+// sampling.
+type senderHistory struct {
+	packets []sentPacket // Sent packets, in increasing order of sequence number
+}
+
+// sentPacket records the departure of a single packet
+type sentPacket struct {
+	SeqNo int64
+	Time  int64 // Time the packet was sent
+	Data  bool  // Whether the packet carried application data
+	State byte  // One of packetOutstanding, packetAcked or packetLost
+}
+
+const (
+	packetOutstanding = iota
+	packetAcked
+	packetLost
+)
+
+const (
+	// NumDupAck is the number of packets sent after a packet P that must be acknowledged,
+	// before P is inferred to be lost, RFC 4341 Section 5
+	NumDupAck = 3
+
+	// SenderHistoryMaxLen is the maximum number of sent packets remembered
+	SenderHistoryMaxLen = 4096
+)
+
+// Init resets the senderHistory for new use
+func (t *senderHistory) Init() {
+	t.packets = make([]sentPacket, 0, 64)
+}
+
+// OnWrite records a packet departure. It returns the record of an outstanding packet that
+// was pushed out of the history to make room, or nil otherwise.
+func (t *senderHistory) OnWrite(seqNo int64, timeWrite int64, data bool) (evicted *sentPacket) {
+	if len(t.packets) >= SenderHistoryMaxLen {
+		if t.packets[0].State == packetOutstanding {
+			p := t.packets[0]
+			p.State = packetLost
+			evicted = &p
+		}
+		t.packets = t.packets[1:]
+	}
+	t.packets = append(t.packets, sentPacket{SeqNo: seqNo, Time: timeWrite, Data: data, State: packetOutstanding})
+	return evicted
+}
+
+// find returns the index of the packet with the given sequence number, or -1 otherwise
+func (t *senderHistory) find(seqNo int64) int {
+	lo, hi := 0, len(t.packets)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if dccp.SeqLess(t.packets[mid].SeqNo, seqNo) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(t.packets) && t.packets[lo].SeqNo == seqNo {
+		return lo
+	}
+	return -1
+}
+
+// Ack marks the packet with sequence number seqNo as received. If the packet was
+// outstanding, its record is returned. Otherwise, Ack returns nil.
+func (t *senderHistory) Ack(seqNo int64) *sentPacket {
+	i := t.find(seqNo)
+	if i < 0 || t.packets[i].State != packetOutstanding {
+		return nil
+	}
+	t.packets[i].State = packetAcked
+	p := t.packets[i]
+	return &p
+}
+
+// AckRange marks all outstanding packets with sequence numbers between lo and hi, inclusive,
+// as received, and returns their records.
+func (t *senderHistory) AckRange(lo, hi int64) []*sentPacket {
+	var r []*sentPacket
+	for i := range t.packets {
+		p := &t.packets[i]
+		if dccp.SeqLess(hi, p.SeqNo) {
+			break
+		}
+		if dccp.SeqLess(p.SeqNo, lo) || p.State != packetOutstanding {
+			continue
+		}
+		p.State = packetAcked
+		q := *p
+		r = append(r, &q)
+	}
+	return r
+}
+
+// AckUpTo marks all outstanding packets with sequence numbers not exceeding ackNo as
+// received, and returns their records.
+func (t *senderHistory) AckUpTo(ackNo int64) []*sentPacket {
+	var r []*sentPacket
+	for i := range t.packets {
+		p := &t.packets[i]
+		if dccp.SeqLess(ackNo, p.SeqNo) {
+			break
+		}
+		if p.State != packetOutstanding {
+			continue
+		}
+		p.State = packetAcked
+		q := *p
+		r = append(r, &q)
+	}
+	return r
+}
+
+// DetectLosses declares lost every outstanding packet that is followed by at least
+// NumDupAck acknowledged packets, and returns their records.
+func (t *senderHistory) DetectLosses() []*sentPacket {
+	var r []*sentPacket
+	acked := 0
+	for i := len(t.packets) - 1; i >= 0; i-- {
+		p := &t.packets[i]
+		switch p.State {
+		case packetAcked:
+			acked++
+		case packetOutstanding:
+			if acked >= NumDupAck {
+				p.State = packetLost
+				q := *p
+				r = append(r, &q)
+			}
+		}
+	}
+	return r
+}
+
+// LoseAll declares all outstanding packets lost. It is used on transmit timeouts, so that
+// late acknowledgements of these packets do not affect the pipe.
+func (t *senderHistory) LoseAll() {
+	for i := range t.packets {
+		if t.packets[i].State == packetOutstanding {
+			t.packets[i].State = packetLost
+		}
+	}
+}
+
+// Prune forgets the records at the head of the history that are no longer outstanding.
+// Such records precede all outstanding packets and are not needed to infer losses.
+func (t *senderHistory) Prune() {
+	k := 0
+	for k < len(t.packets) && t.packets[k].State != packetOutstanding {
+		k++
+	}
+	if k > 0 {
+		t.packets = append(t.packets[:0], t.packets[k:]...)
+	}
+}
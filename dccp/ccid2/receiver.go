@@ -0,0 +1,110 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+func newReceiver(env *dccp.Env, amb *dccp.Amb) *receiver {
+	return &receiver{ env: env, amb: amb.Refine("receiver"), ackRatio: DefaultAckRatio }
+}
+
+// receiver implements CCID2 congestion control and it conforms to dccp.ReceiverCongestionControl.
+// The receiver acknowledges every Ack Ratio data packets, and acknowledges any remaining
+// data packets after a short delay.
+type receiver struct {
+	env *dccp.Env
+	amb *dccp.Amb
+	dccp.Mutex
+	open         bool  // Whether the CC is active
+	ackRatio     int64 // Number of data packets per acknowledgement
+	dataSinceAck int64 // Number of data packets received since the last Ack was sent
+	lastData     int64 // Time when the last data packet was received
+}
+
+// AckDelay is the maximum time the receiver holds back the acknowledgement of data packets
+// that do not fill up an Ack Ratio
+const AckDelay = dccp.RoundtripDefault / 2
+
+// GetID() returns the CCID of this congestion control algorithm
+func (r *receiver) GetID() byte {
+	return dccp.CCID2
+}
+
+// Open tells the Congestion Control that the connection has entered
+// OPEN or PARTOPEN state and that the CC can now kick in.
+func (r *receiver) Open() {
+	r.Lock()
+	defer r.Unlock()
+	if r.open {
+		panic("opening an open ccid2 receiver")
+	}
+	r.open = true
+	r.dataSinceAck = 0
+	r.lastData = 0
+}
+
+// SetAckRatio sets the number of data packets acknowledged by each Ack, as negotiated
+// by the sender. It conforms to dccp.AckRatioReceiver.
+func (r *receiver) SetAckRatio(ratio int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.ackRatio = max64(1, ratio)
+}
+
+// Conn calls OnWrite before a packet is sent to give CongestionControl
+// an opportunity to add CCVal and options to an outgoing packet
+func (r *receiver) OnWrite(ph *dccp.PreHeader) (options []*dccp.Option) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.open {
+		return nil
+	}
+	if ph.Type == dccp.Ack || ph.Type == dccp.DataAck {
+		r.dataSinceAck = 0
+	}
+	return nil
+}
+
+// Conn calls OnRead after a packet has been accepted and validated
+// If OnRead returns ErrDrop, the packet will be dropped and no further processing
+// will occur. If the CC is not active, OnRead MUST return nil.
+func (r *receiver) OnRead(ff *dccp.FeedforwardHeader) error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.open {
+		return nil
+	}
+	if ff.Type != dccp.Data && ff.Type != dccp.DataAck {
+		return nil
+	}
+	r.dataSinceAck++
+	r.lastData = ff.Time
+	if r.dataSinceAck >= r.ackRatio {
+		return dccp.CongestionAck
+	}
+	return nil
+}
+
+// OnIdle behaves identically to the same method of the HC-Sender CCID
+func (r *receiver) OnIdle(now int64) error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.open {
+		return nil
+	}
+	if r.dataSinceAck > 0 && now-r.lastData >= AckDelay {
+		return dccp.CongestionAck
+	}
+	return nil
+}
+
+// Close terminates the half-connection congestion control when it is not needed any longer
+func (r *receiver) Close() {
+	r.Lock()
+	defer r.Unlock()
+	r.open = false
+}
@@ -0,0 +1,302 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"fmt"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+func newSender(env *dccp.Env, amb *dccp.Amb) *sender {
+	return &sender{ env: env, amb: amb.Refine("sender") }
+}
+
+// sender implements a CCID2 congestion control sender.
+// It conforms to dccp.SenderCongestionControl.
+type sender struct {
+	env *dccp.Env
+	amb *dccp.Amb
+	dccp.Mutex // Locks all fields below
+	senderWindow
+	senderHistory
+	senderTimeout
+	senderAckRatio
+	gss       int64 // Greatest sequence number sent
+	gssSet    bool // Whether gss is valid
+	heartbeat int64 // Desired heartbeat interval
+	slowUntil int64 // Time until which cwnd is not increased, following a Slow Receiver option
+	wake      chan int // Closed and replaced whenever a blocked Strobe should re-check the window
+	open      bool // Whether the CC is active
+}
+
+// CCMPS is the Congestion Control Maximum Packet Size of CCID 2. CCID 2 imposes no limit
+// on packet size beyond the PMTU, RFC 4341 Section 5.3
+const CCMPS = 1e9
+
+// GetID() returns the CCID of this congestion control algorithm
+func (s *sender) GetID() byte { return dccp.CCID2 }
+
+// GetCCMPS returns the Congestion Control Maximum Packet Size, CCMPS. Generally, PMTU <= CCMPS
+func (s *sender) GetCCMPS() int32 { return CCMPS }
+
+// GetRTT returns the Round-Trip Time as measured by this CCID
+func (s *sender) GetRTT() int64 {
+	s.Lock()
+	defer s.Unlock()
+	rtt, _ := s.senderTimeout.RTT()
+	return rtt
+}
+
+// GetAckRatio returns the Ack Ratio desired by the sender, which Conn negotiates with
+// the receiver. It conforms to dccp.AckRatioSender.
+func (s *sender) GetAckRatio() int64 {
+	s.Lock()
+	defer s.Unlock()
+	if !s.open {
+		return DefaultAckRatio
+	}
+	return s.senderAckRatio.AckRatio()
+}
+
+// GetPacketsPerRTT returns the congestion window, which limits the number of data packets
+// sent per round-trip time. It conforms to dccp.PacketRateSender.
+func (s *sender) GetPacketsPerRTT() int64 {
+	s.Lock()
+	defer s.Unlock()
+	if !s.open {
+		return 0
+	}
+	return s.senderWindow.Cwnd()
+}
+
+// Open tells the Congestion Control that the connection has entered
+// OPEN or PARTOPEN state and that the CC can now kick in. Before the
+// call to Open and after the call to Close, the Strobe function is
+// expected to return immediately.
+func (s *sender) Open() {
+	s.Lock()
+	defer s.Unlock()
+	if s.open {
+		panic("opening an open ccid2 sender")
+	}
+	s.senderWindow.Init()
+	s.senderHistory.Init()
+	s.senderTimeout.Init()
+	s.senderAckRatio.Init()
+	s.gss, s.gssSet = 0, false
+	s.wake = make(chan int)
+	s.open = true
+}
+
+// wakeup unblocks any pending Strobe calls, so they can re-check the window
+func (s *sender) wakeup() {
+	s.AssertLocked()
+	if s.wake != nil {
+		close(s.wake)
+	}
+	s.wake = make(chan int)
+}
+
+// Conn calls OnWrite before a packet is sent to give CongestionControl
+// an opportunity to add CCVal and options to an outgoing packet
+// If the CC is not active, OnWrite should return 0, nil.
+func (s *sender) OnWrite(ph *dccp.PreHeader) (ccval int8, options []*dccp.Option) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.open {
+		return 0, nil
+	}
+
+	if s.gssSet {
+		s.gss = dccp.SeqMax(s.gss, ph.SeqNo)
+	} else {
+		s.gss, s.gssSet = ph.SeqNo, true
+	}
+	data := ph.Type == dccp.Data || ph.Type == dccp.DataAck
+	if evicted := s.senderHistory.OnWrite(ph.SeqNo, ph.TimeWrite, data); evicted != nil && evicted.Data {
+		s.senderWindow.OnDataLeft()
+	}
+	if data {
+		s.senderWindow.OnDataSent()
+		s.senderTimeout.OnDataSent(ph.SeqNo, ph.TimeWrite)
+	}
+	return 0, nil
+}
+
+// Conn calls OnRead after a packet has been accepted and validated
+// If OnRead returns ErrDrop, the packet will be dropped and no further processing
+// will occur. If OnRead returns ResetError, the connection will be reset.
+// If the CC is not active, OnRead MUST return nil.
+func (s *sender) OnRead(fb *dccp.FeedbackHeader) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.open {
+		return nil
+	}
+	s.senderAckRatio.OnPeerPacket(fb.SeqNo)
+
+	// Only feedback packets (Ack or DataAck) acknowledge our packets
+	if fb.Type != dccp.Ack && fb.Type != dccp.DataAck {
+		return nil
+	}
+
+	// Packets reported received by the Ack Vector are acknowledged individually, and those
+	// reported ECN marked also indicate congestion. Lacking an Ack Vector, the Acknowledgement
+	// Number is taken as a cumulative acknowledgement of all packets up to it.
+	var acked int64
+	if av := dccp.FindAckVectorOption(fb.Options); av != nil {
+		for _, r := range av.Ranges(fb.AckNo) {
+			if r.State == dccp.AckVectorNotReceived {
+				continue
+			}
+			for _, p := range s.senderHistory.AckRange(r.Lo, r.Hi) {
+				if !p.Data {
+					continue
+				}
+				s.senderWindow.OnDataLeft()
+				s.senderTimeout.OnDataAcked(p, fb.Time)
+				if r.State == dccp.AckVectorECNMarked {
+					s.onCongestion(p, "marked", fb)
+					continue
+				}
+				acked++
+			}
+		}
+	} else {
+		for _, p := range s.senderHistory.AckUpTo(fb.AckNo) {
+			if !p.Data {
+				continue
+			}
+			acked++
+			s.senderWindow.OnDataLeft()
+			s.senderTimeout.OnDataAcked(p, fb.Time)
+		}
+	}
+
+	// Infer losses from packets acknowledged after a hole
+	for _, p := range s.senderHistory.DetectLosses() {
+		if !p.Data {
+			continue
+		}
+		s.senderWindow.OnDataLeft()
+		s.senderTimeout.OnDataLost(p)
+		s.onCongestion(p, "lost", fb)
+	}
+	s.senderHistory.Prune()
+
+	// Data Dropped reports acknowledged packets, whose data did not reach the application
+	for _, d := range fb.DataDropped {
+		switch d.DropCode {
+		case dccp.DropProtocolConstraints, dccp.DropAppNotListening:
+			// These do not indicate congestion
+		case dccp.DropReceiveBuffer:
+			s.senderWindow.OnReceiveBufferDrop(dccp.SeqDist(d.Lo, d.Hi) + 1)
+			s.amb.E(dccp.EventInfo, fmt.Sprintf("Receive buffer drop, cwnd=%d, ssthresh=%d",
+				s.senderWindow.Cwnd(), s.senderWindow.SSThresh()), fb)
+		default:
+			// Other Drop Codes are treated as ECN marks, RFC 4340 Section 11.7.2
+			if s.senderWindow.OnCongestion(d.Hi, s.gss) {
+				s.amb.E(dccp.EventInfo, fmt.Sprintf("Congestion event, dropped=%d, recover=%d, cwnd=%d, ssthresh=%d",
+					d.Hi, s.gss, s.senderWindow.Cwnd(), s.senderWindow.SSThresh()), fb)
+			}
+		}
+	}
+
+	if acked > 0 {
+		if fb.Time >= s.slowUntil {
+			s.senderWindow.OnAcked(acked, s.senderAckRatio.AckRatio())
+		}
+		if s.senderAckRatio.OnDataAcked(acked, s.senderWindow.Cwnd()) {
+			s.amb.E(dccp.EventInfo, fmt.Sprintf("Ack Ratio=%d", s.senderAckRatio.AckRatio()), fb)
+		}
+		s.senderTimeout.Restart(fb.Time, s.senderWindow.Pipe() > 0)
+	}
+	s.wakeup()
+
+	return nil
+}
+
+// OnSlowReceiver holds cwnd for one round-trip time and exits slow-start, RFC 4341 Section 5.2
+func (s *sender) OnSlowReceiver(now int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.open {
+		return
+	}
+	rtt, _ := s.senderTimeout.RTT()
+	s.slowUntil = now + rtt
+	s.senderWindow.ExitSlowStart()
+	s.amb.E(dccp.EventInfo, fmt.Sprintf("Slow receiver, cwnd=%d, ssthresh=%d",
+		s.senderWindow.Cwnd(), s.senderWindow.SSThresh()))
+}
+
+// onCongestion registers the loss or marking of data packet p with the congestion window
+func (s *sender) onCongestion(p *sentPacket, why string, fb *dccp.FeedbackHeader) {
+	s.AssertLocked()
+	if s.senderWindow.OnCongestion(p.SeqNo, s.gss) {
+		s.amb.E(dccp.EventInfo, fmt.Sprintf("Congestion event, %s=%d, recover=%d, cwnd=%d, ssthresh=%d",
+			why, p.SeqNo, s.gss, s.senderWindow.Cwnd(), s.senderWindow.SSThresh()), fb)
+	}
+}
+
+// Strobe blocks until a new packet can be sent without violating the congestion
+// window. If the CC is not active, Strobe MUST return immediately. Only data packets
+// count against the window, RFC 4341 Section 5, so packets of other types pass at once.
+func (s *sender) Strobe(Type byte) {
+	if Type != dccp.Data && Type != dccp.DataAck {
+		return
+	}
+	for {
+		s.Lock()
+		if !s.open || s.senderWindow.CanSend() {
+			s.Unlock()
+			return
+		}
+		wake := s.wake
+		s.Unlock()
+		<-wake
+	}
+}
+
+// OnIdle is called periodically. If the CC is not active, OnIdle MUST to return nil.
+func (s *sender) OnIdle(now int64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.open {
+		return nil
+	}
+
+	if s.senderTimeout.IsExpired(now) {
+		s.senderWindow.OnTimeout(s.gss)
+		s.senderHistory.LoseAll()
+		s.senderHistory.Prune()
+		s.senderTimeout.OnTimeout()
+		s.amb.E(dccp.EventInfo, fmt.Sprintf("Transmit timeout, ssthresh=%d, RTO=%s",
+			s.senderWindow.SSThresh(), dccp.Nstoa(s.senderTimeout.RTO())))
+		s.wakeup()
+	}
+
+	return nil
+}
+
+// SetHeartbeat advices the CCID of the desired frequency of heartbeat packets.  A heartbeat
+// interval value of zero indicates that no heartbeat is needed.
+func (s *sender) SetHeartbeat(interval int64) {
+	s.Lock()
+	defer s.Unlock()
+	s.heartbeat = interval
+}
+
+// Close terminates the half-connection congestion control when it is not needed any longer
+func (s *sender) Close() {
+	s.Lock()
+	defer s.Unlock()
+	s.open = false
+	s.wakeup()
+}
@@ -0,0 +1,41 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"testing"
+	"time"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// TestStrobeNonData checks that a full congestion window holds back data packets, but not
+// Acks and other packets without data
+func TestStrobeNonData(t *testing.T) {
+	env := dccp.NewEnv(nil)
+	s := newSender(env, dccp.NewAmb("sender", env))
+	s.Open()
+	s.Lock()
+	for s.senderWindow.CanSend() {
+		s.senderWindow.OnDataSent()
+	}
+	s.Unlock()
+
+	for _, Type := range []byte{dccp.Ack, dccp.Sync, dccp.SyncAck, dccp.Close, dccp.Reset} {
+		s.Strobe(Type)
+	}
+
+	done := make(chan int)
+	go func() {
+		s.Strobe(dccp.Data)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("data packet passed a full window")
+	case <-time.After(100 * time.Millisecond):
+	}
+	s.Close()
+	<-done
+}
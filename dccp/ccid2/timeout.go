@@ -0,0 +1,122 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderTimeout maintains the round-trip time estimate and the transmit timer of the
+// CCID 2 sender. It follows the TCP algorithms of RFC 2988, as required by RFC 4341,
+// Section 5, except that no minimum timeout of one second is enforced.
+type senderTimeout struct {
+	srtt   int64 // Smoothed round-trip time, or zero if no sample has been taken
+	rttvar int64 // Round-trip time variation
+	rto    int64 // Current retransmission timeout, including back-off
+
+	expire int64 // Time when the transmit timer expires, or zero if the timer is not armed
+
+	sampleSeqNo int64 // Sequence number of the data packet being timed
+	sampling    bool  // Whether a data packet is currently being timed
+}
+
+const (
+	TimeoutInitial = 3e9  // Timeout before any RTT sample is available, 3 sec
+	TimeoutMin     = 2e8  // Lower bound on the timeout, 200 ms
+	TimeoutMax     = 64e9 // Upper bound on the timeout, including back-off, 64 sec
+	TimeoutGranularity = dccp.RoundtripMin // Clock granularity G in RFC 2988
+)
+
+// Init resets the senderTimeout for new use
+func (t *senderTimeout) Init() {
+	t.srtt = 0
+	t.rttvar = 0
+	t.rto = TimeoutInitial
+	t.expire = 0
+	t.sampleSeqNo = 0
+	t.sampling = false
+}
+
+// RTT returns the current round-trip time estimate, or dccp.RoundtripDefault if no samples
+// have been taken yet. estimated is set if the RTT is based on sample data.
+func (t *senderTimeout) RTT() (rtt int64, estimated bool) {
+	if t.srtt <= 0 {
+		return dccp.RoundtripDefault, false
+	}
+	return t.srtt, true
+}
+
+// RTO returns the current retransmission timeout
+func (t *senderTimeout) RTO() int64 { return t.rto }
+
+// OnDataSent is called each time a data packet is sent. The RTT is estimated at most once
+// per window of data, so a new packet is timed only if no other is being timed.
+func (t *senderTimeout) OnDataSent(seqNo int64, now int64) {
+	if !t.sampling {
+		t.sampleSeqNo, t.sampling = seqNo, true
+	}
+	if t.expire == 0 {
+		t.expire = now + t.rto
+	}
+}
+
+// OnDataAcked is called for each newly acknowledged data packet p. It updates the RTT
+// estimate if p is the packet being timed. OnDataAcked returns true if the estimate has
+// changed.
+func (t *senderTimeout) OnDataAcked(p *sentPacket, now int64) bool {
+	if !t.sampling || p.SeqNo != t.sampleSeqNo {
+		return false
+	}
+	t.sampling = false
+	r := now - p.Time
+	if r <= 0 {
+		return false
+	}
+	if t.srtt == 0 {
+		t.srtt = r
+		t.rttvar = r / 2
+	} else {
+		d := t.srtt - r
+		if d < 0 {
+			d = -d
+		}
+		t.rttvar = (3*t.rttvar + d) / 4
+		t.srtt = (7*t.srtt + r) / 8
+	}
+	t.rto = t.srtt + max64(TimeoutGranularity, 4*t.rttvar)
+	t.rto = min64(TimeoutMax, max64(TimeoutMin, t.rto))
+	return true
+}
+
+// OnDataLost is called for each data packet that is declared lost. Karn's algorithm
+// excludes lost packets from the RTT estimate.
+func (t *senderTimeout) OnDataLost(p *sentPacket) {
+	if t.sampling && p.SeqNo == t.sampleSeqNo {
+		t.sampling = false
+	}
+}
+
+// Restart re-arms the transmit timer after an acknowledgement of new data, if data is
+// still outstanding, or disarms it otherwise.
+func (t *senderTimeout) Restart(now int64, outstanding bool) {
+	if outstanding {
+		t.expire = now + t.rto
+	} else {
+		t.expire = 0
+	}
+}
+
+// IsExpired returns true if the transmit timer is armed and has expired
+func (t *senderTimeout) IsExpired(now int64) bool {
+	return t.expire > 0 && now >= t.expire
+}
+
+// OnTimeout backs off the timer exponentially and disarms it. The timer is re-armed with
+// the next data packet sent.
+func (t *senderTimeout) OnTimeout() {
+	t.rto = min64(TimeoutMax, 2*t.rto)
+	t.expire = 0
+	t.sampling = false
+}
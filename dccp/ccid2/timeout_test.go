@@ -0,0 +1,61 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"testing"
+)
+
+// TestTimeout checks the RTT estimate, the transmit timer and its exponential back-off
+func TestTimeout(t *testing.T) {
+	var s senderTimeout
+	s.Init()
+	if s.RTO() != TimeoutInitial || s.IsExpired(1e12) {
+		t.Fatalf("initial RTO %d, expired=%v", s.RTO(), s.IsExpired(1e12))
+	}
+
+	// The first packet sent is timed and arms the timer
+	s.OnDataSent(5, 1e9)
+	s.OnDataSent(6, 1e9)
+	if s.IsExpired(1e9+TimeoutInitial-1) || !s.IsExpired(1e9+TimeoutInitial) {
+		t.Errorf("timer does not expire after the initial RTO")
+	}
+	if s.OnDataAcked(&sentPacket{SeqNo: 6, Time: 1e9}, 11e8) {
+		t.Errorf("untimed packet updated the RTT")
+	}
+	if !s.OnDataAcked(&sentPacket{SeqNo: 5, Time: 1e9}, 11e8) {
+		t.Fatalf("timed packet did not update the RTT")
+	}
+	if rtt, est := s.RTT(); rtt != 1e8 || !est {
+		t.Errorf("expecting RTT 1e8, got %d (%v)", rtt, est)
+	}
+	// RTO = SRTT + 4*RTTVAR = 1e8 + 4*5e7
+	if s.RTO() != 3e8 {
+		t.Errorf("expecting RTO 3e8, got %d", s.RTO())
+	}
+	s.Restart(2e9, true)
+	if !s.IsExpired(2e9 + 3e8) {
+		t.Errorf("restarted timer does not expire after RTO")
+	}
+
+	// Each timeout doubles the RTO, up to TimeoutMax, and disarms the timer
+	s.OnTimeout()
+	if s.RTO() != 6e8 || s.IsExpired(1e12) {
+		t.Errorf("after timeout: RTO %d, expired=%v", s.RTO(), s.IsExpired(1e12))
+	}
+	for i := 0; i < 10; i++ {
+		s.OnTimeout()
+	}
+	if s.RTO() != TimeoutMax {
+		t.Errorf("expecting RTO to be capped at %d, got %d", int64(TimeoutMax), s.RTO())
+	}
+
+	// Karn's algorithm: a lost packet is not timed
+	s.OnDataSent(7, 3e9)
+	s.OnDataLost(&sentPacket{SeqNo: 7, Time: 3e9})
+	if s.OnDataAcked(&sentPacket{SeqNo: 7, Time: 3e9}, 4e9) {
+		t.Errorf("lost packet updated the RTT")
+	}
+}
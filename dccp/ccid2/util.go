@@ -0,0 +1,21 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+// Some basic utility functions below
+
+func min64(x, y int64) int64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func max64(x, y int64) int64 {
+	if x > y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,140 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderWindow maintains the three integer parameters of a CCID 2 sender: the congestion
+// window cwnd, the slow-start threshold ssthresh and the pipe, all measured in packets.
+// Its logic is described in RFC 4341, Section 5.
+type senderWindow struct {
+	cwnd     int64 // Maximum number of data packets allowed in the network
+	ssthresh int64 // Slow-start threshold
+	pipe     int64 // Estimate of the number of data packets outstanding in the network
+
+	ssAcked  int64 // Newly acknowledged data packets not yet accounted for in slow-start
+	caAcked  int64 // Data packets acknowledged since the last increase in congestion avoidance
+
+	recoverSeqNo int64 // Greatest sequence number sent when the last congestion event was detected
+	recoverSet   bool  // Whether recoverSeqNo is valid
+}
+
+const (
+	// InitialWindow is the initial value of cwnd, following RFC 3390 for a 1460-byte MSS
+	InitialWindow = 3
+
+	// InitialSSThresh is the initial slow-start threshold, an arbitrarily high value
+	InitialSSThresh = 1 << 30
+
+	// MinSSThresh is the smallest value ssthresh can assume after a reduction
+	MinSSThresh = 2
+)
+
+// Init resets the senderWindow for new use
+func (w *senderWindow) Init() {
+	w.cwnd = InitialWindow
+	w.ssthresh = InitialSSThresh
+	w.pipe = 0
+	w.ssAcked = 0
+	w.caAcked = 0
+	w.recoverSeqNo = 0
+	w.recoverSet = false
+}
+
+// CanSend returns true if a new data packet can be sent without violating the window
+func (w *senderWindow) CanSend() bool {
+	return w.pipe < w.cwnd
+}
+
+// Cwnd returns the current congestion window in packets
+func (w *senderWindow) Cwnd() int64 { return w.cwnd }
+
+// SSThresh returns the current slow-start threshold in packets
+func (w *senderWindow) SSThresh() int64 { return w.ssthresh }
+
+// Pipe returns the current number of data packets believed to be in the network
+func (w *senderWindow) Pipe() int64 { return w.pipe }
+
+// InSlowStart returns true if the sender is in slow-start
+func (w *senderWindow) InSlowStart() bool { return w.cwnd < w.ssthresh }
+
+// OnDataSent is called each time a data packet is sent
+func (w *senderWindow) OnDataSent() {
+	w.pipe++
+}
+
+// OnDataLeft is called each time a data packet is inferred to have left the network, either
+// by being acknowledged or by being declared lost. It must be called at most once per packet.
+func (w *senderWindow) OnDataLeft() {
+	if w.pipe > 0 {
+		w.pipe--
+	}
+}
+
+// OnAcked adjusts cwnd in response to a single acknowledgement that newly acknowledges
+// acked unmarked data packets. ackRatio is the Ack Ratio currently in effect.
+func (w *senderWindow) OnAcked(acked int64, ackRatio int64) {
+	if acked <= 0 {
+		return
+	}
+	if w.InSlowStart() {
+		// Increase cwnd by one packet for every two newly acknowledged data packets,
+		// up to a maximum of Ack Ratio/2 packets per acknowledgement
+		w.ssAcked += acked
+		inc := w.ssAcked / 2
+		w.ssAcked %= 2
+		inc = min64(inc, max64(1, ackRatio/2))
+		w.cwnd += inc
+		return
+	}
+	// In congestion avoidance, increase cwnd by one packet for every window of data
+	// acknowledged without lost or marked packets
+	w.caAcked += acked
+	if w.caAcked >= w.cwnd {
+		w.caAcked -= w.cwnd
+		w.cwnd++
+	}
+}
+
+// OnCongestion is invoked when the packet with sequence number seqNo is detected as lost or
+// marked. gss is the greatest sequence number sent so far. Losses and marks of packets sent
+// before the previous congestion event was detected belong to that same event and are
+// ignored. OnCongestion returns true if a new congestion event has been registered.
+func (w *senderWindow) OnCongestion(seqNo, gss int64) bool {
+	if w.recoverSet && dccp.SeqLessEq(seqNo, w.recoverSeqNo) {
+		return false
+	}
+	w.recoverSeqNo, w.recoverSet = gss, true
+	w.cwnd = max64(1, w.cwnd/2)
+	w.ssthresh = max64(MinSSThresh, w.cwnd)
+	w.ssAcked, w.caAcked = 0, 0
+	return true
+}
+
+// OnReceiveBufferDrop is invoked when n data packets are newly acknowledged as dropped in
+// the receive buffer. cwnd is reduced by one for each such packet, but never below one, and
+// slow-start is exited. See RFC 4341, Section 5.2.
+func (w *senderWindow) OnReceiveBufferDrop(n int64) {
+	w.cwnd = max64(1, w.cwnd-n)
+	w.ExitSlowStart()
+}
+
+// ExitSlowStart lowers ssthresh to cwnd, so that cwnd grows by congestion avoidance from now on
+func (w *senderWindow) ExitSlowStart() {
+	w.ssthresh = min64(w.ssthresh, max64(MinSSThresh, w.cwnd))
+	w.ssAcked, w.caAcked = 0, 0
+}
+
+// OnTimeout is invoked when the transmit timer expires. gss is the greatest sequence
+// number sent so far.
+func (w *senderWindow) OnTimeout(gss int64) {
+	w.ssthresh = max64(MinSSThresh, w.cwnd/2)
+	w.cwnd = 1
+	w.pipe = 0
+	w.ssAcked, w.caAcked = 0, 0
+	w.recoverSeqNo, w.recoverSet = gss, true
+}
@@ -0,0 +1,108 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid2
+
+import (
+	"testing"
+)
+
+// TestWindowGrowth checks that cwnd grows by one packet per two acknowledged packets in
+// slow-start, limited by the Ack Ratio, and by one packet per window in congestion avoidance
+func TestWindowGrowth(t *testing.T) {
+	var w senderWindow
+	w.Init()
+	if w.Cwnd() != InitialWindow || !w.InSlowStart() {
+		t.Fatalf("initial cwnd=%d, slow-start=%v", w.Cwnd(), w.InSlowStart())
+	}
+	steps := []struct{ acked, ackRatio, cwnd int64 }{
+		{2, 2, 4},
+		{1, 2, 4}, // Half a packet is carried over
+		{1, 2, 5},
+		{4, 4, 7},  // An Ack Ratio of 4 allows an increase of 2 per ack
+		{10, 2, 8}, // An Ack Ratio of 2 limits the increase to 1 per ack
+	}
+	for i, s := range steps {
+		w.OnAcked(s.acked, s.ackRatio)
+		if w.Cwnd() != s.cwnd {
+			t.Errorf("slow-start step %d: expecting cwnd %d, got %d", i, s.cwnd, w.Cwnd())
+		}
+	}
+
+	w.ExitSlowStart()
+	if w.InSlowStart() || w.SSThresh() != 8 {
+		t.Fatalf("exiting slow-start leaves ssthresh=%d", w.SSThresh())
+	}
+	w.OnAcked(7, 2)
+	if w.Cwnd() != 8 {
+		t.Errorf("cwnd grew to %d before a window was acknowledged", w.Cwnd())
+	}
+	w.OnAcked(1, 2)
+	if w.Cwnd() != 9 {
+		t.Errorf("expecting cwnd 9 after a window, got %d", w.Cwnd())
+	}
+	for i := 0; i < 9; i++ {
+		w.OnAcked(1, 2)
+	}
+	if w.Cwnd() != 10 {
+		t.Errorf("expecting cwnd 10 after another window, got %d", w.Cwnd())
+	}
+}
+
+// TestWindowCongestion checks that a congestion event halves cwnd once per window of data, and
+// that a timeout collapses cwnd to one packet
+func TestWindowCongestion(t *testing.T) {
+	var w senderWindow
+	w.Init()
+	for i := 0; i < 5; i++ {
+		w.OnAcked(2, 2)
+	}
+	if w.Cwnd() != 8 {
+		t.Fatalf("expecting cwnd 8, got %d", w.Cwnd())
+	}
+
+	if !w.OnCongestion(10, 20) {
+		t.Errorf("loss not registered as a congestion event")
+	}
+	if w.Cwnd() != 4 || w.SSThresh() != 4 || w.InSlowStart() {
+		t.Errorf("after loss: cwnd=%d, ssthresh=%d", w.Cwnd(), w.SSThresh())
+	}
+	// Losses of packets sent before the event was detected belong to the same event
+	if w.OnCongestion(15, 25) || w.Cwnd() != 4 {
+		t.Errorf("second loss in a window reduced cwnd to %d", w.Cwnd())
+	}
+	if !w.OnCongestion(21, 30) || w.Cwnd() != 2 || w.SSThresh() != MinSSThresh {
+		t.Errorf("after new loss: cwnd=%d, ssthresh=%d", w.Cwnd(), w.SSThresh())
+	}
+	w.OnCongestion(31, 40)
+	w.OnCongestion(41, 50)
+	if w.Cwnd() != 1 || w.SSThresh() != MinSSThresh {
+		t.Errorf("repeated losses: cwnd=%d, ssthresh=%d", w.Cwnd(), w.SSThresh())
+	}
+
+	// Pipe limits sending
+	w.Init()
+	for i := 0; i < InitialWindow; i++ {
+		w.OnDataSent()
+	}
+	if w.CanSend() || w.Pipe() != InitialWindow {
+		t.Errorf("full window allows sending, pipe=%d", w.Pipe())
+	}
+	w.OnDataLeft()
+	if !w.CanSend() {
+		t.Errorf("window does not allow sending after a packet left")
+	}
+
+	for i := 0; i < 10; i++ {
+		w.OnAcked(2, 2)
+	}
+	w.OnTimeout(60)
+	if w.Cwnd() != 1 || w.SSThresh() != 6 || w.Pipe() != 0 {
+		t.Errorf("after timeout: cwnd=%d, ssthresh=%d, pipe=%d", w.Cwnd(), w.SSThresh(), w.Pipe())
+	}
+	// Packets sent before the timeout do not cause another reduction
+	if w.OnCongestion(55, 70) {
+		t.Errorf("loss of a packet sent before the timeout registered")
+	}
+}
@@ -10,10 +10,49 @@ import (
 
 type CCID3 struct {}
 
-func (CCID3) NewSender(env *dccp.Env, amb *dccp.Amb) dccp.SenderCongestionControl { 
-	return newSender(env, amb)
+func (CCID3) GetID() byte { return dccp.CCID3 }
+
+func (CCID3) NewSender(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.SenderCongestionControl { 
+	return newSender(env, amb, configFromArgs(args))
+}
+
+func (CCID3) NewReceiver(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.ReceiverCongestionControl { 
+	return newReceiver(env, amb, configFromArgs(args))
+}
+
+// Config selects optional behaviours of CCID3. A *Config among the CCIDArgs of a dccp.Config
+// applies to the connections that are dialed or accepted with it. Connections without one
+// use the zero Config.
+type Config struct {
+
+	// ReduceOscillations enables the oscillation prevention of RFC 5348, Section 4.5. The
+	// sender lowers its instantaneous sending rate while the round-trip time is above its
+	// long-term average, which keeps queues short on paths with little statistical
+	// multiplexing, where the sending rate would otherwise oscillate.
+	ReduceOscillations bool
+
+	// LossFromIntervals makes the sender compute the loss event rate itself from the Loss
+	// Intervals option, as allowed by RFC 4342, Section 8.6, instead of using the rate that
+	// the receiver reports in its Loss Event Rate option. The reported rate is then only
+	// checked against the computed one, so that a receiver misreporting loss is detected.
+	// Without it, the computed rate is used only when the Loss Event Rate option is missing.
+	LossFromIntervals bool
+
+	// StrictRFC4342 restricts both ends to the options of RFC 4342, so that they interoperate
+	// with other CCID3 implementations. The sender does not send the private Roundtrip Report
+	// option, and the receiver estimates the RTT from the window counters of the packets it
+	// receives, instead of reading it from that option. The receiver sends only the Elapsed
+	// Time, Receive Rate, Loss Intervals and Loss Event Rate options in either mode, and the
+	// sender takes its RTT from the Elapsed Time option.
+	StrictRFC4342 bool
 }
 
-func (CCID3) NewReceiver(env *dccp.Env, amb *dccp.Amb) dccp.ReceiverCongestionControl { 
-	return newReceiver(env, amb)
+// configFromArgs returns the first *Config in args, or the zero Config if there is none
+func configFromArgs(args []interface{}) *Config {
+	for _, a := range args {
+		if cfg, ok := a.(*Config); ok && cfg != nil {
+			return cfg
+		}
+	}
+	return &Config{}
 }
@@ -0,0 +1,95 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderDataLimit detects data-limited intervals, during which the application did not
+// offer enough data to send at the allowed rate, as described in RFC 5348, Section 8.2.1.
+//
+// The sender is not data-limited when a data packet has to wait for the strober, since the
+// application has data ready sooner than the allowed rate permits sending it. A data packet
+// that follows a non-data packet may wait only because the latter took its strobe, which says
+// nothing about the data ready to send, so such waits are not counted. A feedback packet
+// covers the interval of one RTT that ends with the send time of the packet it acknowledges.
+// The interval was data-limited if no data packet sent within it had to wait. Two times at
+// which the sender was not data-limited are remembered, as in the RFC pseudocode:
+//
+//   After sending a segment:
+//     If (sender has sent all it is allowed to send) {
+//       If NotLimited1 <= t_new
+//         NotLimited1 = t_now;
+//       Else if (NotLimited2 <= t_next)
+//         NotLimited2 = t_now;
+//     }
+//
+//   When a feedback packet is received:
+//     t_new = send time of the acknowledged packet;
+//     t_old = t_new - R;
+//     t_next = t_now;
+//     If ((t_old < NotLimited1 <= t_new) Or (t_old < NotLimited2 <= t_new))
+//       The interval was not data-limited;
+//     Else
+//       The interval was data-limited;
+//     If (NotLimited1 <= t_new And NotLimited2 > t_new)
+//       NotLimited1 = NotLimited2;
+//
+type senderDataLimit struct {
+	waited      bool  // True if the packet about to be sent has waited for the strober
+	data        bool  // True if the last packet sent was a data packet
+	notLimited1 int64 // Send time of a packet when the sender was not data-limited; ns since UTC zero
+	notLimited2 int64 // A later such send time; ns since UTC zero
+	tNew        int64 // Send time of the packet acknowledged by the latest feedback; ns since UTC zero
+	tNext       int64 // Time the latest feedback was received; ns since UTC zero
+}
+
+// Init resets the object for new use
+func (t *senderDataLimit) Init() {
+	t.waited = false
+	t.data = false
+	t.notLimited1 = 0
+	t.notLimited2 = 0
+	t.tNew = 0
+	t.tNext = 0
+}
+
+// Sender calls OnStrobe after each strobe, which precedes the sending of a packet. waited is
+// true if the strobe had to wait.
+func (t *senderDataLimit) OnStrobe(waited bool) {
+	t.waited = waited
+}
+
+// Sender calls OnWrite for every packet sent
+func (t *senderDataLimit) OnWrite(ph *dccp.PreHeader) {
+	waited, follows := t.waited, t.data
+	t.waited = false
+	t.data = ph.Type == dccp.Data || ph.Type == dccp.DataAck
+	if !t.data || !waited || !follows {
+		return
+	}
+	if t.notLimited1 <= t.tNew {
+		t.notLimited1 = ph.TimeWrite
+	} else if t.notLimited2 <= t.tNext {
+		t.notLimited2 = ph.TimeWrite
+	}
+}
+
+// Sender calls OnRead for every feedback packet. now is the time the feedback packet was
+// received, ackTime is the send time of the packet it acknowledges and rtt is the current
+// round-trip time estimate. OnRead returns true if the interval covered by the feedback
+// packet was data-limited.
+func (t *senderDataLimit) OnRead(now, ackTime, rtt int64) bool {
+	t.tNew = ackTime
+	tOld := t.tNew - rtt
+	t.tNext = now
+	limited := !((tOld < t.notLimited1 && t.notLimited1 <= t.tNew) ||
+		(tOld < t.notLimited2 && t.notLimited2 <= t.tNew))
+	if t.notLimited1 <= t.tNew && t.notLimited2 > t.tNew {
+		t.notLimited1 = t.notLimited2
+	}
+	return limited
+}
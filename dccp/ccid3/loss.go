@@ -16,6 +16,7 @@ func LossSample(series string, lossRateInv uint32) dccp.Sample {
 
 const (
 	LossReceiverEstimateSample = "Loss-Receiver"
+	LossSenderEstimateSample   = "Loss-Sender"
 )
 
 // lossRateCalculator calculates the inverse of the loss event rate as
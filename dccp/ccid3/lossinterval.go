@@ -20,6 +20,9 @@ type evolveInterval struct {
 
 	// --- Last received packet state
 
+	// lastSeqNoPresent is true if at least one packet has been received
+	lastSeqNoPresent bool
+
 	// lastSeqNo is the sequence number of the last successfuly received packet
 	lastSeqNo   int64
 
@@ -70,6 +73,7 @@ type pushIntervalFunc func(*LossIntervalDetail)
 func (t *evolveInterval) Init(amb *dccp.Amb, push pushIntervalFunc) {
 	t.amb = amb.Refine("evolveInterval")
 	t.push = push
+	t.lastSeqNoPresent = false
 	t.lastSeqNo = 0
 	t.lastTime = 0
 	t.lastRTT = 0
@@ -88,12 +92,15 @@ func (t *evolveInterval) clearInterval() {
 // OnRead is called after best effort has been made to fix packet 
 // reordering. This function performs tha main loss interval construction logic.
 //
-// TODO: Account for non-data packets
+// Lost packets that the NDP Count of ff reports as non-data packets are not counted as lost.
+// Packets marked Congestion Experienced are counted as lost.
+//
+// TODO: Account for non-data packets in the Data Length of loss intervals
 func (t *evolveInterval) OnRead(ff *dccp.FeedforwardHeader, rtt int64) {
 
 	// If sequence number re-ordering present, packet is not considered here, because it was
 	// already counted as a lost packet when t.lastSeqNo was considered
-	if ff.SeqNo <= t.lastSeqNo {
+	if t.lastSeqNoPresent && dccp.SeqLessEq(ff.SeqNo, t.lastSeqNo) {
 		return
 	}
 	// Packet re-ordering may also occur if a packet is received with a timestamp smaller than
@@ -106,23 +113,33 @@ func (t *evolveInterval) OnRead(ff *dccp.FeedforwardHeader, rtt int64) {
 		return
 	}
 
+	// A packet marked Congestion Experienced is treated as lost, RFC 4342 Section 6.1. It is
+	// counted among the lost packets preceding the next unmarked packet.
+	if ff.ECN == dccp.ECNCE {
+		return
+	}
+
 	// Keep a separate count of non-Data packets
 	if ff.Type != dccp.Data && ff.Type != dccp.DataAck {
 		t.nonDataLen++
 	}
 
-	// Number of lost packets between this and the last received packets
-	nlost := int(ff.SeqNo - t.lastSeqNo) - 1
+	// Number of lost packets between this and the last received packets. The last NDPCount
+	// packets before ff were non-data packets, RFC 4340 Section 7.7, so only the packets
+	// before them may have carried application data.
+	nlost := int(dccp.SeqDist(t.lastSeqNo, ff.SeqNo)) - 1
+	nlost -= int(min64(int64(nlost), int64(ff.NDPCount)))
 	lastTime := t.lastTime
-	lastSeqNo := t.lastSeqNo
+	lastSeqNo, lastSeqNoPresent := t.lastSeqNo, t.lastSeqNoPresent
 
 	// Update last received event
+	t.lastSeqNoPresent = true
 	t.lastSeqNo = ff.SeqNo
 	t.lastTime = ff.Time
 	t.lastRTT = rtt
 
 	// Only perform updates after the second packet ever received
-	if lastSeqNo > 0 {
+	if lastSeqNoPresent {
 
 		// Prepare tail between previous receive and this one
 		t._tail.Init(lastTime, ff.Time, nlost, lastSeqNo)
@@ -274,7 +291,7 @@ func (t *eventTail) GetLossEvent(k int64) (lossTime int64, lossSeqNo int64) {
 	if k <= 0 {
 		return -1, -1
 	}
-	return t.prevTime + k*t.gap, t.prevSeqNo + k
+	return t.prevTime + k*t.gap, dccp.SeqAdd(t.prevSeqNo, k)
 }
 
 // LatestLoss returns the identity of the latest loss that occurred BEFORE-or-ON
@@ -302,7 +319,7 @@ func (t *eventTail) Chop(k int) {
 	if k > t.nlost {
 		panic("chopping more than available")
 	}
-	t.prevSeqNo += int64(k)
+	t.prevSeqNo = dccp.SeqAdd(t.prevSeqNo, int64(k))
 	t.prevTime += int64(k)*t.gap
 	t.nlost -= k
 }
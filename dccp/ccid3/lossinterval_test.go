@@ -0,0 +1,72 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// TestNDPCountLoss checks that sequence number gaps, which the NDP Count reports as lost
+// non-data packets, do not start loss intervals
+func TestNDPCountLoss(t *testing.T) {
+	var ev evolveInterval
+	ev.Init(dccp.NoLogging, func(*LossIntervalDetail) {})
+	const rtt = 1e8
+	read := func(seqNo int64, ndpCount uint64) {
+		ev.OnRead(&dccp.FeedforwardHeader{Type: dccp.Data, X: true, SeqNo: seqNo, Time: seqNo * 1e6, NDPCount: ndpCount}, rtt)
+	}
+	read(100, 0)
+	read(101, 0)
+	// Packets 102 and 103 were non-data packets
+	read(104, 2)
+	if ev.lossLen != 0 {
+		t.Errorf("lost non-data packets counted as loss")
+	}
+	// Packets 105 to 107 are lost, but only 107 is a non-data packet
+	read(108, 1)
+	if ev.lossLen != 2 {
+		t.Errorf("expecting loss length 2, got %d", ev.lossLen)
+	}
+}
+
+// TestLossWrap checks that loss intervals are constructed, and recovered at the sender, across
+// the wrap of the sequence number space
+func TestLossWrap(t *testing.T) {
+	var ev evolveInterval
+	var finished []*LossIntervalDetail
+	ev.Init(dccp.NoLogging, func(lid *LossIntervalDetail) { finished = append(finished, lid) })
+	const rtt = 1e8
+	var now int64
+	read := func(seqNo int64) {
+		now += 1e6
+		ev.OnRead(&dccp.FeedforwardHeader{Type: dccp.Data, X: true, SeqNo: seqNo, Time: now}, rtt)
+	}
+	read(dccp.SEQNOMAX - 1)
+	read(dccp.SEQNOMAX)
+	// Packets 0 and 1 are lost
+	read(2)
+	read(3)
+	if ev.lossLen != 2 || ev.startSeqNo != 0 {
+		t.Fatalf("expecting loss length 2 from 0, got %d from %012x", ev.lossLen, ev.startSeqNo)
+	}
+	// A late packet from before the wrap is not counted
+	read(dccp.SEQNOMAX)
+	if ev.lossLen != 2 || ev.losslessLen != 2 {
+		t.Errorf("late packet changed interval to %d lost, %d lossless", ev.lossLen, ev.losslessLen)
+	}
+
+	// The sender recovers the start of the interval from the acknowledgement of packet 3
+	details := recoverIntervalDetails(3, 0, []*LossInterval{&ev.Unfinished().LossInterval})
+	if details[0].StartSeqNo != 0 {
+		t.Errorf("sender recovered interval start %012x", details[0].StartSeqNo)
+	}
+	if n := calcNewLossCount(details, true, dccp.SEQNOMAX); n != 1 {
+		t.Errorf("expecting 1 new loss interval, got %d", n)
+	}
+	if n := calcNewLossCount(details, true, 1); n != 0 {
+		t.Errorf("expecting no new loss intervals, got %d", n)
+	}
+}
@@ -9,14 +9,15 @@ import (
 	"github.com/petar/GoDCCP/dccp"
 )
 
-func newReceiver(env *dccp.Env, amb *dccp.Amb) *receiver {
-	return &receiver{ env: env, amb: amb.Refine("receiver") }
+func newReceiver(env *dccp.Env, amb *dccp.Amb, cfg *Config) *receiver {
+	return &receiver{ env: env, amb: amb.Refine("receiver"), config: *cfg }
 }
 
 // receiver implements CCID3 congestion control and it conforms to dccp.ReceiverCongestionControl
 type receiver struct {
-	env *dccp.Env
-	amb *dccp.Amb
+	env    *dccp.Env
+	amb    *dccp.Amb
+	config Config
 	dccp.Mutex
 	receiverRoundtripEstimator
 	receiverRateCalculator
@@ -30,6 +31,7 @@ type receiver struct {
 	lastLossEventRateInv uint32 // The inverse loss event rate sent in the last Ack packet
 
 	// The following fields are used to compute ElapsedTime options
+	gsrPresent   bool  // True if at least one packet has been received via OnRead
 	gsr          int64 // Greatest sequence number of packet received via OnRead
 	gsrTimestamp int64 // Timestamp of packet with greatest sequence number received via OnRead
 
@@ -53,7 +55,7 @@ func (r *receiver) Open() {
 		panic("opening an open ccid3 receiver")
 	}
 
-	r.receiverRoundtripEstimator.Init(r.amb)
+	r.receiverRoundtripEstimator.Init(r.amb, r.config.StrictRFC4342)
 	r.receiverRateCalculator.Init()
 	r.receiverLossTracker.Init(r.amb)
 	r.open = true
@@ -62,6 +64,7 @@ func (r *receiver) Open() {
 	r.dataSinceAck = false
 	r.lastLossEventRateInv = UnknownLossEventRateInv
 
+	r.gsrPresent = false
 	r.gsr = 0
 	r.gsrTimestamp = 0
 
@@ -72,7 +75,7 @@ func (r *receiver) Open() {
 func (r *receiver) makeElapsedTimeOption(ackNo int64, timeWrite int64) *dccp.ElapsedTimeOption {
 	// The first Ack may be sent before receiver has had a chance to see a gsr, in which
 	// case we return nil
-	if r.gsr == 0 {
+	if !r.gsrPresent {
 		return nil
 	}
 	if ackNo != r.gsr {
@@ -103,9 +106,8 @@ func (r *receiver) OnWrite(ph *dccp.PreHeader) (options []*dccp.Option) {
 		r.lastCCVal = r.latestCCVal
 
 		// Prepare feedback options, if we've seen packets before
-		// XXX: Maybe gsr = 0 should not indicate not seen packets, use something else
-		if r.gsr > 0 {
-			opts := make([]*dccp.Option, 3)
+		if r.gsrPresent {
+			opts := make([]*dccp.Option, 4)
 			opts[0] = encodeOption(r.makeElapsedTimeOption(ph.AckNo, ph.TimeWrite))
 			if opts[0] == nil {
 				r.amb.E(dccp.EventWarn, "ElapsedTime option encoding == nil", ph)
@@ -118,6 +120,10 @@ func (r *receiver) OnWrite(ph *dccp.PreHeader) (options []*dccp.Option) {
 			if opts[2] == nil {
 				r.amb.E(dccp.EventWarn, "LossIntervals option encoding == nil", ph)
 			}
+			opts[3] = encodeOption(&LossEventRateOption{RateInv: r.lastLossEventRateInv})
+			if opts[3] == nil {
+				r.amb.E(dccp.EventWarn, "LossEventRate option encoding == nil", ph)
+			}
 			r.amb.E(dccp.EventInfo, fmt.Sprintf("Placed %d receiver opts", len(opts)), ph)
 			return opts
 		}
@@ -142,8 +148,8 @@ func (r *receiver) OnRead(ff *dccp.FeedforwardHeader) error {
 		return nil
 	}
 
-	// XXX: Must use circular arithmrtic here
-	if ff.SeqNo > r.gsr {
+	if !r.gsrPresent || dccp.SeqLess(r.gsr, ff.SeqNo) {
+		r.gsrPresent = true
 		r.gsr = ff.SeqNo
 		r.gsrTimestamp = ff.Time
 	}
@@ -40,20 +40,18 @@ func (t *receiverLossTracker) Init(amb *dccp.Amb) {
 // returns potentially another header (if available) whose SeqNo is no later.
 // Every header is returned exactly once.
 func (t *receiverLossTracker) pushPopHeader(ff *dccp.FeedforwardHeader) *dccp.FeedforwardHeader {
-	var popSeqNo int64 = dccp.SEQNOMAX + 1
+	var r *dccp.FeedforwardHeader
 	var pop int
 	for i, ge := range t.pastHeaders {
 		if ge == nil {
 			t.pastHeaders[i] = ff
 			return nil
 		}
-		// XXX: This must employ circular comparison
-		if ge.SeqNo < popSeqNo {
+		if r == nil || dccp.SeqLess(ge.SeqNo, r.SeqNo) {
 			pop = i
-			popSeqNo = ge.SeqNo
+			r = ge
 		}
 	}
-	r := t.pastHeaders[pop]
 	t.pastHeaders[pop] = ff
 	return r
 }
@@ -63,11 +61,14 @@ func (t *receiverLossTracker) pushPopHeader(ff *dccp.FeedforwardHeader) *dccp.Fe
 // considered by the loss intervals logic.
 func (t *receiverLossTracker) skipLength(ackno int64) byte {
 	var skip byte
-	var dbgGSR int64 = 0
+	var dbgGSR int64 = ackno
 	for _, ge := range t.pastHeaders {
 		if ge != nil {
+			if skip == 0 {
+				dbgGSR = ge.SeqNo
+			}
 			skip++
-			dbgGSR = max64(dbgGSR, ge.SeqNo)
+			dbgGSR = dccp.SeqMax(dbgGSR, ge.SeqNo)
 		}
 	}
 	if dbgGSR != ackno {
@@ -17,6 +17,7 @@ func RoundtripSample(series string, rtt int64) dccp.Sample {
 const (
 	RoundtripElapsedSample = "RTT-Elapsed"
 	RoundtripReportSample  = "RTT-Report"
+	RoundtripWindowSample  = "RTT-Window"
 )
 
 // Checkpoint types are attached to emits to mark them so they can be singled out in test guzzles
@@ -50,6 +51,7 @@ func (t *senderRoundtripReporter) OnWrite(rtt int64, timeWrite int64) *dccp.Opti
 type senderRoundtripEstimator struct {
 	amb   *dccp.Amb
 	estimate int64
+	sample   int64					// The latest RTT sample, or zero if none
 	k        int					// The index of the next history cell to write in
 	history  [SenderRoundtripHistoryLen]sendTime	// Circular array, recording departure times of last few packets
 }
@@ -60,7 +62,7 @@ type sendTime struct {
 }
 
 const (
-	SenderRoundtripHistoryLen = 20 // How many timestamps of sent packets to remember
+	SenderRoundtripHistoryLen = 128 // How many timestamps of sent packets to remember; at least the packets in flight
 	SenderRoundtripWeightNew = 1
 	SenderRoundtripWeightOld = 9
 )
@@ -69,6 +71,7 @@ const (
 func (t *senderRoundtripEstimator) Init(amb *dccp.Amb) {
 	t.amb = amb.Refine("senderRoundtripEstimator")
 	t.estimate = 0
+	t.sample = 0
 	t.k = 0
 	for i, _ := range t.history {
 		t.history[i] = sendTime{} // Zero Time indicates no data
@@ -89,7 +92,7 @@ func (t *senderRoundtripEstimator) OnWrite(seqNo int64, timeWrite int64) {
 func (t *senderRoundtripEstimator) find(seqNo int64) *sendTime {
 	for i := 0; i < len(t.history); i++ {
 		r := &t.history[i]
-		if r.SeqNo == seqNo {
+		if r.Time != 0 && r.SeqNo == seqNo {
 			return r
 		}
 	}
@@ -130,6 +133,7 @@ func (t *senderRoundtripEstimator) OnRead(fb *dccp.FeedbackHeader) bool {
 		t.amb.E(dccp.EventWarn, "Invalid elapsed opt", fb)
 		return false
 	}
+	t.sample = est
 	est_old := t.estimate
 	if est_old == 0 {
 		t.estimate = est
@@ -153,6 +157,12 @@ func (t *senderRoundtripEstimator) RTT() (rtt int64, estimated bool) {
 	return t.estimate, true
 }
 
+// Sample returns the latest RTT sample in ns, from which the estimate is smoothed, or zero if
+// no sample has been taken
+func (t *senderRoundtripEstimator) Sample() int64 {
+	return t.sample
+}
+
 // HasRTT returns true if senderRoundtripEstimator has enough sample data for an estimate
 func (t *senderRoundtripEstimator) HasRTT() bool {
 	return t.estimate > 0
@@ -161,26 +171,49 @@ func (t *senderRoundtripEstimator) HasRTT() bool {
 // receiverRoundtripEstimator is a data structure that estimates the RTT at the receiver end.
 // Instead of using the less precise algorithm described in RFC 4342, towards the end of Section
 // 8.1, we simply record the RTT estimate calculated at the sender and communicated via an option.
+// When fromWindow is set, because the sender may not send that option, the estimate is instead
+// derived from the window counters of the received data packets, as described in RFC 4342.
 type receiverRoundtripEstimator struct {
 	amb *dccp.Amb
 
+	// fromWindow is set if the RTT is estimated from window counters, rather than reported
+	fromWindow bool
+
 	// rtt equals the latest RTT estimate, or 0 otherwise
 	rtt int64
 
 	// rttTime is the time when RTT estimate was received
 	rttTime int64
+
+	// window records the arrival times of the first packets of recent windows, in a circular array
+	k      int
+	window [WindowCounterMod]windowArrival
+}
+
+// windowArrival records the arrival time of the first data packet with a given window counter
+type windowArrival struct {
+	CCVal int8
+	Time  int64 // Time=0 indicates that the struct is nil
 }
 
 // Init initializes the RTT estimator
-func (t *receiverRoundtripEstimator) Init(amb *dccp.Amb) {
+func (t *receiverRoundtripEstimator) Init(amb *dccp.Amb, fromWindow bool) {
 	t.amb = amb.Refine("receiverRoundtripEstimator")
+	t.fromWindow = fromWindow
 	t.rtt = 0
 	t.rttTime = 0
+	t.k = 0
+	for i, _ := range t.window {
+		t.window[i] = windowArrival{}
+	}
 }
 
 // receiver calls OnRead every time a packet is received
 // OnRead returns true, if the roundtrip estimate has changed
 func (t *receiverRoundtripEstimator) OnRead(ff *dccp.FeedforwardHeader) bool {
+	if t.fromWindow {
+		return t.onReadWindow(ff)
+	}
 
 	// Read RoundtripReportOption
 	// Currently RoundtripReportOption is allowed on any packet type
@@ -210,6 +243,64 @@ func (t *receiverRoundtripEstimator) OnRead(ff *dccp.FeedforwardHeader) bool {
 	return true
 }
 
+// onReadWindow estimates the RTT from window counters, RFC 4342, Section 8.1. The sender
+// advances the window counter every quarter of an RTT, so the first packets of two windows,
+// whose counters differ by d, were sent about d/4 RTTs apart. The sample is taken across the
+// largest difference, not exceeding 4, that is at least 2.
+func (t *receiverRoundtripEstimator) onReadWindow(ff *dccp.FeedforwardHeader) bool {
+	if ff.Type != dccp.Data && ff.Type != dccp.DataAck {
+		return false
+	}
+
+	// Record the arrival of the first packet of a new window. Packets of older windows, which
+	// arrive out of order, show a difference greater than the sender may advance the counter by.
+	latest := &t.window[(t.k+WindowCounterMod-1)%WindowCounterMod]
+	if latest.Time != 0 {
+		d := diffWindowCounter(ff.CCVal, latest.CCVal)
+		if d == 0 || d > WindowCounterMaxInc {
+			return false
+		}
+	}
+	t.window[t.k] = windowArrival{ff.CCVal, ff.Time}
+	t.k = (t.k + 1) % WindowCounterMod
+
+	// Walk back to the earliest window, whose counter is at most 4 behind
+	var d, dEarliest int8
+	var tEarliest int64
+	for i := 2; i <= WindowCounterMod; i++ {
+		w := &t.window[(t.k+WindowCounterMod-i)%WindowCounterMod]
+		next := &t.window[(t.k+WindowCounterMod-i+1)%WindowCounterMod]
+		if w.Time == 0 {
+			break
+		}
+		if d += diffWindowCounter(next.CCVal, w.CCVal); d > 4 {
+			break
+		}
+		dEarliest, tEarliest = d, w.Time
+	}
+	if dEarliest < 2 {
+		return false
+	}
+	est := (ff.Time - tEarliest) * 4 / int64(dEarliest)
+	if est <= 0 || est > 30e9 {
+		t.amb.E(dccp.EventWarn, "Invalid window counter RTT sample", ff)
+		return false
+	}
+
+	// Update RTT estimate
+	if t.rtt == 0 {
+		t.rtt = est
+	} else {
+		t.rtt = (est * SenderRoundtripWeightNew + t.rtt * SenderRoundtripWeightOld) / 
+			(SenderRoundtripWeightNew + SenderRoundtripWeightOld)
+	}
+	t.rttTime = ff.Time
+	t.amb.E(dccp.EventMatch, fmt.Sprintf("Window —> RTT=%s", dccp.Nstoa(t.rtt)), ff, 
+		RoundtripSample(RoundtripWindowSample, t.rtt))
+
+	return true
+}
+
 // RTT returns the best available estimate of the round-trip time
 func (t *receiverRoundtripEstimator) RTT(now int64) (rtt int64, estimated bool) {
 	if t.rtt != 0 &&  now - t.rttTime < 1e9 {
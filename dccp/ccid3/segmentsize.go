@@ -4,31 +4,84 @@
 
 package ccid3
 
-// senderSegmentSize keeps an up-to-date estimate of the Segment Size (SS).
-// The current implementation simply uses the MPS (maximum packet size) as SS.
-// TODO: Compute SS as the average SS over a few most recent loss intervals, see Section 5.3.
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// senderSegmentSize keeps an up-to-date estimate of the Segment Size (SS), the size of the
+// application data in a packet, which the sender uses in the throughput equation and to set
+// its sending rate. Applications send packets of varying sizes, so SS is the mean size of the
+// Data and DataAck packets sent over the last SSLossIntervals loss intervals, as suggested in
+// RFC 5348, Section 4.1. Until the first data packet is sent, SS is the largest size that
+// fits in the Congestion Control Maximum Packet Size (CCMPS).
+//
+// CCID 3 does not limit the size of packets beyond the Path MTU, so its CCMPS equals the
+// PMTU. The largest segment size leaves room for the headers and options of a data packet.
 type senderSegmentSize struct {
-	mps int
+	ccmps    int32                          // Congestion Control Maximum Packet Size
+	overhead int32                          // Size of the headers and options of a data packet
+	k        int                            // Index of the current loss interval in intervals
+	n        int                            // Number of loss intervals in intervals
+	interval [SSLossIntervals]segmentTotals // Circular array of the last few loss intervals
+}
+
+// segmentTotals accumulates the sizes of the data packets sent during a loss interval
+type segmentTotals struct {
+	Bytes   int64
+	Packets int64
 }
 
-const FixedSegmentSize = 2*1500
+const (
+	SSLossIntervals = 4             // Number of most recent loss intervals, over which SS is averaged
+	SSMin           = 1             // Smallest segment size, which protects rate calculations from zero
+	SSDefaultPMTU   = dccp.PMTUBase // CCMPS before the Path MTU is known
+)
 
 // Init resets the object for new use
 func (t *senderSegmentSize) Init() {
-	t.mps = 0
+	*t = senderSegmentSize{ccmps: SSDefaultPMTU, n: 1}
 }
 
-// Sender calls SetMPS to notify this object if the maximum packet size in use
-func (t *senderSegmentSize) SetMPS(mps int) { t.mps = mps }
+// SetPMTU sets the Path MTU, from which CCMPS is derived, and the overhead of the headers and
+// options of a data packet
+func (t *senderSegmentSize) SetPMTU(pmtu int32, overhead int32) {
+	t.ccmps, t.overhead = pmtu, overhead
+}
+
+// CCMPS returns the Congestion Control Maximum Packet Size
+func (t *senderSegmentSize) CCMPS() int32 { return t.ccmps }
+
+// MaxSS returns the largest segment size that fits in CCMPS
+func (t *senderSegmentSize) MaxSS() uint32 {
+	return uint32(max64(SSMin, int64(t.ccmps-t.overhead)))
+}
+
+// Sender calls OnWrite for every packet sent, so that the sizes of data packets are counted
+func (t *senderSegmentSize) OnWrite(ph *dccp.PreHeader) {
+	if ph.Type != dccp.Data && ph.Type != dccp.DataAck {
+		return
+	}
+	t.interval[t.k].Bytes += int64(ph.DataLen)
+	t.interval[t.k].Packets++
+}
+
+// Sender calls OnLoss whenever feedback reports new loss events, which begin a new loss interval
+func (t *senderSegmentSize) OnLoss() {
+	t.k = (t.k + 1) % SSLossIntervals
+	t.interval[t.k] = segmentTotals{}
+	t.n = min(t.n+1, SSLossIntervals)
+}
 
 // SS returns the current estimate of the segment size
-func (t *senderSegmentSize) SS() int { 
-	if t.mps <= 0 {
-		panic("not ready with SS")
+func (t *senderSegmentSize) SS() uint32 {
+	var total segmentTotals
+	for i := 0; i < t.n; i++ {
+		r := &t.interval[(t.k+SSLossIntervals-i)%SSLossIntervals]
+		total.Bytes += r.Bytes
+		total.Packets += r.Packets
+	}
+	if total.Packets == 0 {
+		return t.MaxSS()
 	}
-	// TODO: Segment Size should equal maximum packet size minus DCCP header size.
-	// In other words, it is supposed to reflect the app data size.
-	// Since we are in user space, we tend to use MPS to mean app data as well. 
-	// It would be nice to set these straight eventually and use more uniform terminology.
-	return t.mps
+	return uint32(max64(SSMin, (total.Bytes+total.Packets/2)/total.Packets))
 }
@@ -0,0 +1,55 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// TestSegmentSize checks that SS is the mean size of the application data sent over the last
+// few loss intervals, and the largest segment that fits in CCMPS before any data is sent
+func TestSegmentSize(t *testing.T) {
+	var t3 senderSegmentSize
+	t3.Init()
+	if ss := t3.SS(); ss != SSDefaultPMTU {
+		t.Errorf("expecting default SS %d, got %d", SSDefaultPMTU, ss)
+	}
+	t3.SetPMTU(1500, 48)
+	if ccmps, ss := t3.CCMPS(), t3.SS(); ccmps != 1500 || ss != 1452 {
+		t.Errorf("expecting CCMPS 1500 and SS 1452, got %d and %d", ccmps, ss)
+	}
+
+	write := func(typ byte, dataLen int) {
+		t3.OnWrite(&dccp.PreHeader{Type: typ, DataLen: dataLen})
+	}
+	write(dccp.Data, 80)
+	write(dccp.DataAck, 320)
+	write(dccp.Ack, 0)
+	if ss := t3.SS(); ss != 200 {
+		t.Errorf("expecting SS 200, got %d", ss)
+	}
+
+	// The sizes of the first interval are forgotten after SSLossIntervals more intervals
+	for i := 0; i < SSLossIntervals-1; i++ {
+		t3.OnLoss()
+		write(dccp.Data, 1400)
+	}
+	if ss := t3.SS(); ss != (80+320+3*1400+2)/5 {
+		t.Errorf("expecting SS %d, got %d", (80+320+3*1400+2)/5, ss)
+	}
+	t3.OnLoss()
+	write(dccp.Data, 1400)
+	if ss := t3.SS(); ss != 1400 {
+		t.Errorf("expecting SS 1400, got %d", ss)
+	}
+
+	// Zero-length data does not reduce SS to zero
+	t3.Init()
+	write(dccp.Data, 0)
+	if ss := t3.SS(); ss != SSMin {
+		t.Errorf("expecting SS %d, got %d", SSMin, ss)
+	}
+}
@@ -9,15 +9,18 @@ import (
 	"github.com/petar/GoDCCP/dccp"
 )
 
-func newSender(env *dccp.Env, amb *dccp.Amb) *sender {
-	return &sender{ env: env, amb: amb.Refine("sender") }
+func newSender(env *dccp.Env, amb *dccp.Amb, cfg *Config) *sender {
+	s := &sender{ env: env, amb: amb.Refine("sender"), config: *cfg }
+	s.senderSegmentSize.Init()
+	return s
 }
 
 // sender implements a CCID3 congestion control sender.
 // It conforms to dccp.SenderCongestionControl.
 type sender struct {
-	env *dccp.Env
-	amb *dccp.Amb
+	env    *dccp.Env
+	amb    *dccp.Amb
+	config Config
 	senderStrober
 	dccp.Mutex // Locks all fields below
 	senderRoundtripEstimator
@@ -26,16 +29,22 @@ type sender struct {
 	senderNoFeedbackTimer
 	senderSegmentSize
 	senderLossTracker
+	senderDataLimit
 	senderRateCalculator
-	open bool // Whether the CC is active
+	slowUntil int64 // Time until which the allowed sending rate is held, following a Slow Receiver option
+	open      bool  // Whether the CC is active
+	heartbeat int64 // Desired heartbeat interval
 }
 
 // GetID() returns the CCID of this congestion control algorithm
 func (s *sender) GetID() byte { return dccp.CCID3 }
 
 // GetCCMPS returns the Congestion Control Maximum Packet Size, CCMPS. Generally, PMTU <= CCMPS
-// TODO: For the time being we use a fixed CCMPS
-func (s *sender) GetCCMPS() int32 { return FixedSegmentSize }
+func (s *sender) GetCCMPS() int32 {
+	s.Lock()
+	defer s.Unlock()
+	return s.senderSegmentSize.CCMPS()
+}
 
 // GetRTT returns the Round-Trip Time as measured by this CCID
 func (s *sender) GetRTT() int64 {
@@ -45,6 +54,30 @@ func (s *sender) GetRTT() int64 {
 	return rtt
 }
 
+// GetPacketsPerRTT estimates the number of packets sent per round-trip time at the rate
+// currently enforced by the strober. It conforms to dccp.PacketRateSender.
+func (s *sender) GetPacketsPerRTT() int64 {
+	s.Lock()
+	defer s.Unlock()
+	if !s.open {
+		return 0
+	}
+	rtt, _ := s.senderRoundtripEstimator.RTT()
+	interval := max64(1, s.senderStrober.Interval())
+	return (rtt + interval - 1) / interval
+}
+
+// GetPacketInterval returns the time interval between packets enforced by the strober.
+// It conforms to dccp.PacketIntervalSender.
+func (s *sender) GetPacketInterval() int64 {
+	s.Lock()
+	defer s.Unlock()
+	if !s.open {
+		return 0
+	}
+	return s.senderStrober.Interval()
+}
+
 // Open tells the Congestion Control that the connection has entered
 // OPEN or PARTOPEN state and that the CC can now kick in. Before the
 // call to Open and after the call to Close, the Strobe function is
@@ -61,13 +94,25 @@ func (s *sender) Open() {
 	s.senderRoundtripReporter.Init()
 	s.senderNoFeedbackTimer.Init()
 	s.senderSegmentSize.Init()
-	s.senderSegmentSize.SetMPS(FixedSegmentSize)
-	s.senderLossTracker.Init(s.amb)
-	s.senderRateCalculator.Init(s.amb, FixedSegmentSize, rtt)
-	s.senderStrober.Init(s.env, s.amb, s.senderRateCalculator.X(), FixedSegmentSize)
+	ss := s.senderSegmentSize.SS()
+	s.senderLossTracker.Init(s.amb, s.config.LossFromIntervals)
+	s.senderDataLimit.Init()
+	s.senderRateCalculator.Init(s.amb, ss, rtt, s.config.ReduceOscillations)
+	s.senderStrober.Init(s.env, s.amb, s.senderRateCalculator.X(), ss)
+	s.setRate(s.senderRateCalculator.X(), ss)
 	s.open = true
 }
 
+// setRate sets the strobe rate to the allowed sending rate x, given in bytes per second. Flag
+// "FixRate", if present, enforces a fixed send rate given in packets per second instead.
+func (s *sender) setRate(x uint32, ss uint32) {
+	if flagFixRate, flagFixRatePresent := s.amb.Flags().GetUint32("FixRate"); flagFixRatePresent {
+		s.senderStrober.SetRatePPS(flagFixRate)
+		return
+	}
+	s.senderStrober.SetRate(x, ss)
+}
+
 // Conn calls OnWrite before a packet is sent to give CongestionControl
 // an opportunity to add CCVal and options to an outgoing packet
 // If the CC is not active, OnWrite should return 0, nil.
@@ -80,6 +125,8 @@ func (s *sender) OnWrite(ph *dccp.PreHeader) (ccval int8, options []*dccp.Option
 	}
 
 	s.senderNoFeedbackTimer.OnWrite(ph)
+	s.senderSegmentSize.OnWrite(ph)
+	s.senderDataLimit.OnWrite(ph)
 
 	s.senderRoundtripEstimator.OnWrite(ph.SeqNo, ph.TimeWrite)
 	rtt, _ := s.senderRoundtripEstimator.RTT()
@@ -87,9 +134,12 @@ func (s *sender) OnWrite(ph *dccp.PreHeader) (ccval int8, options []*dccp.Option
 	ccval = s.senderWindowCounter.OnWrite(rtt, ph.SeqNo, ph.TimeWrite)
 	s.amb.E(dccp.EventInfo, fmt.Sprintf("CCVAL=%d", ccval))
 
-	reportOpt := s.senderRoundtripReporter.OnWrite(rtt, ph.TimeWrite)
-	if reportOpt != nil {
-		options = []*dccp.Option{ reportOpt }
+	// The Roundtrip Report option is our own extension, which strict RFC 4342 peers don't know
+	if !s.config.StrictRFC4342 {
+		reportOpt := s.senderRoundtripReporter.OnWrite(rtt, ph.TimeWrite)
+		if reportOpt != nil {
+			options = []*dccp.Option{ reportOpt }
+		}
 	}
 
 	return ccval, options
@@ -115,6 +165,13 @@ func (s *sender) OnRead(fb *dccp.FeedbackHeader) error {
 	s.senderRoundtripEstimator.OnRead(fb)
 	rtt, rttEstimated := s.senderRoundtripEstimator.RTT()
 
+	// Determine whether the interval covered by the feedback was data-limited
+	ackTime := fb.Time - rtt
+	if st := s.senderRoundtripEstimator.find(fb.AckNo); st != nil {
+		ackTime = st.Time
+	}
+	dataLimited := s.senderDataLimit.OnRead(fb.Time, ackTime, rtt)
+
 	// Update the nofeedback timeout interval and reset the timer
 	s.senderNoFeedbackTimer.OnRead(rtt, rttEstimated, fb)
 
@@ -126,6 +183,10 @@ func (s *sender) OnRead(fb *dccp.FeedbackHeader) error {
 	if err != nil {
 		return nil
 	}
+	if lossFeedback.NewLossCount > 0 {
+		s.senderSegmentSize.OnLoss()
+	}
+	ss := s.senderSegmentSize.SS()
 
 	// Update allowed sending rate
 	xrecv, err := readReceiveRate(fb)
@@ -133,21 +194,19 @@ func (s *sender) OnRead(fb *dccp.FeedbackHeader) error {
 		s.amb.E(dccp.EventWarn, "Feedback packet with corrupt receive rate option", fb)
 		return nil
 	}
+	xrecv = adjustReceiveRate(xrecv, countDropped(fb), ss, rtt)
 	xf := &XFeedback{
 		Now:          fb.Time,
-		SS:           FixedSegmentSize,
+		SS:           ss,
 		XRecv:        xrecv,
 		RTT:          rtt,
+		RTTSample:    s.senderRoundtripEstimator.Sample(),
+		DataLimited:  dataLimited,
+		Hold:         fb.Time < s.slowUntil,
 		LossFeedback: lossFeedback,
 	}
 	x := s.senderRateCalculator.OnRead(xf)
-	// Flag "FixRate", if present, enforces a fixed send rate given in packets per second
-	flagFixRate, flagFixRatePresent := s.amb.Flags().GetUint32("FixRate")
-	if flagFixRatePresent {
-		s.senderStrober.SetRatePPS(flagFixRate)
-	} else {
-		s.senderStrober.SetRate(x, FixedSegmentSize)
-	}
+	s.setRate(x, ss)
 
 	return nil
 }
@@ -168,9 +227,49 @@ func readReceiveRate(fb *dccp.FeedbackHeader) (xrecv uint32, err error) {
 	return receiverRateCalculator.Rate, nil
 }
 
+// countDropped returns the number of packets that fb newly reports as dropped at the
+// receiver due to congestion. Drop Codes 0 and 1 do not indicate congestion. Drop Code 2 is
+// the receive buffer drop of RFC 4342, Section 5.2. In the absence of ECN support, the
+// remaining codes, which RFC 4340 treats as ECN marks, are counted alike.
+func countDropped(fb *dccp.FeedbackHeader) int64 {
+	var n int64
+	for _, d := range fb.DataDropped {
+		if d.DropCode != dccp.DropProtocolConstraints && d.DropCode != dccp.DropAppNotListening {
+			n += dccp.SeqDist(d.Lo, d.Hi) + 1
+		}
+	}
+	return n
+}
+
+// adjustReceiveRate lowers the receive rate xrecv, reported by the receiver, in response to
+// ndrop packets of size ss newly reported as dropped, as described in RFC 4342, Section 5.2
+func adjustReceiveRate(xrecv uint32, ndrop int64, ss uint32, rtt int64) uint32 {
+	if ndrop <= 0 {
+		return xrecv
+	}
+	xdrop := int64(xrecv)
+	if rtt > 0 {
+		perRTT := (1e9 * int64(ss)) / rtt // One packet per RTT, in bytes per second
+		xdrop = max64(int64(xrecv)-ndrop*perRTT, min64(int64(xrecv), perRTT))
+	}
+	return uint32(min64(int64(xrecv), xdrop/2))
+}
+
+// OnSlowReceiver holds the allowed sending rate for one round-trip time, RFC 4342 Section 5.2
+func (s *sender) OnSlowReceiver(now int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.open {
+		return
+	}
+	rtt, _ := s.senderRoundtripEstimator.RTT()
+	s.slowUntil = now + rtt
+}
+
 // Strobe blocks until a new packet can be sent without violating the congestion control
 // rate limit. If the CC is not active, Strobe MUST return immediately.
-func (s *sender) Strobe() {
+func (s *sender) Strobe(Type byte) {
 	s.Lock()
 	open := s.open
 	s.Unlock()
@@ -180,7 +279,10 @@ func (s *sender) Strobe() {
 		return
 	}
 
-	s.senderStrober.Strobe()
+	waited := s.senderStrober.Strobe()
+	s.Lock()
+	s.senderDataLimit.OnStrobe(waited)
+	s.Unlock()
 }
 
 // OnIdle is called periodically. If the CC is not active, OnIdle MUST to return nil.
@@ -197,13 +299,7 @@ func (s *sender) OnIdle(now int64) error {
 		_, hasRTT := s.senderRoundtripEstimator.RTT()
 
 		x := s.senderRateCalculator.OnNoFeedback(now, hasRTT, idleSince, nofeedbackSet)
-		// Flag "FixRate" described above
-		flagFixRate, flagFixRatePresent := s.amb.Flags().GetUint32("FixRate")
-		if flagFixRatePresent {
-			s.senderStrober.SetRatePPS(flagFixRate)
-		} else {
-			s.senderStrober.SetRate(x, FixedSegmentSize)
-		}
+		s.setRate(x, s.senderSegmentSize.SS())
 
 		s.senderNoFeedbackTimer.Reset(now)
 	}
@@ -214,7 +310,21 @@ func (s *sender) OnIdle(now int64) error {
 // SetHeartbeat advices the CCID of the desired frequency of heartbeat packets.  A heartbeat
 // interval value of zero indicates that no heartbeat is needed.
 func (s *sender) SetHeartbeat(interval int64) {
-	panic("un")
+	s.Lock()
+	defer s.Unlock()
+	s.heartbeat = interval
+}
+
+// SetPMTU sets the Path MTU, from which CCMPS and the largest segment size are derived. It
+// conforms to dccp.PMTUSender. If the CC is not active, SetPMTU does nothing.
+func (s *sender) SetPMTU(pmtu int32, overhead int32) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.open {
+		return
+	}
+	s.senderSegmentSize.SetPMTU(pmtu, overhead)
+	s.amb.E(dccp.EventInfo, fmt.Sprintf("CCMPS=%d, max SS=%d", s.senderSegmentSize.CCMPS(), s.senderSegmentSize.MaxSS()))
 }
 
 // Close terminates the half-connection congestion control when it is not needed any longer
@@ -222,4 +332,5 @@ func (s *sender) Close() {
 	s.Lock()
 	defer s.Unlock()
 	s.open = false
+	s.senderStrober.Close()
 }
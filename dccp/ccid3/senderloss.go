@@ -12,21 +12,39 @@ import (
 // —————
 // senderLossTracker processes loss intervals options received at the sender and maintains relevant loss
 // statistics.
+//
+// By default, the loss event rate is the one reported by the receiver in its Loss Event Rate option,
+// falling back to the rate computed from the Loss Intervals option when the former is missing. When
+// fromIntervals is set, the rate is always computed from the Loss Intervals option, and a reported rate
+// that differs from it is counted as a misreport.
 type senderLossTracker struct {
 	amb *dccp.Amb
+	fromIntervals    bool   // Whether the loss event rate is computed purely from the loss intervals
+	lastAckNoPresent bool   // Whether any feedback has been received
 	lastAckNo   int64  // SeqNo of the last ack'd segment; equals the AckNo of the last feedback
 	lastRateInv uint32 // Last known value of loss event rate inverse
+	misreports  int    // Number of feedback packets whose reported loss event rate disagreed with the loss intervals
 	lossRateCalculator
 }
 
 // Init resets the senderLossTracker instance for new use
-func (t *senderLossTracker) Init(amb *dccp.Amb) {
+func (t *senderLossTracker) Init(amb *dccp.Amb, fromIntervals bool) {
 	t.amb = amb.Refine("senderLossTracker")
+	t.fromIntervals = fromIntervals
+	t.lastAckNoPresent = false
 	t.lastAckNo = 0
 	t.lastRateInv = UnknownLossEventRateInv
+	t.misreports = 0
 	t.lossRateCalculator.Init(NINTERVAL)
 }
 
+// Misreports returns the number of feedback packets, since Init, whose Loss Event Rate option
+// disagreed with the rate computed from their Loss Intervals option. Misreports are only detected
+// when the loss event rate is computed from the loss intervals.
+func (t *senderLossTracker) Misreports() int {
+	return t.misreports
+}
+
 // calcRateInv computes the loss event rate inverse encoded in the loss intervals
 func (t *senderLossTracker) calcRateInv(details []*LossIntervalDetail) uint32 {
 	return t.lossRateCalculator.CalcLossEventRateInv(details)
@@ -37,6 +55,7 @@ type LossFeedback struct {
 	RateInv      uint32 // Loss event rate inverse
 	NewLossCount byte   // Number of loss events reported in this feedback packet
 	RateInc      bool   // Has the loss rate increased since the last feedback packet
+	Misreported  bool   // Did the reported loss event rate disagree with the loss intervals
 }
 
 // Sender calls OnRead whenever a new feedback packet arrives
@@ -47,12 +66,15 @@ func (t *senderLossTracker) OnRead(fb *dccp.FeedbackHeader) (LossFeedback, error
 		return LossFeedback{}, ErrNoAck
 	}
 	var lossIntervals *LossIntervalsOption
+	var lossEventRate *LossEventRateOption
 	t.amb.E(dccp.EventInfo, fmt.Sprintf("Encoded option count = %d", len(fb.Options)), fb)
-	for i, opt := range fb.Options {
-		if lossIntervals = DecodeLossIntervalsOption(opt); lossIntervals != nil {
-			break
+	for _, opt := range fb.Options {
+		if li := DecodeLossIntervalsOption(opt); li != nil && lossIntervals == nil {
+			lossIntervals = li
+		}
+		if ler := DecodeLossEventRateOption(opt); ler != nil && lossEventRate == nil {
+			lossEventRate = ler
 		}
-		t.amb.E(dccp.EventInfo, fmt.Sprintf("Decodingd option %d", i), fb)
 	}
 	if lossIntervals == nil {
 		t.amb.E(dccp.EventWarn, "Missing lossIntervals option", fb)
@@ -62,19 +84,34 @@ func (t *senderLossTracker) OnRead(fb *dccp.FeedbackHeader) (LossFeedback, error
 	// Calcuate new loss count
 	var r LossFeedback
 	details := recoverIntervalDetails(fb.AckNo, lossIntervals.SkipLength, lossIntervals.LossIntervals)
-	r.NewLossCount = calcNewLossCount(details, t.lastAckNo)
+	r.NewLossCount = calcNewLossCount(details, t.lastAckNoPresent, t.lastAckNo)
 
 	// Calculate new rate inverse
 	rateInv := t.calcRateInv(details)
+	if lossEventRate != nil {
+		if t.fromIntervals {
+			if lossEventRate.RateInv != rateInv {
+				r.Misreported = true
+				t.misreports++
+				t.amb.E(dccp.EventWarn, fmt.Sprintf("Receiver reports loss rate inv %d, loss intervals give %d",
+					lossEventRate.RateInv, rateInv), fb)
+			}
+		} else {
+			rateInv = lossEventRate.RateInv
+		}
+	}
 	r.RateInv = rateInv
 	if rateInv < t.lastRateInv {
 		r.RateInc = true
 	}
 	t.lastRateInv = rateInv
-	t.amb.E(dccp.EventMatch, fmt.Sprintf("Loss rate inv = %0.4g", 1 / float64(rateInv)))
+	t.amb.E(dccp.EventMatch, fmt.Sprintf("Loss rate inv = %0.4g", 1 / float64(rateInv)),
+		LossSample(LossSenderEstimateSample, rateInv))
 
-	// XXX: Must use circular arithmetic here
-	t.lastAckNo = max64(t.lastAckNo, fb.AckNo)
+	if !t.lastAckNoPresent || dccp.SeqLess(t.lastAckNo, fb.AckNo) {
+		t.lastAckNo = fb.AckNo
+	}
+	t.lastAckNoPresent = true
 
 	return r, nil
 }
@@ -82,11 +119,11 @@ func (t *senderLossTracker) OnRead(fb *dccp.FeedbackHeader) (LossFeedback, error
 // recoverIntervalDetails returns a slice containing the estimated details of the loss intervals
 func recoverIntervalDetails(ackno int64, skip byte, lis []*LossInterval) []*LossIntervalDetail {
 	r := make([]*LossIntervalDetail, len(lis))
-	var head int64 = ackno + 1 - int64(skip)
+	var head int64 = dccp.SeqAdd(ackno, 1-int64(skip))
 	for i, li := range lis {
 		r[i] = &LossIntervalDetail{}
 		r[i].LossInterval = *li
-		head -= int64(li.SeqLen())
+		head = dccp.SeqAdd(head, -int64(li.SeqLen()))
 		r[i].StartSeqNo = head
 		// TODO: StartTime, StartRTT, Unfinished are not recovered (but also not used)
 	}
@@ -94,13 +131,12 @@ func recoverIntervalDetails(ackno int64, skip byte, lis []*LossInterval) []*Loss
 }
 
 // calcNewLossCount calculates the number of new loss intervals reported in this feedback packet,
-// since the last packet (identified by lastAckNo)
-// XXX: Must use circular arithmetic here
-func calcNewLossCount(details []*LossIntervalDetail, lastAckNo int64) byte {
-	// If lastAckNo is zero (no acks have been received), this function works correctly
+// since the last packet (identified by lastAckNo). All intervals are new, if no feedback has
+// been received before, as indicated by lastAckNoPresent.
+func calcNewLossCount(details []*LossIntervalDetail, lastAckNoPresent bool, lastAckNo int64) byte {
 	var r byte
 	for _, d := range details {
-		if d.StartSeqNo <= lastAckNo {
+		if lastAckNoPresent && dccp.SeqLessEq(d.StartSeqNo, lastAckNo) {
 			break
 		}
 		r++
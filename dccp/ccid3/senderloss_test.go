@@ -0,0 +1,59 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// makeLossFeedback returns a feedback packet carrying three loss intervals of 100 packets each,
+// which give a loss event rate inverse of 100, and a Loss Event Rate option reporting rateInv
+func makeLossFeedback(ackNo int64, rateInv uint32) *dccp.FeedbackHeader {
+	lis := make([]*LossInterval, 3)
+	for i := range lis {
+		lis[i] = &LossInterval{LosslessLength: 99, LossLength: 1, DataLength: 100}
+	}
+	return &dccp.FeedbackHeader{
+		Type:  dccp.Ack,
+		AckNo: ackNo,
+		Options: []*dccp.Option{
+			encodeOption(&LossIntervalsOption{SkipLength: 1, LossIntervals: lis}),
+			encodeOption(&LossEventRateOption{RateInv: rateInv}),
+		},
+	}
+}
+
+// TestSenderLossMisreports checks that a sender uses the loss event rate reported by the receiver
+// by default, and that it computes the rate from the loss intervals and detects a receiver that
+// underreports loss when asked to
+func TestSenderLossMisreports(t *testing.T) {
+	amb := dccp.NewAmb("test", dccp.NewEnv(nil))
+
+	var trusting senderLossTracker
+	trusting.Init(amb, false)
+	r, err := trusting.OnRead(makeLossFeedback(400, 1000))
+	if err != nil {
+		t.Fatalf("reading feedback (%s)", err)
+	}
+	if r.RateInv != 1000 || r.Misreported {
+		t.Errorf("expecting reported rate inv 1000, got %d (misreported=%v)", r.RateInv, r.Misreported)
+	}
+	if n := trusting.Misreports(); n != 0 {
+		t.Errorf("expecting no misreports, got %d", n)
+	}
+
+	var detecting senderLossTracker
+	detecting.Init(amb, true)
+	if r, _ = detecting.OnRead(makeLossFeedback(400, 100)); r.RateInv != 100 || r.Misreported {
+		t.Errorf("expecting computed rate inv 100, got %d (misreported=%v)", r.RateInv, r.Misreported)
+	}
+	if r, _ = detecting.OnRead(makeLossFeedback(401, 1000)); r.RateInv != 100 || !r.Misreported {
+		t.Errorf("expecting computed rate inv 100 and a misreport, got %d (misreported=%v)", r.RateInv, r.Misreported)
+	}
+	if n := detecting.Misreports(); n != 1 {
+		t.Errorf("expecting 1 misreport, got %d", n)
+	}
+}
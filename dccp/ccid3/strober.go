@@ -17,21 +17,38 @@ type senderStrober struct {
 	dccp.Mutex
 	interval int64		// Maximum average time interval between packets, in nanoseconds
 	last     int64
+	closed   chan struct{}	// Closed by Close to release a waiting Strobe
 }
 
 // BytesPerSecondToPacketsPer64Sec converts a rate in byter per second to
-// packets of size ss per 64 seconds
+// packets of size ss per 64 seconds. The result is at least one packet, the minimum
+// rate, which minRate loses to rounding when ss does not divide evenly.
 func BytesPerSecondToPacketsPer64Sec(bps uint32, ss uint32) int64 {
-	return (64 * int64(bps)) / int64(ss)
+	return max64(1, (64 * int64(bps)) / int64(ss))
 }
 
 // Init resets the senderStrober instance for new use
 func (s *senderStrober) Init(env *dccp.Env, amb *dccp.Amb, bps uint32, ss uint32) {
 	s.env = env
 	s.amb = amb.Refine("strober")
+	s.closed = make(chan struct{})
 	s.SetRate(bps, ss)
 }
 
+// Close releases any pending call to Strobe and makes future calls return immediately
+func (s *senderStrober) Close() {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed == nil {
+		return
+	}
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+}
+
 // SetWait sets the strobing rate by setting the time interval between two strobes in nanoseconds
 func (s *senderStrober) SetInterval(interval int64) {
 	s.Lock()
@@ -67,6 +84,13 @@ func (s *senderStrober) SetRatePPS(pps uint32) {
 	// s.amb.E(dccp.EventInfo, fmt.Sprintf("Set strobe rate %d pps", 1e9 / s.interval))
 }
 
+// Interval returns the current time interval between two strobes in nanoseconds
+func (s *senderStrober) Interval() int64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.interval
+}
+
 // Strobe ensures that the frequency with which (multiple calls) to Strobe return does not
 // exceed the allowed rate.  In particular, note that senderStrober makes sure that after data
 // limited periods, when the application is not calling it for a while, there is no burst of
@@ -74,18 +98,28 @@ func (s *senderStrober) SetRatePPS(pps uint32) {
 // not use a lock to prevent concurrent invocation. DCCP currently calls Strobe in a loop,
 // so concurrent invocations are not a concern.
 //
+// Strobe returns true if it had to wait, meaning that the caller was limited by the rate
+// rather than by the data it had to send. A wait is cut short by a call to Close.
+//
 // XXX: This routine should be optimized
-func (s *senderStrober) Strobe() {
+func (s *senderStrober) Strobe() (waited bool) {
 	s.Lock()
 	now := s.env.Now()
 	delta := s.interval - (now - s.last)
 	_interval := s.interval
+	closed := s.closed
 	s.Unlock()
 	defer s.amb.E(dccp.EventInfo, fmt.Sprintf("Strobe at %d pps", 1e9 / _interval), nil)
 	if delta > 0 {
-		s.env.Sleep(delta)
+		expired, stop := s.env.Timer(delta)
+		select {
+		case <-expired:
+		case <-closed:
+		}
+		stop()
 	}
 	s.Lock()
 	s.last = s.env.Now()
 	s.Unlock()
+	return delta > 0
 }
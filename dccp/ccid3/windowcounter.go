@@ -4,6 +4,10 @@
 
 package ccid3
 
+import (
+	"github.com/petar/GoDCCP/dccp"
+)
+
 // —————
 // senderWindowCounter maintains the window counter (WC) logic of the sender.
 // It's logic is described in RFC 4342, Section 8.1.
@@ -57,7 +61,7 @@ func (wc *senderWindowCounter) Init() {
 func (wc *senderWindowCounter) OnWrite(rtt int64, seqNo int64, now int64) int8 {
 	// Update sequence number fields
 	if wc.lastSeqNoPresent {
-		if seqNo <= wc.lastSeqNo {
+		if dccp.SeqLessEq(seqNo, wc.lastSeqNo) {
 			panic("non-increasing seq no")
 		}
 	}
@@ -128,11 +132,11 @@ func (wc *senderWindowCounter) getTimeBound(rtt int64, now int64) (ccvalInc int8
 // OnRead simply keeps track of the highest acknowledged sequence number.
 func (wc *senderWindowCounter) OnRead(ackNo int64) {
 	// Discard acknowledgements of unsent packets
-	if !wc.lastSeqNoPresent || ackNo > wc.lastSeqNo {
+	if !wc.lastSeqNoPresent || dccp.SeqLess(wc.lastSeqNo, ackNo) {
 		return
 	}
 	if wc.lastAckNoPresent {
-		wc.lastAckNo = max64(wc.lastAckNo, ackNo)
+		wc.lastAckNo = dccp.SeqMax(wc.lastAckNo, ackNo)
 	} else {
 		wc.lastAckNoPresent = true
 		wc.lastAckNo = ackNo
@@ -169,7 +173,7 @@ func (t *windowHistory) Add(startSeqNo int64, startTime int64, ccval int8) {
 	lastRec := t.fetch(0)
 	if lastRec != nil {
 		// ccvals cannot decrease
-		if startSeqNo <= lastRec.StartSeqNo {
+		if dccp.SeqLessEq(startSeqNo, lastRec.StartSeqNo) {
 			panic("non-increasing sequence number")
 		}
 		// Time of outgoing packets should increase
@@ -199,7 +203,7 @@ func (t *windowHistory) Lookup(seqNo int64, ccvalDepth int) (ccvalDiff int8, ok
 		if prev != nil {
 			ccvalDiff += diffWindowCounter(prev.CCVal, w.CCVal)
 		}
-		if w.StartSeqNo <= seqNo {
+		if dccp.SeqLessEq(w.StartSeqNo, seqNo) {
 			return ccvalDiff, true
 		}
 	}
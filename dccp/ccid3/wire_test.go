@@ -0,0 +1,172 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// The golden tests below pin the on-the-wire format of the options and packets that CCID3
+// exchanges in strict RFC 4342 mode. Their expected bytes were checked by hand against
+// RFC 4340, Section 5, and RFC 4342, Section 8.
+
+var wireIP = []byte{10, 0, 0, 1}
+
+// wireOption pairs an option with its expected encoding, type byte and data alike
+type wireOption struct {
+	Unencoded UnencodedOption
+	Type      byte
+	Data      string // Hex
+}
+
+var wireOptions = []wireOption{
+	{&dccp.ElapsedTimeOption{Elapsed: 0x1234}, dccp.OptionElapsedTime, "1234"},
+	{&dccp.ElapsedTimeOption{Elapsed: 0x12345678}, dccp.OptionElapsedTime, "12345678"},
+	{&ReceiveRateOption{Rate: 0x00010203}, OptionReceiveRate, "00010203"},
+	{&LossEventRateOption{RateInv: 100}, OptionLossEventRate, "00000064"},
+	{&LossEventRateOption{RateInv: UnknownLossEventRateInv}, OptionLossEventRate, "ffffffff"},
+	{
+		&LossIntervalsOption{
+			SkipLength: 2,
+			LossIntervals: []*LossInterval{
+				&LossInterval{LosslessLength: 9, LossLength: 1, DataLength: 10, ECNNonceEcho: false},
+				&LossInterval{LosslessLength: 0x123456, LossLength: 3, DataLength: 0x10, ECNNonceEcho: true},
+			},
+		},
+		OptionLossIntervals,
+		"02" + "000009" + "000001" + "00000a" + "123456" + "800003" + "000010",
+	},
+}
+
+// TestWireOptions checks that the options of RFC 4342 encode to, and decode from, their
+// pinned wire format
+func TestWireOptions(t *testing.T) {
+	for i, w := range wireOptions {
+		opt, err := w.Unencoded.Encode()
+		if err != nil {
+			t.Fatalf("#%d: encoding (%s)", i, err)
+		}
+		if opt.Type != w.Type || hex.EncodeToString(opt.Data) != w.Data || opt.Mandatory {
+			t.Errorf("#%d: expecting type %d data %s, got type %d data %x", i, w.Type, w.Data, opt.Type, opt.Data)
+		}
+		var decoded interface{}
+		switch w.Unencoded.(type) {
+		case *dccp.ElapsedTimeOption:
+			decoded = dccp.DecodeElapsedTimeOption(opt)
+		case *ReceiveRateOption:
+			decoded = DecodeReceiveRateOption(opt)
+		case *LossEventRateOption:
+			decoded = DecodeLossEventRateOption(opt)
+		case *LossIntervalsOption:
+			decoded = DecodeLossIntervalsOption(opt)
+		}
+		if !reflect.DeepEqual(decoded, w.Unencoded) {
+			t.Errorf("#%d: expecting %v, decoded %v", i, w.Unencoded, decoded)
+		}
+	}
+}
+
+// wireFeedbackPacket is the Ack that a strict receiver sends after receiving data packets 1—80,
+// except 10 and 50, with packets 10ms apart and the window counter advancing every 5 packets
+const wireFeedbackPacket = "" +
+	"1389" + "0fa0" + "10" + "00" + "5a13" + "07" + "00" + "000000000064" + // Generic header, X=1
+	"0000" + "000000000050" + // Acknowledgement Number subheader
+	"2b04" + "0002" + // Elapsed Time of 20µs
+	"c206" + "00002691" + // Receive Rate of 9873 bytes per second
+	"c115" + "03" + // Loss Intervals, Skip Length 3, the packets not yet known to be received
+	"00001b" + "000001" + "00001c" + // Unfinished interval 50—77, beginning with the loss of 50
+	"000027" + "000001" + "000028" + // Interval 10—49
+	"c006" + "00000028" + // Loss Event Rate, with inverse 40
+	"000000" // Padding
+
+// TestWireFeedback checks that a strict receiver encodes its feedback in the pinned format and
+// that the sender decodes it, finding no private options
+func TestWireFeedback(t *testing.T) {
+	env := dccp.NewEnv(nil)
+	r := newReceiver(env, dccp.NewAmb("receiver", env), &Config{StrictRFC4342: true})
+	r.Open()
+	const t0 = 1e9
+	var now int64
+	for seqNo := int64(1); seqNo <= 80; seqNo++ {
+		now = t0 + seqNo*10e6
+		if seqNo == 10 || seqNo == 50 {
+			continue
+		}
+		r.OnRead(&dccp.FeedforwardHeader{
+			Type:    dccp.Data,
+			X:       true,
+			SeqNo:   seqNo,
+			CCVal:   int8((seqNo / 5) % WindowCounterMod),
+			Time:    now,
+			DataLen: 100,
+		})
+	}
+	opts := r.OnWrite(&dccp.PreHeader{Type: dccp.Ack, X: true, SeqNo: 100, AckNo: 80, TimeWrite: now + 20e3})
+
+	h := &dccp.Header{
+		SourcePort: 5001,
+		DestPort:   4000,
+		Type:       dccp.Ack,
+		X:          true,
+		SeqNo:      100,
+		AckNo:      80,
+		Options:    opts,
+	}
+	p, err := h.Write(wireIP, wireIP, dccp.ProtoDCCP, false)
+	if err != nil {
+		t.Fatalf("encoding (%s)", err)
+	}
+	if got := hex.EncodeToString(p); got != wireFeedbackPacket {
+		t.Errorf("expecting\n%s\ngot\n%s", wireFeedbackPacket, got)
+	}
+
+	// The sender finds only the options of RFC 4340 and RFC 4342
+	golden, _ := hex.DecodeString(wireFeedbackPacket)
+	g, err := dccp.ReadHeader(golden, wireIP, wireIP, dccp.ProtoDCCP, false)
+	if err != nil {
+		t.Fatalf("decoding (%s)", err)
+	}
+	for _, opt := range g.Options {
+		switch opt.Type {
+		case dccp.OptionElapsedTime, OptionReceiveRate, OptionLossIntervals, OptionLossEventRate, dccp.OptionPadding:
+		default:
+			t.Errorf("unexpected option type %d", opt.Type)
+		}
+	}
+	var s senderLossTracker
+	s.Init(dccp.NewAmb("sender", env), true)
+	lf, err := s.OnRead(&dccp.FeedbackHeader{Type: g.Type, SeqNo: g.SeqNo, AckNo: g.AckNo, Options: g.Options})
+	if err != nil {
+		t.Fatalf("reading feedback (%s)", err)
+	}
+	if lf.Misreported || lf.NewLossCount != 2 {
+		t.Errorf("expecting 2 new losses and no misreport, got %d and %v", lf.NewLossCount, lf.Misreported)
+	}
+}
+
+// TestWireStrictSender checks that a strict sender attaches no options to its packets, where a
+// default sender reports its RTT estimate in the private Roundtrip Report option
+func TestWireStrictSender(t *testing.T) {
+	env := dccp.NewEnv(nil)
+	for _, strict := range []bool{false, true} {
+		s := newSender(env, dccp.NewAmb("sender", env), &Config{StrictRFC4342: strict})
+		s.Open()
+		var private bool
+		for seqNo := int64(1); seqNo <= 10; seqNo++ {
+			_, opts := s.OnWrite(&dccp.PreHeader{Type: dccp.Data, X: true, SeqNo: seqNo, TimeWrite: 1e9 + seqNo*10e6, DataLen: 100})
+			for _, opt := range opts {
+				private = private || bytes.IndexByte([]byte{OptionRoundtripReport, OptionLossDigest}, opt.Type) >= 0
+			}
+		}
+		s.Close()
+		if private == strict {
+			t.Errorf("strict=%v sender: private options sent=%v", strict, private)
+		}
+	}
+}
@@ -24,6 +24,11 @@ type senderRateCalculator struct {
 	ss          uint32 // Last known value of segment size
 	rtt         int64  // Last known value of round-trip time estimate
 
+	// Oscillation prevention (RFC 5348, Section 4.5)
+	reduceOscillations bool    // True if oscillation prevention is enabled
+	rSqmean            float64 // Long-term average of the square root of the RTT samples, or zero if unset
+	xInst              uint32  // Instantaneous sending rate, in bytes per second
+
 	xRecvSet           // Data structure for x_recv_set (see RFC 5348)
 }
 
@@ -32,13 +37,20 @@ const (
 	X_MAX_BACKOFF_INTERVAL  = 64e9           // Maximum backoff interval in ns (See RFC 5348, Section 4.3)
 	X_RECV_MAX              = math.MaxInt32  // Maximum receive rate, in bytes per second
 	X_RECV_SET_SIZE         = 3              // Size of x_recv_set
+	X_SQMEAN_WEIGHT_OLD     = 0.9            // Weight q2 of the old R_sqmean (See RFC 5348, Section 4.5)
 )
 
+// XInstSample is the name of the series of samples of the instantaneous sending rate, as a
+// percentage of the allowed sending rate, which are emitted while oscillation prevention is on
+const XInstSample = "X-Inst"
+
 // Init resets the rate calculator for new use and returns the initial 
 // allowed sending rate (in bytes per second). The latter is the rate
 // to be used before the first feedback packet is received and hence before
 // an RTT estimate is available.
-func (t *senderRateCalculator) Init(amb *dccp.Amb, ss uint32, rtt int64) {
+// If reduceOscillations is set, the sending rate follows the oscillation prevention of RFC
+// 5348, Section 4.5.
+func (t *senderRateCalculator) Init(amb *dccp.Amb, ss uint32, rtt int64, reduceOscillations bool) {
 	t.amb = amb.Refine("senderRateCalculator")
 	// The allowed sending rate before the first feedback packet is received
 	// is one packet per second.
@@ -56,6 +68,9 @@ func (t *senderRateCalculator) Init(amb *dccp.Amb, ss uint32, rtt int64) {
 	t.lossRateInv = UnknownLossEventRateInv
 	t.ss = ss
 	t.rtt = rtt
+	t.reduceOscillations = reduceOscillations
+	t.rSqmean = 0
+	t.xInst = t.x
 	t.xRecvSet.Init()
 }
 
@@ -83,15 +98,18 @@ func initRate(ss uint32, rtt int64) uint32 {
 // XFeedback contains computed feedback variables that are used by the rate calculator to update the
 // allowed sending rate
 type XFeedback struct {
-	Now   int64   // Time now
-	SS    uint32  // Segment size
-	XRecv uint32  // Receive rate
-	RTT   int64   // Round-trip time
-	LossFeedback  // Loss-related feedback
+	Now         int64  // Time now
+	SS          uint32 // Segment size
+	XRecv       uint32 // Receive rate
+	RTT         int64  // Round-trip time
+	RTTSample   int64  // Latest round-trip time sample, or zero if none
+	DataLimited bool   // True if the interval covered by the feedback was data-limited
+	Hold        bool   // True if the allowed sending rate must not increase, following a Slow Receiver option
+	LossFeedback       // Loss-related feedback
 }
 
 // Sender calls OnRead each time a new feedback packet (i.e. Ack or DataAck) arrives.
-// OnRead returns the new allowed sending in bytes per second.
+// OnRead returns the new instantaneous sending rate in bytes per second.
 func (t *senderRateCalculator) OnRead(f *XFeedback) uint32 {
 	if f.LossFeedback.RateInv < 1 {
 		panic("invalid loss rate inverse")
@@ -102,10 +120,13 @@ func (t *senderRateCalculator) OnRead(f *XFeedback) uint32 {
 	now := f.Now
 
 	if t.tld <= 0 {
-		return t.onFirstRead(now)
+		return t.oscillate(f.RTTSample, t.onFirstRead(now))
 	}
-	// TODO: We currently don't honor data-limited periods
-	if false /* the entire interval covered by the feedback packet was a data-limited interval */ {
+	x0 := t.x
+	// A data-limited sender keeps the highest receive rate of the recent past, so that
+	// the receive rates reported after a quiet period do not collapse its allowed rate
+	// (RFC 5348, Section 8.2)
+	if f.DataLimited {
 		if f.LossFeedback.RateInc || f.LossFeedback.NewLossCount > 0 {
 			t.xRecvSet.Halve()
 			f.XRecv = (85 * f.XRecv) / 100
@@ -119,7 +140,12 @@ func (t *senderRateCalculator) OnRead(f *XFeedback) uint32 {
 		t.xRecvSet.Update(now, f.XRecv, t.rtt)
 		t.recvLimit = 2 * t.xRecvSet.Max()
 	}
-	return t.recalculate(now)
+	x := t.recalculate(now)
+	// A slow receiver asks the sender not to increase its rate (RFC 4342, Section 5.2)
+	if f.Hold && x > x0 {
+		t.x, t.xInst, x = x0, x0, x0
+	}
+	return t.oscillate(f.RTTSample, x)
 }
 
 func (t *senderRateCalculator) recalculate(now int64) uint32 {
@@ -132,10 +158,36 @@ func (t *senderRateCalculator) recalculate(now int64) uint32 {
 		t.x = maxu32(minu32(2*t.x, t.recvLimit), initRate(t.ss, t.rtt))
 		t.tld = now
 	}
-	// TODO: Place oscillation reduction code here (see RFC 5348, Section 4.3)
+	t.xInst = t.x
 	return t.x
 }
 
+// oscillate returns the instantaneous sending rate, given the allowed sending rate x and the
+// latest RTT sample rttSample. If oscillation prevention is enabled, the instantaneous rate is
+// lowered when the RTT sample exceeds the long-term average RTT, as described in RFC 5348,
+// Section 4.5:
+//
+//   R_sqmean = q2*R_sqmean + (1-q2)*sqrt(R_sample)
+//   X_inst = X * R_sqmean / sqrt(R_sample)
+//
+func (t *senderRateCalculator) oscillate(rttSample int64, x uint32) uint32 {
+	t.xInst = x
+	if !t.reduceOscillations || rttSample <= 0 {
+		return t.xInst
+	}
+	sqrtSample := math.Sqrt(float64(rttSample))
+	if t.rSqmean == 0 {
+		t.rSqmean = sqrtSample
+	} else {
+		t.rSqmean = X_SQMEAN_WEIGHT_OLD*t.rSqmean + (1-X_SQMEAN_WEIGHT_OLD)*sqrtSample
+	}
+	xInst := uint32(float64(x) * t.rSqmean / sqrtSample)
+	t.xInst = maxu32(xInst, minRate(t.ss))
+	t.amb.E(dccp.EventInfo, fmt.Sprintf("X_inst=%d, X=%d", t.xInst, x),
+		dccp.NewSample(XInstSample, 100*float64(t.xInst)/float64(x), "%"))
+	return t.xInst
+}
+
 // Sender calls OnNoFeedback when the no feedback timer expires.
 // OnNoFeedback returns the new allowed sending rate.
 // See RFC 5348, Section 4.4
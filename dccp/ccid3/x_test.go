@@ -0,0 +1,49 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package ccid3
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// TestSlowReceiverHold checks that a Slow Receiver option holds the allowed sending rate, while
+// Data Dropped reports halve the receive rate it is computed from
+func TestSlowReceiverHold(t *testing.T) {
+	const (
+		ss  = 1000
+		rtt = 1e8
+	)
+	var calc senderRateCalculator
+	calc.Init(dccp.NewAmb("test", dccp.NewEnv(nil)), ss, rtt, false)
+	f := func(now int64, xrecv uint32, hold bool) *XFeedback {
+		return &XFeedback{
+			Now:          now,
+			SS:           ss,
+			XRecv:        xrecv,
+			RTT:          rtt,
+			Hold:         hold,
+			LossFeedback: LossFeedback{RateInv: UnknownLossEventRateInv},
+		}
+	}
+	x0 := calc.OnRead(f(1e9, 1e6, false))
+
+	// In slow-start, the rate would double after one RTT, but a slow receiver holds it
+	if x := calc.OnRead(f(1e9+rtt, 1e6, true)); x != x0 {
+		t.Errorf("slow receiver: expecting rate %d, got %d", x0, x)
+	}
+	if x := calc.OnRead(f(1e9+2*rtt, 1e6, false)); x != 2*x0 {
+		t.Errorf("after slow receiver: expecting rate %d, got %d", 2*x0, x)
+	}
+
+	// One packet per RTT is 1e4 bytes per second
+	var xrecv uint32 = 1e6
+	if x := adjustReceiveRate(xrecv, 0, ss, rtt); x != xrecv {
+		t.Errorf("no drops: expecting receive rate %d, got %d", xrecv, x)
+	}
+	if x := adjustReceiveRate(xrecv, 1, ss, rtt); x != (xrecv-1e4)/2 {
+		t.Errorf("one drop: expecting receive rate %d, got %d", (xrecv-1e4)/2, x)
+	}
+}
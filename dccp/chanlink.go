@@ -9,15 +9,23 @@ import (
 	"time"
 )
 
-// ChanLink treats one side of a channel as an incoming packet link
+// ChanLink treats one side of a channel as an incoming packet link. ChanLink implements
+// ECNLink. It can emulate a congested router, which marks ECN-capable packets, see SetMarkCE.
 type ChanLink struct {
 	Mutex
-	in, out chan []byte
+	in, out chan chanPacket
+	markCE  bool
+}
+
+// chanPacket is a packet in transit on a ChanLink, along with its ECN codepoint
+type chanPacket struct {
+	Data []byte
+	ECN  byte
 }
 
 func NewChanPipe() (p, q *ChanLink) {
-	c0 := make(chan []byte)
-	c1 := make(chan []byte)
+	c0 := make(chan chanPacket)
+	c1 := make(chan chanPacket)
 	return &ChanLink{in: c0, out: c1}, &ChanLink{in: c1, out: c0}
 }
 
@@ -25,6 +33,14 @@ func (l *ChanLink) GetMTU() int {
 	return 1500
 }
 
+// SetMarkCE determines whether ECN-capable packets written to this side of the link are
+// marked Congestion Experienced before they are delivered
+func (l *ChanLink) SetMarkCE(markCE bool) {
+	l.Lock()
+	defer l.Unlock()
+	l.markCE = markCE
+}
+
 func (l *ChanLink) SetReadDeadline(t time.Time) error {
 	// SetReadDeadline does not apply because ChanLink returns
 	// an EIO error if no input is available
@@ -33,27 +49,41 @@ func (l *ChanLink) SetReadDeadline(t time.Time) error {
 }
 
 func (l *ChanLink) ReadFrom(buf []byte) (n int, addr net.Addr, err error) {
+	n, addr, _, err = l.ReadFromECN(buf)
+	return n, addr, err
+}
+
+// ReadFromECN implements ECNLink.ReadFromECN
+func (l *ChanLink) ReadFromECN(buf []byte) (n int, addr net.Addr, ecn byte, err error) {
 	l.Lock()
 	in := l.in
 	l.Unlock()
 	if in == nil {
-		return 0, nil, ErrBad
+		return 0, nil, 0, ErrBad
 	}
 
 	p, ok := <-in
 	if !ok {
-		return 0, nil, ErrIO
+		return 0, nil, 0, ErrIO
 	}
-	n = copy(buf, p)
-	if n != len(p) {
+	n = copy(buf, p.Data)
+	if n != len(p.Data) {
 		panic("insufficient buf len")
 	}
-	return len(p), nil, nil
+	return len(p.Data), nil, p.ECN, nil
 }
 
 func (l *ChanLink) WriteTo(buf []byte, addr net.Addr) (n int, err error) {
+	return l.WriteToECN(buf, addr, ECNNotECT)
+}
+
+// WriteToECN implements ECNLink.WriteToECN
+func (l *ChanLink) WriteToECN(buf []byte, addr net.Addr, ecn byte) (n int, err error) {
 	l.Lock()
 	out := l.out
+	if l.markCE && isECNCapable(ecn) {
+		ecn = ECNCE
+	}
 	l.Unlock()
 	if out == nil {
 		return 0, ErrBad
@@ -61,7 +91,7 @@ func (l *ChanLink) WriteTo(buf []byte, addr net.Addr) (n int, err error) {
 
 	p := make([]byte, len(buf))
 	copy(p, buf)
-	out <- p
+	out <- chanPacket{Data: p, ECN: ecn}
 	return len(buf), nil
 }
 
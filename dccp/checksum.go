@@ -39,7 +39,7 @@ func csumSum(buf []byte) uint16 {
 	for i := 0; i < l16; i++ {
 		sum = csumAdd(sum, csumBytesToUint16(buf[2*i:2*i+2]))
 	}
-	if (l16 << 2) < len(buf) {
+	if (l16 << 1) < len(buf) {
 		two := make([]byte, 2)
 		two[0] = buf[len(buf)-1]
 		two[1] = 0
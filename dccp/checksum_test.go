@@ -37,3 +37,11 @@ func TestChecksum(t *testing.T) {
 	}
 
 }
+
+// TestChecksumOddTail checks that the last byte of an odd-size buffer is summed as the high
+// byte of a 16-bit word, padded with zero, RFC 1071
+func TestChecksumOddTail(t *testing.T) {
+	if sum := csumSum([]byte{0x12, 0x34, 0x56}); sum != 0x1234+0x5600 {
+		t.Errorf("expecting sum %04x, got %04x", 0x1234+0x5600, sum)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// Config holds the parameters of new connections. Fields left at their zero value take the
+// default value, which is noted for each field. See Stack.SetConfig and Stack.DialConfig.
+type Config struct {
+
+	// Env is the runtime shared by the connections, which determines their notion of time
+	// and receives their traces. By default, each connection runs in a new Env.
+	Env *Env
+
+	// TraceWriter receives the traces of connections that run in a new Env. It is ignored if
+	// Env is set. By default, connections are not traced.
+	TraceWriter TraceWriter
+
+	// CCIDArgs are passed to the NewSender and NewReceiver methods of the congestion controls.
+	// By default, no arguments are passed.
+	CCIDArgs []interface{}
+
+	// Heartbeat is the interval in ns, after which an idle connection in OPEN sends an Ack,
+	// so that the other side and the path see some traffic. The interval is also advised
+	// to the sender congestion control. By default, there are no heartbeats.
+	Heartbeat int64
+
+	// RequestTimeout is the time in ns, during which a client resends its Request and a
+	// server waits for one. It defaults to REQUEST_BACKOFF_TIMEOUT.
+	RequestTimeout int64
+
+	// RespondTimeout is the time in ns, during which a server waits in RESPOND for the client
+	// to acknowledge its Response. It defaults to RESPOND_TIMEOUT.
+	RespondTimeout int64
+
+	// ReadBuffer is the number of received data packets, which are queued until they are
+	// read by the application. It defaults to 5.
+	ReadBuffer int
+
+	// WriteBuffer is the number of written data packets, which are queued until the
+	// congestion control allows them to be sent. By default, Write blocks until its data is
+	// handed to the write loop.
+	WriteBuffer int
+}
+
+const defaultReadBuffer = 5
+
+// override returns a copy of x, whose fields are replaced by the ones set in y
+func (x *Config) override(y *Config) *Config {
+	var z Config
+	if x != nil {
+		z = *x
+	}
+	if y == nil {
+		return &z
+	}
+	if y.Env != nil {
+		z.Env = y.Env
+	}
+	if y.TraceWriter != nil {
+		z.TraceWriter = y.TraceWriter
+	}
+	if y.CCIDArgs != nil {
+		z.CCIDArgs = y.CCIDArgs
+	}
+	if y.Heartbeat != 0 {
+		z.Heartbeat = y.Heartbeat
+	}
+	if y.RequestTimeout != 0 {
+		z.RequestTimeout = y.RequestTimeout
+	}
+	if y.RespondTimeout != 0 {
+		z.RespondTimeout = y.RespondTimeout
+	}
+	if y.ReadBuffer != 0 {
+		z.ReadBuffer = y.ReadBuffer
+	}
+	if y.WriteBuffer != 0 {
+		z.WriteBuffer = y.WriteBuffer
+	}
+	return &z
+}
+
+// resolve returns a copy of x, whose unset fields are replaced by their default values
+func (x *Config) resolve() Config {
+	var z Config
+	if x != nil {
+		z = *x
+	}
+	if z.RequestTimeout <= 0 {
+		z.RequestTimeout = REQUEST_BACKOFF_TIMEOUT
+	}
+	if z.RespondTimeout <= 0 {
+		z.RespondTimeout = RESPOND_TIMEOUT
+	}
+	if z.ReadBuffer <= 0 {
+		z.ReadBuffer = defaultReadBuffer
+	}
+	if z.WriteBuffer < 0 {
+		z.WriteBuffer = 0
+	}
+	return z
+}
+
+// newEnvAmb returns the runtime and the logging context of a new connection, whose traces
+// are labeled label
+func (x *Config) newEnvAmb(label string) (*Env, *Amb) {
+	if x == nil || (x.Env == nil && x.TraceWriter == nil) {
+		return NewEnv(nil), NoLogging
+	}
+	env := x.Env
+	if env == nil {
+		env = NewEnv(x.TraceWriter)
+	}
+	return env, NewAmb(label, env)
+}
@@ -0,0 +1,28 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import "testing"
+
+func TestConfigOverride(t *testing.T) {
+	stack := &Config{Heartbeat: 1e9, ReadBuffer: 10, CCIDArgs: []interface{}{1}}
+	cfg := stack.override(&Config{Heartbeat: 2e9, RequestTimeout: 5e9})
+	if cfg.Heartbeat != 2e9 || cfg.RequestTimeout != 5e9 || cfg.ReadBuffer != 10 || len(cfg.CCIDArgs) != 1 {
+		t.Errorf("override gives %#v", cfg)
+	}
+	if stack.Heartbeat != 1e9 || stack.RequestTimeout != 0 {
+		t.Errorf("override modifies the stack config")
+	}
+
+	r := (*Config)(nil).resolve()
+	if r.RequestTimeout != REQUEST_BACKOFF_TIMEOUT || r.RespondTimeout != RESPOND_TIMEOUT ||
+		r.ReadBuffer != defaultReadBuffer || r.WriteBuffer != 0 || r.Heartbeat != 0 {
+
+		t.Errorf("defaults are %#v", r)
+	}
+	if r = cfg.resolve(); r.RequestTimeout != 5e9 || r.ReadBuffer != 10 {
+		t.Errorf("resolve gives %#v", r)
+	}
+}
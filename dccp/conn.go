@@ -4,30 +4,63 @@
 
 package dccp
 
+import "sync"
+
 // Conn 
 type Conn struct {
 	env   *Env
 	amb   *Amb
 
 	hc    HeaderConn
+	ccids []CCID // Supported congestion controls, in order of preference. Set under lock in LISTEN.
+	config Config // Parameters of the connection, with defaults filled in
 	scc   SenderCongestionControl
 	rcc   ReceiverCongestionControl
 
-	Mutex                       // Protects access to socket, ccidOpen and err
+	Mutex                       // Protects access to all fields below, up to readAppLk, as well as scc and rcc
 	socket
 	ccidOpen       bool         // True if the sender and receiver CCID's have been opened
 	err            error        // Reason for connection tear down
+	ackVector      ackVectorBuffer // Acknowledgement buffer, used when Send Ack Vector is on
+	dataDropped    dataDroppedBuffer  // Received packets whose data did not reach the application
+	dropReports    dataDroppedReports // Sent packets whose data the other side reported as dropped
+	ecnNonces      ecnNonceBuffer // ECN Nonces of sent packets, used to verify Nonce Echoes
+	feature        featureSet   // Feature values and their negotiation state
+	seqWindow      int64        // Minimum local Sequence Window, requested by the application
+	initCookies    []*Option    // Init Cookies of the server's Response, echoed by the client in PARTOPEN
+	ndpCount       uint64       // Number of consecutive non-data packets sent most recently
+	slowReceiver   bool         // Whether the application has declared itself a slow receiver
+	slowReceiverTime int64      // Time when the other side last sent a Slow Receiver option
+	csCov          byte         // Checksum coverage of written data, requested by the application
+	pmtu           pmtuSearch   // Path MTU Discovery, Section 14
+	dataTime       int64        // Time when the latest Data or DataAck packet was sent or received
+	staleCount     int64        // Number of blocks of data discarded because their expiry passed before they were sent
+	strobeHeld     bool         // Set if the last strobe went unused by stale data; accessed only by writeLoop
+	services       func(uint32) []CCID // Congestion controls of the Service Codes accepted by a server, or nil if all are accepted
+	listenDone     chan int     // Closed when a server leaves LISTEN, once its Service Code is known
 
 	readAppLk      Mutex
-	readApp        chan []byte  // readLoop() sends application data to Read()
+	readApp        chan *appData // readLoop() sends application data to Read()
 	writeDataLk    Mutex
-	writeData      chan []byte  // Write() sends application data to writeLoop()
+	writeData      chan *appData // Write() sends application data to writeLoop()
+	writeDataDone  chan int     // Closed before writeData, so that blocked calls to Write let go of writeDataLk
+	writeDataOnce  sync.Once
 	writeNonDataLk Mutex
 	writeNonData   chan *writeHeader // inject() sends wire-format non-Data packets (higher priority) to writeLoop()
 
+	readExpire     deadline     // Expiration time of calls to Read
+	writeExpire    deadline     // Expiration time of calls to Write
+
 	writeTime      monotoneTime
 }
 
+// appData is a block of application data, passed between the user calls and the loops
+type appData struct {
+	Data     []byte
+	CsCov    byte  // Checksum coverage, with which the data is sent or was received
+	Deadline int64 // Time after which written data is discarded, rather than sent, or zero if none
+}
+
 // Joiner returns a Joiner instance that can wait until all goroutines
 // associated with the connection have completed.
 func (c *Conn) Joiner() Joiner {
@@ -39,62 +72,156 @@ func (c *Conn) Amb() *Amb {
 	return c.amb
 }
 
-func newConn(env *Env, amb *Amb, hc HeaderConn, scc SenderCongestionControl, rcc ReceiverCongestionControl) *Conn {
+func newConn(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, cfg *Config) *Conn {
+	if len(ccids) == 0 {
+		panic("no congestion control")
+	}
+	config := cfg.resolve()
 	c := &Conn{
 		env:          env,
 		amb:          amb,
 		hc:           hc,
-		scc:          scc,
-		rcc:          rcc,
+		ccids:        ccids,
+		config:       config,
 		ccidOpen:     false,
-		readApp:      make(chan []byte, 5),
-		writeData:    make(chan []byte),
+		readApp:      make(chan *appData, config.ReadBuffer),
+		writeData:    make(chan *appData, config.WriteBuffer),
+		writeDataDone: make(chan int),
 		writeNonData: make(chan *writeHeader, 5),
 	}
 	c.writeTime.Init(env)
+	c.readExpire.Init(env)
+	c.writeExpire.Init(env)
 
 	c.Lock()
-	// Currently, CCID is not negotiated, rather both sides use the same
-	c.socket.SetCCIDA(scc.GetID())
-	c.socket.SetCCIDB(rcc.GetID())
+	c.ackVector.Init()
+	c.dataDropped.Init()
+	c.dropReports.Init()
+	c.ecnNonces.Init()
+	c.feature.Init()
+	initFeaturePrefs(&c.feature, ccids)
+	c.pmtu.Init()
 
-	// REMARK: SWAF/SWBF are currently not implemented. 
-	// Instead, we use wide enough fixed-size windows
-	c.socket.SetSWAF(SEQWIN_FIXED)
-	c.socket.SetSWBF(SEQWIN_FIXED)
+	c.seqWindow = SEQWIN_FIXED
 
+	c.syncWithFeatures()
 	c.syncWithLink()
 	c.syncWithCongestionControl()
+	c.syncSequenceWindow()
 	c.Unlock()
 
 	return c
 }
 
-func NewConnServer(env *Env, amb *Amb, hc HeaderConn, 
-	scc SenderCongestionControl, rcc ReceiverCongestionControl) *Conn {
+// initFeaturePrefs sets the feature preferences of a new connection, which supports the
+// congestion controls ccids
+func initFeaturePrefs(f *featureSet, ccids []CCID) {
+	initCCIDPrefs(f, ccids)
+
+	// We send Ack Vectors and NDP Counts if the other side asks for them
+	f.SetPrefs(FeatureSendAckVector, true, []byte{0, 1})
+	f.SetPrefs(FeatureSendNDPCount, true, []byte{0, 1})
+
+	// We refrain from ECN if the other side cannot read ECN codepoints
+	f.SetPrefs(FeatureECNIncapable, false, []byte{0, 1})
+
+	// We send partial checksum coverage only as far as the other side accepts it. Higher
+	// minimums come first, since they are the more conservative choice.
+	prefs := make([]byte, 16)
+	for i := range prefs {
+		prefs[i] = byte(15 - i)
+	}
+	f.SetPrefs(FeatureMinCsCov, false, prefs)
+}
+
+// initCCIDPrefs makes both half-connections accept any of the congestion controls ccids
+func initCCIDPrefs(f *featureSet, ccids []CCID) {
+	prefs := make([]byte, len(ccids))
+	for i, ccid := range ccids {
+		prefs[i] = ccid.GetID()
+	}
+	f.SetPrefs(FeatureCCID, true, prefs)
+	f.SetPrefs(FeatureCCID, false, prefs)
+}
+
+// NewConnServer creates a server-side connection over hc, which accepts any of the
+// congestion controls ccids. The server's preferences prevail during CCID negotiation.
+func NewConnServer(env *Env, amb *Amb, hc HeaderConn, ccids []CCID) *Conn {
+	return newConnServer(env, amb, hc, ccids, nil, nil)
+}
+
+// newConnServer is like NewConnServer, except that the congestion controls are chosen by the
+// Service Code of the client's Request. If services is not nil, it returns the congestion
+// controls supported by a Service Code, or nil if the Service Code is not accepted. Requests for
+// such Service Codes are answered with Bad Service Code Resets, Section 8.1.2. The connection
+// is configured by cfg, or by default if cfg is nil.
+func newConnServer(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, services func(uint32) []CCID, cfg *Config) *Conn {
 
-	c := newConn(env, amb, hc, scc, rcc)
+	c := newConn(env, amb, hc, ccids, cfg)
 
 	c.Lock()
+	c.services = services
+	c.listenDone = make(chan int)
 	c.gotoLISTEN()
 	c.Unlock()
 
-	c.env.Go(func() { c.writeLoop(c.writeNonData, c.writeData) }, "ConnServer·writLoop")
+	// The queues are passed before the connection can be closed, which clears them
+	writeNonData, writeData := c.writeNonData, c.writeData
+	c.env.Go(func() { c.writeLoop(writeNonData, writeData) }, "ConnServer·writLoop")
+	c.env.Go(func() { c.readLoop() }, "ConnServer·readLoop")
+	c.env.Go(func() { c.idleLoop() }, "ConnServer·idleLoop")
+	return c
+}
+
+// newConnServerCookie creates a server-side connection over hc, whose handshake was answered
+// statelessly, see AcceptStateless. The connection state is restored from the verified Init
+// Cookie ck. The connection starts in RESPOND and reads the client packet h, which echoed the
+// cookie, before any packets from hc. The connection is configured by cfg.
+func newConnServerCookie(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, ck *initCookie, h *Header, cfg *Config) *Conn {
+
+	c := newConn(env, amb, &replayHeaderConn{HeaderConn: hc, h: h}, ccids, cfg)
+
+	c.Lock()
+	c.socket.SetServer(true)
+	c.feature.SetValues(ck.Features)
+	c.syncWithFeatures()
+	c.syncSequenceWindow()
+	c.gotoRESPOND(ck.ServiceCode, ck.ISS, ck.ISR)
+	// The Response has already been sent
+	c.socket.SetGSS(ck.ISS)
+	c.Unlock()
+
+	// The queues are passed before the connection can be closed, which clears them
+	writeNonData, writeData := c.writeNonData, c.writeData
+	c.env.Go(func() { c.writeLoop(writeNonData, writeData) }, "ConnServer·writLoop")
 	c.env.Go(func() { c.readLoop() }, "ConnServer·readLoop")
 	c.env.Go(func() { c.idleLoop() }, "ConnServer·idleLoop")
 	return c
 }
 
-func NewConnClient(env *Env, amb *Amb, hc HeaderConn, 
-	scc SenderCongestionControl, rcc ReceiverCongestionControl, serviceCode uint32) *Conn {
+// NewConnClient creates a client-side connection over hc, which offers the congestion
+// controls ccids, in order of preference, for both half-connections.
+func NewConnClient(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, serviceCode uint32) *Conn {
+	return newConnClient(env, amb, hc, ccids, serviceCode, nil)
+}
+
+// newConnClient is like NewConnClient, except that the connection is configured by cfg, or by
+// default if cfg is nil
+func newConnClient(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, serviceCode uint32, cfg *Config) *Conn {
 
-	c := newConn(env, amb, hc, scc, rcc)
+	c := newConn(env, amb, hc, ccids, cfg)
 
 	c.Lock()
+	// The client opens the CCID negotiation. The connection is reset, if the
+	// server supports none of the offered CCIDs.
+	c.feature.Change(FeatureCCID, true, true)
+	c.feature.Change(FeatureCCID, false, true)
 	c.gotoREQUEST(serviceCode)
 	c.Unlock()
 
-	c.env.Go(func() { c.writeLoop(c.writeNonData, c.writeData) }, "ConnClient·writeLoop")
+	// The queues are passed before the connection can be closed, which clears them
+	writeNonData, writeData := c.writeNonData, c.writeData
+	c.env.Go(func() { c.writeLoop(writeNonData, writeData) }, "ConnClient·writeLoop")
 	c.env.Go(func() { c.readLoop() }, "ConnClient·readLoop")
 	c.env.Go(func() { c.idleLoop() }, "ConnClient·idleLoop")
 	return c
@@ -0,0 +1,138 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+)
+
+const (
+	COOKIE_TIMEOUT = RESPOND_TIMEOUT // Lifetime of an Init Cookie, in ns
+
+	cookieKeyLen     = 32 // Length of randomly chosen secret keys
+	cookieMACLen     = 16 // Length of the truncated HMAC-SHA256 carried by an Init Cookie
+	cookieHeaderLen  = 8 + 6 + 6 + 4
+	cookieFeatureLen = 1 + 1 + 6
+)
+
+// initCookie holds the connection state, which a stateless server wraps in the Init Cookie
+// option of its Response instead of keeping it, Section 8.1.4
+type initCookie struct {
+	Time        int64          // Time when the cookie was issued, in ns
+	ISS         int64          // Initial Sequence number Sent by the server
+	ISR         int64          // Initial Sequence number Received from the client
+	ServiceCode uint32         // Service Code of the Request
+	Features    []featureValue // Feature values reconciled while processing the Request
+}
+
+// CookieJar issues and verifies Init Cookies. Each cookie carries a message authentication
+// code, computed with a secret key over the cookie contents and the labels of the flow it was
+// issued on. Cookies that have been tampered with, that are echoed on a different flow, or
+// that are older than the cookie lifetime are rejected.
+type CookieJar struct {
+	key      []byte
+	lifetime int64
+}
+
+// NewCookieJar creates a new CookieJar, which uses the secret key. If key is nil, a random
+// key is chosen.
+func NewCookieJar(key []byte) *CookieJar {
+	if key == nil {
+		key = make([]byte, cookieKeyLen)
+		if _, err := rand.Read(key); err != nil {
+			panic("choosing cookie key")
+		}
+	}
+	return &CookieJar{
+		key:      append([]byte{}, key...),
+		lifetime: COOKIE_TIMEOUT,
+	}
+}
+
+// mac computes the truncated HMAC-SHA256 of the data d, on the flow with the given local
+// and remote labels
+func (j *CookieJar) mac(d []byte, local, remote Bytes) []byte {
+	m := hmac.New(sha256.New, j.key)
+	for _, label := range []Bytes{local, remote} {
+		if label != nil {
+			m.Write(label.Bytes())
+		}
+	}
+	m.Write(d)
+	return m.Sum(nil)[:cookieMACLen]
+}
+
+// chooseISS derives the server's Initial Sequence Number from the client's, so that a
+// retransmitted Request is answered with the same sequence number
+func (j *CookieJar) chooseISS(isr int64, local, remote Bytes) int64 {
+	v := DecodeUint48(j.mac(encodeFeatureValue(uint64(isr), 6), local, remote)[:6])
+	return int64(v%(1<<48-1)) + 1
+}
+
+// encode wraps ck in an Init Cookie option, issued on the flow with the given labels
+func (j *CookieJar) encode(ck *initCookie, local, remote Bytes) (*Option, error) {
+	d := make([]byte, 0, cookieHeaderLen+cookieFeatureLen*len(ck.Features)+cookieMACLen)
+	d = append(d, encodeFeatureValue(uint64(ck.Time), 8)...)
+	d = append(d, encodeFeatureValue(uint64(ck.ISS), 6)...)
+	d = append(d, encodeFeatureValue(uint64(ck.ISR), 6)...)
+	d = append(d, encodeFeatureValue(uint64(ck.ServiceCode), 4)...)
+	for _, v := range ck.Features {
+		var isLocal byte
+		if v.Local {
+			isLocal = 1
+		}
+		d = append(d, v.Number, isLocal)
+		d = append(d, encodeFeatureValue(v.Value, 6)...)
+	}
+	d = append(d, j.mac(d, local, remote)...)
+	if len(d) > 255-2 {
+		return nil, ErrOversize
+	}
+	return &Option{Type: OptionInitCookie, Data: d}, nil
+}
+
+// decode verifies the Init Cookie option opt, echoed at time now on the flow with the given
+// labels, and returns the connection state it carries
+func (j *CookieJar) decode(opt *Option, now int64, local, remote Bytes) (*initCookie, error) {
+	d := opt.Data
+	if opt.Type != OptionInitCookie || len(d) < cookieHeaderLen+cookieMACLen ||
+		(len(d)-cookieHeaderLen-cookieMACLen)%cookieFeatureLen != 0 {
+
+		return nil, ErrCookie
+	}
+	body := d[:len(d)-cookieMACLen]
+	if !hmac.Equal(d[len(body):], j.mac(body, local, remote)) {
+		return nil, ErrCookie
+	}
+	ck := &initCookie{
+		Time:        int64(decodeFeatureValue(body[0:8])),
+		ISS:         int64(decodeFeatureValue(body[8:14])),
+		ISR:         int64(decodeFeatureValue(body[14:20])),
+		ServiceCode: uint32(decodeFeatureValue(body[20:24])),
+	}
+	if now < ck.Time || now-ck.Time > j.lifetime {
+		return nil, ErrCookie
+	}
+	for p := body[cookieHeaderLen:]; len(p) > 0; p = p[cookieFeatureLen:] {
+		v := featureValue{Number: p[0], Local: p[1] == 1, Value: decodeFeatureValue(p[2:cookieFeatureLen])}
+		if !v.isValid() {
+			return nil, ErrCookie
+		}
+		ck.Features = append(ck.Features, v)
+	}
+	return ck, nil
+}
+
+// findInitCookie returns the first Init Cookie option in opts, or nil if there is none
+func findInitCookie(opts []*Option) *Option {
+	for _, opt := range opts {
+		if opt.Type == OptionInitCookie {
+			return opt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInitCookie(t *testing.T) {
+	jar := NewCookieJar([]byte("secret"))
+	local, remote := &Label{}, ChooseLabel()
+	ck := &initCookie{
+		Time:        1e9,
+		ISS:         500,
+		ISR:         100,
+		ServiceCode: 7,
+		Features: []featureValue{
+			{FeatureCCID, true, CCID3},
+			{FeatureSequenceWindow, false, 1024},
+		},
+	}
+	opt, err := jar.encode(ck, local, remote)
+	if err != nil {
+		t.Fatalf("encoding cookie (%s)", err)
+	}
+	dec, err := jar.decode(opt, 2e9, local, remote)
+	if err != nil {
+		t.Fatalf("decoding cookie (%s)", err)
+	}
+	if dec.Time != ck.Time || dec.ISS != ck.ISS || dec.ISR != ck.ISR || dec.ServiceCode != ck.ServiceCode ||
+		len(dec.Features) != len(ck.Features) {
+
+		t.Fatalf("cookie decodes to %v", dec)
+	}
+	for i, v := range dec.Features {
+		if v != ck.Features[i] {
+			t.Errorf("feature %d: expecting %v, got %v", i, ck.Features[i], v)
+		}
+	}
+
+	// Tampered cookies
+	for i := range opt.Data {
+		d := append([]byte{}, opt.Data...)
+		d[i] ^= 1
+		if _, err := jar.decode(&Option{Type: OptionInitCookie, Data: d}, 2e9, local, remote); err != ErrCookie {
+			t.Errorf("accepted cookie with byte %d modified", i)
+		}
+	}
+	// Cookies echoed on another flow, or with another key
+	if _, err := jar.decode(opt, 2e9, local, ChooseLabel()); err != ErrCookie {
+		t.Errorf("accepted cookie on another flow")
+	}
+	if _, err := NewCookieJar(nil).decode(opt, 2e9, local, remote); err != ErrCookie {
+		t.Errorf("accepted cookie with another key")
+	}
+	// Expired cookies
+	if _, err := jar.decode(opt, 1e9+COOKIE_TIMEOUT+1, local, remote); err != ErrCookie {
+		t.Errorf("accepted expired cookie")
+	}
+}
+
+// testHeaderConn is a HeaderConn, whose reads and writes are carried out on channels
+type testHeaderConn struct {
+	read   chan *Header
+	write  chan *Header
+	expire time.Duration
+	mtu    int
+}
+
+func newTestHeaderConn() *testHeaderConn {
+	return &testHeaderConn{read: make(chan *Header, 5), write: make(chan *Header, 5), mtu: 1500}
+}
+
+func (hc *testHeaderConn) GetMTU() int { return hc.mtu }
+
+func (hc *testHeaderConn) Read() (*Header, error) {
+	select {
+	case h := <-hc.read:
+		return h, nil
+	case <-time.After(hc.expire):
+		return nil, ErrTimeout
+	}
+	panic("unreach")
+}
+
+func (hc *testHeaderConn) Write(h *Header) error {
+	hc.write <- h
+	return nil
+}
+
+func (hc *testHeaderConn) LocalLabel() Bytes  { return &Label{} }
+func (hc *testHeaderConn) RemoteLabel() Bytes { return &Label{} }
+
+func (hc *testHeaderConn) SetReadExpire(nsec int64) error {
+	hc.expire = time.Duration(nsec)
+	return nil
+}
+
+func (hc *testHeaderConn) Close() error { return nil }
+
+func TestAcceptStatelessBadCookie(t *testing.T) {
+	hc := newTestHeaderConn()
+	done := make(chan error, 1)
+	go func() {
+		_, err := AcceptStateless(NewEnv(nil), NoLogging, hc, []CCID{CCFixed{}}, NewCookieJar(nil))
+		done <- err
+	}()
+
+	// An Ack without a cookie does not find a connection
+	hc.read <- &Header{Type: Ack, X: true, SeqNo: 101, AckNo: 500}
+	if g := <-hc.write; g.Type != Reset || g.ResetCode != ResetNoConnection {
+		t.Fatalf("expecting No Connection reset, got %v", g)
+	}
+
+	req := &Header{Type: Request, X: true, SeqNo: 100, ServiceCode: 7}
+	for _, typ := range []byte{OptionChangeL, OptionChangeR} {
+		opt, _ := (&FeatureOption{Type: typ, Feature: FeatureCCID, Values: []byte{CCID_FIXED}}).Encode()
+		req.Options = append(req.Options, opt)
+	}
+	hc.read <- req
+	resp := <-hc.write
+	if resp.Type != Response || resp.AckNo != req.SeqNo {
+		t.Fatalf("expecting response, got %v", resp)
+	}
+	opt := findInitCookie(resp.Options)
+	if opt == nil {
+		t.Fatalf("response carries no init cookie")
+	}
+	var confirms int
+	for _, o := range resp.Options {
+		if fo := DecodeFeatureOption(o); fo != nil && fo.Feature == FeatureCCID && !isOptionChange(fo.Type) {
+			confirms++
+		}
+	}
+	if confirms != 2 {
+		t.Errorf("expecting 2 CCID confirm options, got %d", confirms)
+	}
+	// A retransmitted Request is answered with the same sequence number
+	hc.read <- req
+	if g := <-hc.write; g.Type != Response || g.SeqNo != resp.SeqNo {
+		t.Errorf("retransmitted request answered with seqno %d, expecting %d", g.SeqNo, resp.SeqNo)
+	}
+
+	d := append([]byte{}, opt.Data...)
+	d[0] ^= 1
+	hc.read <- &Header{
+		Type:    Ack,
+		X:       true,
+		SeqNo:   101,
+		AckNo:   resp.SeqNo,
+		Options: []*Option{&Option{Type: OptionInitCookie, Data: d}},
+	}
+	if g := <-hc.write; g.Type != Reset || g.ResetCode != ResetBadInitCookie {
+		t.Errorf("expecting Bad Init Cookie reset, got %v", g)
+	}
+	if err := <-done; err != ErrCookie {
+		t.Errorf("expecting cookie error, got %v", err)
+	}
+}
+
+// TestMuxStateless checks that a Mux with a statelessListener answers Requests without creating
+// flows, and accepts a flow only once a valid Init Cookie is echoed on it
+func TestMuxStateless(t *testing.T) {
+	alink, dlink := NewChanPipe()
+	am := NewMux(alink)
+	l := &statelessListener{
+		env:    NewEnv(nil),
+		amb:    NoLogging,
+		jar:    NewCookieJar(nil),
+		lookup: func(uint32) []CCID { return []CCID{CCFixed{}} },
+	}
+	am.setListener(l)
+	flows := func() int {
+		am.Lock()
+		defer am.Unlock()
+		return len(am.flowsLocal) + len(am.flowsRemote)
+	}
+	bc, _ := NewMux(dlink).Dial(nil)
+	hc := NewHeaderConn(bc)
+	hc.SetReadExpire(1e9)
+
+	req := &Header{Type: Request, X: true, SeqNo: 100, ServiceCode: 7}
+	for _, typ := range []byte{OptionChangeL, OptionChangeR} {
+		opt, _ := (&FeatureOption{Type: typ, Feature: FeatureCCID, Values: []byte{CCID_FIXED}}).Encode()
+		req.Options = append(req.Options, opt)
+	}
+	var resp *Header
+	// A retransmitted Request is answered on the same flow with the same sequence number
+	for i := 0; i < 2; i++ {
+		if err := hc.Write(req); err != nil {
+			t.Fatalf("writing request (%s)", err)
+		}
+		g, err := hc.Read()
+		if err != nil || g.Type != Response || g.AckNo != req.SeqNo {
+			t.Fatalf("expecting response, got %v (%v)", g, err)
+		}
+		if resp != nil && g.SeqNo != resp.SeqNo {
+			t.Errorf("retransmitted request answered with seqno %d, expecting %d", g.SeqNo, resp.SeqNo)
+		}
+		resp = g
+	}
+	if n := flows(); n != 0 {
+		t.Errorf("requests created %d flow entries", n)
+	}
+	opt := findInitCookie(resp.Options)
+	if opt == nil {
+		t.Fatalf("response carries no init cookie")
+	}
+
+	ack := &Header{Type: Ack, X: true, SeqNo: 101, AckNo: resp.SeqNo}
+	d := append([]byte{}, opt.Data...)
+	d[0] ^= 1
+	ack.Options = []*Option{&Option{Type: OptionInitCookie, Data: d}}
+	hc.Write(ack)
+	if g, err := hc.Read(); err != nil || g.Type != Reset || g.ResetCode != ResetBadInitCookie {
+		t.Errorf("expecting Bad Init Cookie reset, got %v (%v)", g, err)
+	}
+	if n := flows(); n != 0 {
+		t.Errorf("bad cookie created %d flow entries", n)
+	}
+
+	ack.Options = []*Option{opt}
+	hc.Write(ack)
+	abc, err := am.Accept()
+	if err != nil {
+		t.Fatalf("accept (%s)", err)
+	}
+	if n := flows(); n != 2 {
+		t.Errorf("expecting 2 flow entries, got %d", n)
+	}
+	c, err := l.accept(NewEnv(nil), NoLogging, NewHeaderConn(abc), nil)
+	if err != nil {
+		t.Fatalf("creating connection (%s)", err)
+	}
+	c.Lock()
+	iss := c.socket.GetISS()
+	c.Unlock()
+	if iss != resp.SeqNo {
+		t.Errorf("connection has ISS %d, expecting %d", iss, resp.SeqNo)
+	}
+	c.Abort()
+}
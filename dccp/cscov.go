@@ -0,0 +1,46 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// getCsCov returns the CsCov value of an outgoing packet with dataLen bytes of application
+// data, whose checksum coverage csCov was requested by the application, Section 9.2. Partial
+// coverage is raised to the minimum accepted by the other side, Minimum Checksum Coverage/B,
+// and full coverage is used instead if the other side does not accept partial coverage or if
+// the requested coverage exceeds the data.
+func (c *Conn) getCsCov(csCov byte, dataLen int) byte {
+	c.AssertLocked()
+	if csCov == CsCovAllData {
+		return CsCovAllData
+	}
+	min := c.socket.GetMinCsCovB()
+	if min == 0 {
+		return CsCovAllData
+	}
+	if csCov < min {
+		csCov = min
+	}
+	if _, err := getChecksumAppCoverage(csCov, dataLen); err != nil {
+		return CsCovAllData
+	}
+	return csCov
+}
+
+// isCsCovAcceptable returns false if the incoming packet h has partial checksum coverage below
+// the minimum accepted by this endpoint, Minimum Checksum Coverage/A, Section 9.2.1
+func (c *Conn) isCsCovAcceptable(h *Header) bool {
+	c.AssertLocked()
+	if h.CsCov == CsCovAllData {
+		return true
+	}
+	min := c.socket.GetMinCsCovA()
+	return min != 0 && h.CsCov >= min
+}
+
+// isCsCovPartial returns true if the checksum coverage csCov leaves some of dataLen bytes of
+// application data uncovered
+func isCsCovPartial(csCov byte, dataLen int) bool {
+	cov, err := getChecksumAppCoverage(csCov, dataLen)
+	return err == nil && cov < dataLen
+}
@@ -0,0 +1,48 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import "hash/crc32"
+
+// DataChecksumOption, Section 9.3
+// Data Checksum holds the CRC-32c of the application data of the packet carrying it
+type DataChecksumOption struct {
+	CRC uint32
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// NewDataChecksumOption returns a Data Checksum option for the application data data
+func NewDataChecksumOption(data []byte) *DataChecksumOption {
+	return &DataChecksumOption{CRC: crc32.Checksum(data, crc32cTable)}
+}
+
+func (opt *DataChecksumOption) Encode() (*Option, error) {
+	d := make([]byte, 4)
+	EncodeUint32(opt.CRC, d)
+	return &Option{
+		Type:      OptionDataChecksum,
+		Data:      d,
+		Mandatory: false,
+	}, nil
+}
+
+func DecodeDataChecksumOption(opt *Option) *DataChecksumOption {
+	if opt.Type != OptionDataChecksum || len(opt.Data) != 4 {
+		return nil
+	}
+	return &DataChecksumOption{CRC: DecodeUint32(opt.Data)}
+}
+
+// isDataCorrupt returns true if h carries a Data Checksum option that does not match its
+// application data
+func isDataCorrupt(h *Header) bool {
+	for _, opt := range h.Options {
+		if dc := DecodeDataChecksumOption(opt); dc != nil {
+			return *dc != *NewDataChecksumOption(h.Data)
+		}
+	}
+	return false
+}
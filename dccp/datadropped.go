@@ -0,0 +1,150 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// DataDroppedOption, Section 11.7
+// Data Dropped reports received packets, whose application data did not reach the application,
+// and the reason why. The first block refers to the packet indicated in the Acknowledgement
+// Number of the carrying packet; subsequent blocks refer to older packets.
+type DataDroppedOption struct {
+	// Blocks lists the blocks of packets, from the newest packet to the oldest.
+	Blocks []DataDroppedBlock
+}
+
+// DataDroppedBlock describes Length consecutive packets. Packets in a Normal Block had their
+// data delivered to the application, or have not been received. Packets in a Drop Block were
+// not delivered as usual, for the reason given by DropCode.
+type DataDroppedBlock struct {
+	Dropped  bool
+	DropCode byte
+	Length   int64 // Number of packets in the block, at least 1
+}
+
+// Drop Codes, Section 11.7, Table 7
+const (
+	DropProtocolConstraints = 0
+	DropAppNotListening     = 1
+	DropReceiveBuffer       = 2
+	DropCorrupt             = 3
+	DropDeliveredCorrupt    = 7
+)
+
+const (
+	// DataDroppedMaxLen is the maximum length of the vector of a single Data Dropped option, in bytes
+	DataDroppedMaxLen = 255 - 2
+
+	// DataDroppedMaxNormalRun is the maximum number of packets described by a single Normal Block byte
+	DataDroppedMaxNormalRun = 128
+
+	// DataDroppedMaxDropRun is the maximum number of packets described by a single Drop Block byte
+	DataDroppedMaxDropRun = 8
+)
+
+func (opt *DataDroppedOption) Encode() (*Option, error) {
+	d := make([]byte, 0, 8)
+	for _, b := range opt.Blocks {
+		if b.Length < 1 || b.DropCode > 7 {
+			return nil, ErrOption
+		}
+		for l := b.Length; l > 0; l -= b.maxRun() {
+			if b.Dropped {
+				d = append(d, 0x80|(b.DropCode<<4)|byte(min64(l, DataDroppedMaxDropRun)-1))
+			} else {
+				d = append(d, byte(min64(l, DataDroppedMaxNormalRun)-1))
+			}
+		}
+	}
+	if len(d) == 0 {
+		return nil, ErrOption
+	}
+	if len(d) > DataDroppedMaxLen {
+		return nil, ErrOversize
+	}
+	return &Option{
+		Type:      OptionDataDropped,
+		Data:      d,
+		Mandatory: false,
+	}, nil
+}
+
+// maxRun returns the maximum number of packets that a single byte of block b can describe
+func (b *DataDroppedBlock) maxRun() int64 {
+	if b.Dropped {
+		return DataDroppedMaxDropRun
+	}
+	return DataDroppedMaxNormalRun
+}
+
+// encodedLen returns the number of vector bytes needed to encode block b
+func (b *DataDroppedBlock) encodedLen() int {
+	return int((b.Length + b.maxRun() - 1) / b.maxRun())
+}
+
+// DecodeDataDroppedOption decodes a Data Dropped option. Adjacent vector bytes of the same
+// kind are merged into a single block.
+func DecodeDataDroppedOption(opt *Option) *DataDroppedOption {
+	if opt.Type != OptionDataDropped || len(opt.Data) == 0 {
+		return nil
+	}
+	return decodeDataDroppedVector(opt.Data)
+}
+
+func decodeDataDroppedVector(d []byte) *DataDroppedOption {
+	r := &DataDroppedOption{Blocks: make([]DataDroppedBlock, 0, len(d))}
+	for _, v := range d {
+		var b DataDroppedBlock
+		if v&0x80 == 0 {
+			b.Length = int64(v) + 1
+		} else {
+			b.Dropped, b.DropCode, b.Length = true, (v>>4)&0x7, int64(v&0x7)+1
+		}
+		k := len(r.Blocks)
+		if k > 0 && r.Blocks[k-1].Dropped == b.Dropped && r.Blocks[k-1].DropCode == b.DropCode {
+			r.Blocks[k-1].Length += b.Length
+		} else {
+			r.Blocks = append(r.Blocks, b)
+		}
+	}
+	return r
+}
+
+// FindDataDroppedOption returns the combined Data Dropped options in opts, or nil if there
+// are none. Each Data Dropped option begins where the previous one left off.
+func FindDataDroppedOption(opts []*Option) *DataDroppedOption {
+	var d []byte
+	for _, opt := range opts {
+		if opt.Type == OptionDataDropped {
+			d = append(d, opt.Data...)
+		}
+	}
+	if len(d) == 0 {
+		return nil
+	}
+	return decodeDataDroppedVector(d)
+}
+
+// DataDroppedRange describes the packets with sequence numbers between Lo and Hi, inclusive,
+// whose data was dropped for the reason DropCode
+type DataDroppedRange struct {
+	Lo, Hi   int64
+	DropCode byte
+}
+
+// Drops returns the sequence number ranges of the Drop Blocks of the option, from newest to
+// oldest, assuming that the option was received on a packet with Acknowledgement Number ackNo.
+// Ranges that reach below sequence number zero continue from the top of the sequence space,
+// so Lo may exceed Hi numerically.
+func (opt *DataDroppedOption) Drops(ackNo int64) []DataDroppedRange {
+	var r []DataDroppedRange
+	hi := ackNo
+	for _, b := range opt.Blocks {
+		lo := SeqAdd(hi, 1-b.Length)
+		if b.Dropped {
+			r = append(r, DataDroppedRange{Lo: lo, Hi: hi, DropCode: b.DropCode})
+		}
+		hi = SeqAdd(lo, -1)
+	}
+	return r
+}
@@ -0,0 +1,133 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+func TestDataDroppedOption(t *testing.T) {
+	// The example from Section 11.7
+	opt := DecodeDataDroppedOption(&Option{Type: OptionDataDropped, Data: []byte{0, 160, 3, 162}})
+	if opt == nil {
+		t.Fatalf("error decoding data dropped option")
+	}
+	expect := []DataDroppedRange{{99, 99, DropReceiveBuffer}, {92, 94, DropReceiveBuffer}}
+	drops := opt.Drops(100)
+	if len(drops) != len(expect) {
+		t.Fatalf("expecting %d drop ranges, got %d", len(expect), len(drops))
+	}
+	for i, d := range drops {
+		if d != expect[i] {
+			t.Errorf("drop range %d: expecting %v, got %v", i, expect[i], d)
+		}
+	}
+
+	// Blocks longer than a single vector byte
+	opt = &DataDroppedOption{
+		Blocks: []DataDroppedBlock{{false, 0, 130}, {true, DropCorrupt, 9}, {true, DropDeliveredCorrupt, 1}},
+	}
+	enc, err := opt.Encode()
+	if err != nil {
+		t.Fatalf("error encoding data dropped option (%s)", err)
+	}
+	if len(enc.Data) != 5 {
+		t.Fatalf("unexpected encoding length %d", len(enc.Data))
+	}
+	dec := FindDataDroppedOption([]*Option{&Option{Type: OptionPadding}, enc})
+	if dec == nil || len(dec.Blocks) != len(opt.Blocks) {
+		t.Fatalf("data dropped option decodes to %v", dec)
+	}
+	for i, b := range dec.Blocks {
+		if b != opt.Blocks[i] {
+			t.Errorf("block %d: expecting %v, got %v", i, opt.Blocks[i], b)
+		}
+	}
+}
+
+func TestDataDroppedBuffer(t *testing.T) {
+	var b dataDroppedBuffer
+	b.Init()
+	if b.makeOption(10) != nil {
+		t.Errorf("data dropped option without drops")
+	}
+	b.OnDrop(5, DropReceiveBuffer)
+	b.OnDrop(6, DropReceiveBuffer)
+	b.OnDrop(9, DropCorrupt)
+	b.OnDrop(12, DropReceiveBuffer)
+
+	opt := b.makeOption(10)
+	expect := []DataDroppedRange{{9, 9, DropCorrupt}, {5, 6, DropReceiveBuffer}}
+	drops := opt.Drops(10)
+	if len(drops) != len(expect) {
+		t.Fatalf("expecting %d drop ranges, got %v", len(expect), drops)
+	}
+	for i, d := range drops {
+		if d != expect[i] {
+			t.Errorf("drop range %d: expecting %v, got %v", i, expect[i], d)
+		}
+	}
+	b.OnWrite(100, 10, opt)
+
+	// A drop reported late is not forgotten with the reports that preceded it. Neither are
+	// newer drops from the same report.
+	b.OnDrop(7, DropAppNotListening)
+	b.OnAck(100)
+	drops = b.makeOption(12).Drops(12)
+	if len(drops) != 3 || drops[0].Lo != 12 || drops[1].Lo != 9 || drops[2].Lo != 7 {
+		t.Errorf("unexpected drops after ack %v", drops)
+	}
+
+	// The sender counts every drop once
+	var r dataDroppedReports
+	r.Init()
+	if fresh := r.OnRead(opt, 10); len(fresh) != 2 {
+		t.Errorf("expecting 2 new drop ranges, got %v", fresh)
+	}
+	if fresh := r.OnRead(b.makeOption(12), 12); len(fresh) != 2 {
+		t.Errorf("expecting 2 new drop ranges, got %v", fresh)
+	}
+	if counts := r.Counts(); counts[DropReceiveBuffer] != 3 || counts[DropCorrupt] != 1 || counts[DropAppNotListening] != 1 {
+		t.Errorf("unexpected drop counts %v", counts)
+	}
+}
+
+func TestDataDroppedLostAck(t *testing.T) {
+	var b dataDroppedBuffer
+	b.Init()
+	b.OnDrop(5, DropReceiveBuffer)
+
+	// Our packet 100, which reports the drop, is lost. The other side acknowledges our later
+	// packet 101 instead, which carried no option.
+	b.OnWrite(100, 10, b.makeOption(10))
+	b.OnAck(101)
+	opt := b.makeOption(11)
+	if drops := opt.Drops(11); len(drops) != 1 || drops[0].Lo != 5 || drops[0].Hi != 5 {
+		t.Fatalf("expecting the drop to be reported again, got %v", drops)
+	}
+
+	// Once a packet carrying the report is acknowledged, the drop is forgotten
+	b.OnWrite(102, 11, opt)
+	b.OnAck(102)
+	if opt := b.makeOption(12); opt != nil {
+		t.Errorf("acknowledged drop reported again %v", opt.Drops(12))
+	}
+	if len(b.acks) != 0 {
+		t.Errorf("expecting no ack records, got %d", len(b.acks))
+	}
+}
+
+func TestDataChecksumOption(t *testing.T) {
+	h := &Header{Type: Data, Data: []byte("application data")}
+	opt, _ := NewDataChecksumOption(h.Data).Encode()
+	h.Options = []*Option{opt}
+	if isDataCorrupt(h) {
+		t.Errorf("intact data reported corrupt")
+	}
+	h.Data[0] ^= 1
+	if !isDataCorrupt(h) {
+		t.Errorf("corrupt data not detected")
+	}
+}
@@ -4,49 +4,192 @@
 
 package dccp
 
-import "net"
+import (
+	"net"
+	"sync"
+)
 
 type Stack struct {
-	mux  *Mux
-	link Link
-	ccid CCID
+	mux         headerMux
+	link        Link
+	ccids       []CCID
+	seqWindow   int64              // Minimum Sequence Window of new connections, or zero for the default
+	shortSeqNos bool               // Whether new connections allow short sequence numbers
+	listener    *statelessListener // Answers handshakes, if they are answered statelessly
+	config      *Config            // Parameters of new connections, or nil for the defaults
+
+	serviceLk   sync.Mutex
+	services    map[uint32]*service // Registered services, by Service Code, or nil if none were registered
+	routeOnce   sync.Once
+	routeErr    error      // Error that ended the routing of new connections
+	routeDone   chan int   // Closed when no more connections are routed
+}
+
+// headerMux dials and accepts the HeaderConns, which the connections of a Stack run on
+type headerMux interface {
+	Accept() (HeaderConn, error)
+	Dial(addr net.Addr) (HeaderConn, error)
+	localAddr() net.Addr
+	setListener(l *statelessListener)
+}
+
+// flowMux is the headerMux over the flows of a Mux
+type flowMux struct {
+	*Mux
+}
+
+func (m flowMux) Accept() (HeaderConn, error) {
+	bc, err := m.Mux.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewHeaderConn(bc), nil
+}
+
+func (m flowMux) Dial(addr net.Addr) (HeaderConn, error) {
+	bc, err := m.Mux.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewHeaderConn(bc), nil
+}
+
+// NewStack creates a new connection-handling object. Connections support the congestion
+// controls ccids, given in order of preference, and negotiate which one is used.
+func NewStack(link Link, ccids ...CCID) *Stack {
+	return &Stack{
+		mux:   flowMux{NewMux(link)},
+		link:  link,
+		ccids: ccids,
+	}
 }
 
-// NewStack creates a new connection-handling object.
-func NewStack(link Link, ccid CCID) *Stack {
+// NewEncapStack is like NewStack, except that connections are encapsulated in DCCP-UDP,
+// RFC 6773, as described in EncapMux, so that they interoperate with other implementations.
+// The addresses passed to Dial must be *EncapAddrs.
+func NewEncapStack(link Link, ccids ...CCID) *Stack {
 	return &Stack{
-		mux:  NewMux(link),
-		link: link,
-		ccid: ccid,
+		mux:   NewEncapMux(link),
+		link:  link,
+		ccids: ccids,
+	}
+}
+
+// SetSequenceWindow sets the minimum Sequence Window that subsequently created connections
+// negotiate for the packets they send. Applications expecting high sending rates over paths
+// with long round-trip times should set a window of several times the number of packets sent
+// per round-trip time. See Conn.SetSequenceWindow.
+func (s *Stack) SetSequenceWindow(w int64) {
+	s.seqWindow = w
+}
+
+// SetAllowShortSeqNos sets whether subsequently created connections are willing to use
+// short sequence numbers. See Conn.SetAllowShortSeqNos.
+func (s *Stack) SetAllowShortSeqNos(allow bool) {
+	s.shortSeqNos = allow
+}
+
+// SetCookieJar makes Accept answer connection handshakes statelessly, using Init Cookies
+// issued by jar, so that no connection state is allocated until a client completes the
+// handshake. Requests are answered by the underlying mux, and a flow is only created once the
+// client echoes a valid cookie. See AcceptStateless. SetCookieJar must be called before the
+// first Accept. The answers are traced as configured by SetConfig at the time of the call.
+func (s *Stack) SetCookieJar(jar *CookieJar) {
+	env, amb := s.config.newEnvAmb("server")
+	s.listener = &statelessListener{
+		env: env,
+		amb: amb,
+		jar: jar,
+		lookup: func(serviceCode uint32) []CCID {
+			return lookupService(s.getServices(), s.ccids, serviceCode)
+		},
+	}
+	s.mux.setListener(s.listener)
+}
+
+// SetConfig sets the parameters of the connections subsequently created by Dial and Accept.
+// DialConfig can override them for individual connections. A nil cfg restores the defaults.
+func (s *Stack) SetConfig(cfg *Config) {
+	s.config = new(Config).override(cfg)
+}
+
+// configure applies the stack settings to a new connection
+func (s *Stack) configure(conn *Conn) {
+	if s.seqWindow > 0 {
+		conn.SetSequenceWindow(s.seqWindow)
+	}
+	if s.shortSeqNos {
+		conn.SetAllowShortSeqNos(true)
 	}
 }
 
 // Dial initiates a new connection to the specified Link-layer address.
 func (s *Stack) Dial(addr net.Addr, serviceCode uint32) (c SegmentConn, err error) {
-	bc, err := s.mux.Dial(addr)
+	conn, err := s.DialConfig(addr, serviceCode, nil)
 	if err != nil {
 		return nil, err
 	}
-	hc := NewHeaderConn(bc)
-	env := NewEnv(nil)
-	c = NewConnClient(env, NoLogging, hc, 
-		s.ccid.NewSender(env, NoLogging),
-		s.ccid.NewReceiver(env, NoLogging), 
-		serviceCode)
-	return c, nil
+	return conn, nil
+}
+
+// DialConfig is like Dial, except that the fields set in cfg override the parameters of the
+// Stack, see SetConfig, for this connection only
+func (s *Stack) DialConfig(addr net.Addr, serviceCode uint32, cfg *Config) (*Conn, error) {
+	hc, err := s.mux.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg = s.config.override(cfg)
+	env, amb := cfg.newEnvAmb("client")
+	conn := newConnClient(env, amb, hc, s.ccids, serviceCode, cfg)
+	s.configure(conn)
+	return conn, nil
 }
 
 // Accept blocks until a new connecion is established. It then
-// returns the connection.
+// returns the connection. Accept cannot be used once services are registered, see Handle.
 func (s *Stack) Accept() (c SegmentConn, err error) {
-	bc, err := s.mux.Accept()
+	if s.isRouting() {
+		return nil, ErrInvalid
+	}
+	conn, err := s.accept()
 	if err != nil {
 		return nil, err
 	}
-	hc := NewHeaderConn(bc)
-	env := NewEnv(nil)
-	c = NewConnServer(env, NoLogging, hc, 
-		s.ccid.NewSender(env, NoLogging), 
-		s.ccid.NewReceiver(env, NoLogging))
-	return c, nil
+	return conn, nil
+}
+
+// accept returns the next server-side connection. Unless its handshake was answered
+// statelessly, the connection may still be waiting for the client's Request.
+func (s *Stack) accept() (*Conn, error) {
+	if s.listener != nil {
+		return s.acceptStateless()
+	}
+	hc, err := s.mux.Accept()
+	if err != nil {
+		return nil, err
+	}
+	env, amb := s.config.newEnvAmb("server")
+	conn := newConnServer(env, amb, hc, s.ccids, s.getServices(), s.config)
+	s.configure(conn)
+	return conn, nil
+}
+
+// acceptStateless returns the next connection, whose stateless handshake has completed.
+// Flows, whose first packet no longer passes the listener, are skipped.
+func (s *Stack) acceptStateless() (*Conn, error) {
+	for {
+		hc, err := s.mux.Accept()
+		if err != nil {
+			return nil, err
+		}
+		env, amb := s.config.newEnvAmb("server")
+		conn, err := s.listener.accept(env, amb, hc, s.config)
+		if err != nil {
+			continue
+		}
+		s.configure(conn)
+		return conn, nil
+	}
+	panic("unreach")
 }
@@ -0,0 +1,60 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// deadline is the expiration time of a blocking call, such as Read or Write. Calls blocked
+// while the deadline changes observe the new deadline. deadline's methods are re-entrant.
+type deadline struct {
+	env     *Env
+	Mutex
+	t       int64         // Expiration time in Env time, or zero if there is none
+	changed chan struct{} // Closed and replaced whenever t changes
+}
+
+// Init prepares the deadline for use without an expiration time
+func (d *deadline) Init(env *Env) {
+	d.env = env
+	d.t = 0
+	d.changed = make(chan struct{})
+}
+
+// Set sets the expiration time to t, given in Env time. A zero t removes the expiration time.
+// A t in the past expires blocked and subsequent calls immediately.
+func (d *deadline) Set(t int64) {
+	d.Lock()
+	defer d.Unlock()
+	d.t = t
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// Wait returns a channel that is closed when the deadline expires and one that is closed when
+// the deadline changes. The function stop releases the resources associated with the channels
+// and must be called when they are no longer needed.
+func (d *deadline) Wait() (expired, changed <-chan struct{}, stop func()) {
+	d.Lock()
+	t, changed := d.t, d.changed
+	d.Unlock()
+	if t == 0 {
+		return nil, changed, func() {}
+	}
+	if timeout := t - d.env.Now(); timeout > 0 {
+		expired, stop = d.env.Timer(timeout)
+		return expired, changed, stop
+	}
+	ch := make(chan struct{})
+	close(ch)
+	return ch, changed, func() {}
+}
+
+// isExpired returns true if the channel expired, returned by Wait, has been closed
+func isExpired(expired <-chan struct{}) bool {
+	select {
+	case <-expired:
+		return true
+	default:
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// dataDroppedBuffer remembers the packets received from the other side, whose application
+// data did not reach the application, and produces the Data Dropped options sent on outgoing
+// acknowledgements. Like the Ack Vector, Data Dropped information is transmitted reliably: a
+// drop is reported until the other side acknowledges a packet that carried its report.
+type dataDroppedBuffer struct {
+	drops []dataDrop          // Dropped packets, in increasing order of SeqNo
+	acks  []dataDroppedRecord // Acks carrying Data Dropped options, in increasing order of SeqNo
+}
+
+// dataDrop records the Drop Code of a single dropped packet
+type dataDrop struct {
+	SeqNo    int64
+	DropCode byte
+}
+
+// dataDroppedRecord remembers an outgoing acknowledgement that carried a Data Dropped option
+type dataDroppedRecord struct {
+	SeqNo  int64 // Sequence number of the packet that carried the option
+	Lo, Hi int64 // Oldest and newest packets whose drops have been reported by the option
+}
+
+const (
+	// dataDroppedMaxDrops is the maximum number of dropped packets remembered
+	dataDroppedMaxDrops = 256
+
+	// dataDroppedMaxRecords is the maximum number of unacknowledged acks remembered
+	dataDroppedMaxRecords = 256
+)
+
+// Init resets the buffer for new use
+func (b *dataDroppedBuffer) Init() {
+	b.drops = nil
+	b.acks = nil
+}
+
+// OnDrop records that the data of the packet with sequence number seqNo was dropped for the
+// reason dropCode. The first report of a packet's drop is retained.
+func (b *dataDroppedBuffer) OnDrop(seqNo int64, dropCode byte) {
+	// An ack that has not reported this drop must not allow it to be forgotten
+	for j := range b.acks {
+		if SeqInWindow(seqNo, b.acks[j].Lo, b.acks[j].Hi) {
+			b.acks[j].Hi = SeqAdd(seqNo, -1)
+		}
+	}
+	i := len(b.drops)
+	for i > 0 && SeqLessEq(seqNo, b.drops[i-1].SeqNo) {
+		i--
+	}
+	if i < len(b.drops) && b.drops[i].SeqNo == seqNo {
+		return
+	}
+	b.drops = append(b.drops, dataDrop{})
+	copy(b.drops[i+1:], b.drops[i:])
+	b.drops[i] = dataDrop{SeqNo: seqNo, DropCode: dropCode}
+	if len(b.drops) > dataDroppedMaxDrops {
+		b.drops = append(b.drops[:0], b.drops[1:]...)
+	}
+}
+
+// makeOption returns a Data Dropped option describing the dropped packets up to the packet with
+// sequence number ackNo. If there are none, makeOption returns nil.
+func (b *dataDroppedBuffer) makeOption(ackNo int64) *DataDroppedOption {
+	opt := &DataDroppedOption{}
+	n := 0        // Number of vector bytes needed to encode opt
+	next := ackNo // Newest packet not yet described by opt
+	for i := len(b.drops) - 1; i >= 0; i-- {
+		d := b.drops[i]
+		if SeqLess(ackNo, d.SeqNo) {
+			continue
+		}
+		if SeqLess(d.SeqNo, next) && !opt.add(DataDroppedBlock{Length: SeqDist(d.SeqNo, next)}, &n) {
+			break
+		}
+		if !opt.add(DataDroppedBlock{Dropped: true, DropCode: d.DropCode, Length: 1}, &n) {
+			break
+		}
+		next = SeqAdd(d.SeqNo, -1)
+	}
+	for k := len(opt.Blocks); k > 0 && !opt.Blocks[k-1].Dropped; k-- {
+		opt.Blocks = opt.Blocks[:k-1]
+	}
+	if len(opt.Blocks) == 0 {
+		return nil
+	}
+	return opt
+}
+
+// add appends block blk to opt, merging it into the last block if they are of the same kind.
+// It returns false and leaves opt unchanged, if the vector length n would exceed the maximum.
+func (opt *DataDroppedOption) add(blk DataDroppedBlock, n *int) bool {
+	k := len(opt.Blocks)
+	if k > 0 && opt.Blocks[k-1].Dropped == blk.Dropped && opt.Blocks[k-1].DropCode == blk.DropCode {
+		merged := opt.Blocks[k-1]
+		merged.Length += blk.Length
+		m := *n - opt.Blocks[k-1].encodedLen() + merged.encodedLen()
+		if m > DataDroppedMaxLen {
+			return false
+		}
+		opt.Blocks[k-1], *n = merged, m
+		return true
+	}
+	if *n+blk.encodedLen() > DataDroppedMaxLen {
+		return false
+	}
+	opt.Blocks = append(opt.Blocks, blk)
+	*n += blk.encodedLen()
+	return true
+}
+
+// OnWrite records that the outgoing packet with sequence number seqNo carries the Data Dropped
+// option opt, which was produced by makeOption(ackNo)
+func (b *dataDroppedBuffer) OnWrite(seqNo, ackNo int64, opt *DataDroppedOption) {
+	if len(b.acks) >= dataDroppedMaxRecords {
+		b.acks = append(b.acks[:0], b.acks[1:]...)
+	}
+	lo := SeqAdd(ackNo, 1)
+	for _, blk := range opt.Blocks {
+		lo = SeqAdd(lo, -blk.Length)
+	}
+	b.acks = append(b.acks, dataDroppedRecord{SeqNo: seqNo, Lo: lo, Hi: ackNo})
+}
+
+// OnAck is called when the other side acknowledges our packet with sequence number ackNo.
+// Acknowledgements are not cumulative, Section 11.4, so only an acknowledgement of a packet
+// that carried a Data Dropped option shows that the other side has received its reports. OnAck
+// then forgets the drops reported by that option, along with the record of that packet and all
+// older ones.
+func (b *dataDroppedBuffer) OnAck(ackNo int64) {
+	k := 0
+	for k < len(b.acks) && SeqLess(b.acks[k].SeqNo, ackNo) {
+		k++
+	}
+	if k == len(b.acks) || b.acks[k].SeqNo != ackNo {
+		return
+	}
+	r := b.acks[k]
+	drops := b.drops[:0]
+	for _, d := range b.drops {
+		if !SeqInWindow(d.SeqNo, r.Lo, r.Hi) {
+			drops = append(drops, d)
+		}
+	}
+	b.drops = drops
+	b.acks = append(b.acks[:0], b.acks[k+1:]...)
+}
+
+// dataDroppedReports is the HC-Sender's counterpart of dataDroppedBuffer. Since the other side
+// reports each drop repeatedly, dataDroppedReports filters out the drops already seen, and
+// counts the newly reported ones by Drop Code.
+type dataDroppedReports struct {
+	recent []int64   // Sequence numbers of recently reported packets, in order of arrival
+	counts [8]int64  // Number of reported packets, indexed by Drop Code
+}
+
+// dataDroppedMaxRecent is the maximum number of reported packets remembered
+const dataDroppedMaxRecent = 2 * dataDroppedMaxDrops
+
+// Init resets the reports for new use
+func (r *dataDroppedReports) Init() {
+	r.recent = nil
+	r.counts = [8]int64{}
+}
+
+// OnRead processes the Data Dropped option opt, received on a packet with Acknowledgement
+// Number ackNo, and returns the ranges of packets that it newly reports as dropped
+func (r *dataDroppedReports) OnRead(opt *DataDroppedOption, ackNo int64) []DataDroppedRange {
+	var fresh []DataDroppedRange
+	for _, d := range opt.Drops(ackNo) {
+		for i := int64(0); i <= SeqDist(d.Lo, d.Hi); i++ {
+			seqNo := SeqAdd(d.Hi, -i)
+			if r.isRecent(seqNo) {
+				continue
+			}
+			if len(r.recent) >= dataDroppedMaxRecent {
+				r.recent = append(r.recent[:0], r.recent[1:]...)
+			}
+			r.recent = append(r.recent, seqNo)
+			r.counts[d.DropCode]++
+			k := len(fresh)
+			if k > 0 && fresh[k-1].DropCode == d.DropCode && fresh[k-1].Lo == SeqAdd(seqNo, 1) {
+				fresh[k-1].Lo = seqNo
+			} else {
+				fresh = append(fresh, DataDroppedRange{Lo: seqNo, Hi: seqNo, DropCode: d.DropCode})
+			}
+		}
+	}
+	return fresh
+}
+
+func (r *dataDroppedReports) isRecent(seqNo int64) bool {
+	for _, s := range r.recent {
+		if s == seqNo {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of packets reported dropped so far, indexed by Drop Code
+func (r *dataDroppedReports) Counts() [8]int64 {
+	return r.counts
+}
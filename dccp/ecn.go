@@ -0,0 +1,122 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// ECN codepoints of the IP header, RFC 3168 Section 5. The two ECN-Capable Transport
+// codepoints carry the ECN Nonce, RFC 3540: ECT(0) carries nonce 0 and ECT(1) carries nonce 1.
+const (
+	ECNNotECT = 0 // Not ECN-Capable Transport
+	ECNECT1   = 1 // ECN-Capable Transport, nonce 1
+	ECNECT0   = 2 // ECN-Capable Transport, nonce 0
+	ECNCE     = 3 // Congestion Experienced
+)
+
+// isECNCapable returns true if ecn is one of the ECN-Capable Transport codepoints
+func isECNCapable(ecn byte) bool {
+	return ecn == ECNECT0 || ecn == ECNECT1
+}
+
+// ecnNonce returns the ECN Nonce carried by a packet with codepoint ecn. Packets that are not
+// ECN-capable carry nonce zero, Section 12.2. A Congestion Experienced mark erases the nonce.
+func ecnNonce(ecn byte) byte {
+	if ecn == ECNECT1 {
+		return 1
+	}
+	return 0
+}
+
+// ecnNonceBuffer remembers the ECN Nonces of the packets recently sent by the HC-Sender, so
+// that the Nonce Echoes of the Ack Vectors sent back by the HC-Receiver can be verified,
+// Section 12.2. It also chooses the random nonces of outgoing packets.
+type ecnNonceBuffer struct {
+	init   bool   // Whether any packet has been recorded
+	tail   int64  // Sequence number of the oldest packet in nonces
+	nonces []byte // nonces[i] is the nonce of packet tail+i, or ecnNonceUnknown
+	pool   uint64 // Random bits not yet used as nonces
+	npool  int    // Number of bits left in pool
+}
+
+const (
+	// ecnNonceBufferMaxLen is the maximum number of nonces remembered. It is large enough to
+	// cover the packets described by a maximum-length Ack Vector, along with the packets sent
+	// while that Ack Vector is in flight.
+	ecnNonceBufferMaxLen = 2 * ackVectorBufferMaxLen
+
+	// ecnNonceUnknown marks packets, whose nonce has not been recorded
+	ecnNonceUnknown = 0xff
+)
+
+// Init resets the buffer for new use
+func (b *ecnNonceBuffer) Init() {
+	b.init = false
+	b.tail = 0
+	b.nonces = nil
+	b.pool = 0
+	b.npool = 0
+}
+
+// Choose returns an unpredictable ECN-Capable Transport codepoint for an outgoing packet
+func (b *ecnNonceBuffer) Choose(env *Env) byte {
+	if b.npool == 0 {
+		b.pool, b.npool = env.ChooseNonces(), 64
+	}
+	bit := b.pool & 1
+	b.pool >>= 1
+	b.npool--
+	if bit == 1 {
+		return ECNECT1
+	}
+	return ECNECT0
+}
+
+// head returns the sequence number of the newest packet in the buffer
+func (b *ecnNonceBuffer) head() int64 {
+	return SeqAdd(b.tail, int64(len(b.nonces))-1)
+}
+
+// OnWrite records that the outgoing packet with sequence number seqNo carries ECN Nonce nonce
+func (b *ecnNonceBuffer) OnWrite(seqNo int64, nonce byte) {
+	if !b.init || SeqLess(seqNo, b.tail) || SeqDist(b.head(), seqNo) > ecnNonceBufferMaxLen {
+		b.init = true
+		b.tail = seqNo
+		b.nonces = append(b.nonces[:0], nonce)
+		return
+	}
+	for SeqLess(b.head(), seqNo) {
+		b.nonces = append(b.nonces, ecnNonceUnknown)
+	}
+	b.nonces[SeqDist(b.tail, seqNo)] = nonce
+	if k := len(b.nonces) - ecnNonceBufferMaxLen; k > 0 {
+		b.nonces = append(b.nonces[:0], b.nonces[k:]...)
+		b.tail = SeqAdd(b.tail, int64(k))
+	}
+}
+
+// Verify returns false if the Nonce Echo of the Ack Vector av, received on a packet with
+// Acknowledgement Number ackNo, differs from the one-bit sum of the nonces of the packets that
+// av reports as received and not ECN marked. Ack Vectors describing packets, whose nonces are
+// not remembered, cannot be verified and are accepted.
+func (b *ecnNonceBuffer) Verify(av *AckVectorOption, ackNo int64) bool {
+	if !b.init {
+		return true
+	}
+	var sum byte
+	for _, r := range av.Ranges(ackNo) {
+		if r.State != AckVectorReceived {
+			continue
+		}
+		if !SeqInWindow(r.Lo, b.tail, b.head()) || !SeqInWindow(r.Hi, b.tail, b.head()) {
+			return true
+		}
+		for i := SeqDist(b.tail, r.Lo); i <= SeqDist(b.tail, r.Hi); i++ {
+			nonce := b.nonces[i]
+			if nonce == ecnNonceUnknown {
+				return true
+			}
+			sum ^= nonce
+		}
+	}
+	return sum == av.Nonce
+}
@@ -0,0 +1,77 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+// TestECNNonceEcho checks that the Nonce Echoes of the receiver's Ack Vectors are verified
+// by the sender's record of the nonces it sent
+func TestECNNonceEcho(t *testing.T) {
+	var sent ecnNonceBuffer
+	sent.Init()
+	var recv ackVectorBuffer
+	recv.Init()
+
+	// Packet 13 is lost and packet 15 is marked by the network
+	codepoints := []byte{ECNECT1, ECNECT0, ECNECT1, ECNECT1, ECNECT1, ECNECT1, ECNECT0}
+	for i, ecn := range codepoints {
+		seqNo := int64(10 + i)
+		sent.OnWrite(seqNo, ecnNonce(ecn))
+		switch seqNo {
+		case 13:
+		case 15:
+			recv.OnRead(seqNo, AckVectorECNMarked, ecnNonce(ECNCE))
+		default:
+			recv.OnRead(seqNo, AckVectorReceived, ecnNonce(ecn))
+		}
+	}
+
+	// The nonces of packets 10, 11, 12, 14 and 16 sum to one
+	av := recv.makeOption(16)
+	if av == nil || av.Nonce != 1 {
+		t.Fatalf("expecting nonce echo 1, got %v", av)
+	}
+	if !sent.Verify(av, 16) {
+		t.Errorf("correct nonce echo rejected")
+	}
+
+	// A receiver that conceals the mark of packet 15 must guess its nonce
+	concealed := &AckVectorOption{Nonce: av.Nonce}
+	for _, run := range av.Runs {
+		if run.State == AckVectorECNMarked {
+			run.State = AckVectorReceived
+		}
+		concealed.Runs = append(concealed.Runs, run)
+	}
+	if sent.Verify(concealed, 16) {
+		t.Errorf("concealed mark accepted")
+	}
+
+	// Ack Vectors describing forgotten packets cannot be verified
+	if !sent.Verify(&AckVectorOption{Nonce: 0, Runs: []AckVectorRun{{AckVectorReceived, 10}}}, 16) {
+		t.Errorf("unverifiable nonce echo rejected")
+	}
+}
+
+// TestChanLinkECN checks that ChanLink carries ECN codepoints and marks ECN-capable packets
+func TestChanLinkECN(t *testing.T) {
+	a, b := NewChanPipe()
+	a.SetMarkCE(true)
+	for _, tt := range []struct{ sent, recv byte }{
+		{ECNNotECT, ECNNotECT}, {ECNECT0, ECNCE}, {ECNECT1, ECNCE},
+	} {
+		go a.WriteToECN([]byte{1, 2, 3}, nil, tt.sent)
+		buf := make([]byte, 10)
+		n, _, ecn, err := b.ReadFromECN(buf)
+		if err != nil || n != 3 {
+			t.Fatalf("read %d bytes (%v)", n, err)
+		}
+		if ecn != tt.recv {
+			t.Errorf("sent codepoint %d, expecting %d, got %d", tt.sent, tt.recv, ecn)
+		}
+	}
+}
@@ -0,0 +1,623 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// EncapMux implements DCCP-UDP, the encapsulation of DCCP in UDP described in RFC 6773, on top
+// of a connection-less packet layer, like UDPLink. Unlike Mux, which frames DCCP headers with
+// flow labels, EncapMux sends the standard DCCP header as the entire UDP payload. Packets are
+// demultiplexed into connections by the link-layer address of their sender, and the source
+// and destination ports of their DCCP header, RFC 6773 Section 3.3. This allows GoDCCP to talk
+// to other DCCP-UDP implementations.
+//
+// The DCCP checksum covers an IP pseudo-header, Section 9.1, which consists of the IP addresses
+// of the encapsulating UDP datagrams and the protocol number of DCCP, RFC 6773 Section 3.5.
+// Packets whose checksum does not verify are dropped.
+//
+// Connections are accepted on any DCCP port, when a DCCP-Request arrives. The Service Code of
+// the Request, rather than the port, selects the service, see Stack.Handle. Requests that
+// arrive while MuxAcceptQueue connections are waiting for Accept are dropped.
+type EncapMux struct {
+	Mutex
+	link       Link
+	conns      map[encapKey]*encapConn // Active connections
+	acceptChan chan *encapConn
+	random     io.Reader          // Source of random local ports
+	portMin    uint16             // Smallest local DCCP port chosen by Dial
+	localIPs   []net.IP           // IP addresses of the local interfaces, see readUnknown
+	listener   *statelessListener // Answers the packets of connections that are not accepted, or nil
+}
+
+const (
+	// EncapPort is the UDP port assigned by IANA to DCCP-UDP, RFC 6773 Section 7
+	EncapPort = 6511
+
+	// ProtoDCCP is the IP protocol number of DCCP, used in the checksum pseudo-header
+	ProtoDCCP = 33
+
+	// EncapPortMin is the smallest local DCCP port chosen by Dial. Ports are chosen from the
+	// dynamic range, RFC 6335 Section 6.
+	EncapPortMin = 49152
+)
+
+// encapKey identifies a connection by the link-layer address of the remote endpoint and the
+// DCCP ports of both endpoints
+type encapKey struct {
+	addr       string
+	remotePort uint16
+	localPort  uint16
+}
+
+// EncapAddr is the address of a DCCP-UDP endpoint. It consists of the link-layer address,
+// e.g. the UDP address of the datagrams, and the DCCP port. EncapAddr implements net.Addr.
+type EncapAddr struct {
+	Link net.Addr // Link-layer address, or nil if not known
+	Port uint16   // DCCP port
+}
+
+// Network returns the name of the DCCP-UDP address namespace, included to conform to net.Addr
+func (addr *EncapAddr) Network() string { return "dccp-udp" }
+
+// String returns the link-layer address and the DCCP port, separated by a slash
+func (addr *EncapAddr) String() string {
+	s := strconv.Itoa(int(addr.Port))
+	if addr.Link == nil {
+		return s
+	}
+	return addr.Link.String() + "/" + s
+}
+
+// Bytes returns a representation of the address, which is used in place of a flow label
+func (addr *EncapAddr) Bytes() []byte {
+	p := make([]byte, 2)
+	EncodeUint16(addr.Port, p)
+	return append(p, linkString(addr.Link)...)
+}
+
+// linkString returns the string representation of the link-layer address addr, or the empty
+// string if addr is nil
+func linkString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// NewEncapMux creates a new EncapMux object, using the connection-less packet interface link
+func NewEncapMux(link Link) *EncapMux {
+	m := &EncapMux{
+		link:       link,
+		conns:      make(map[encapKey]*encapConn),
+		acceptChan: make(chan *encapConn, MuxAcceptQueue),
+		random:     rand.Reader,
+		portMin:    EncapPortMin,
+		localIPs:   interfaceIPs(),
+	}
+	go m.readLoop()
+	return m
+}
+
+// SetRandom replaces the source of randomness, from which the local ports of dialed
+// connections are chosen. The default source is crypto/rand.
+func (m *EncapMux) SetRandom(r io.Reader) {
+	m.Lock()
+	defer m.Unlock()
+	m.random = r
+}
+
+// setListener makes the EncapMux pass the packets of connections, which it has not accepted, to
+// l, see statelessListener. Connections are then accepted only when l approves them.
+func (m *EncapMux) setListener(l *statelessListener) {
+	m.Lock()
+	defer m.Unlock()
+	m.listener = l
+}
+
+func (m *EncapMux) getListener() *statelessListener {
+	m.Lock()
+	defer m.Unlock()
+	return m.listener
+}
+
+// Accept returns the first incoming connection, once its DCCP-Request has arrived
+func (m *EncapMux) Accept() (HeaderConn, error) {
+	c, ok := <-m.acceptChan
+	if !ok {
+		return nil, ErrBad
+	}
+	return c, nil
+}
+
+// Dial opens a connection to the DCCP-UDP endpoint addr, which must be an *EncapAddr. The local
+// DCCP port is chosen at random from the dynamic range. Dial returns ErrBusy if every port in
+// the range is taken by a connection to addr.
+func (m *EncapMux) Dial(addr net.Addr) (HeaderConn, error) {
+	ea, ok := addr.(*EncapAddr)
+	if !ok {
+		return nil, ErrInvalid
+	}
+	la := m.localAddrTo(ea.Link)
+	m.Lock()
+	defer m.Unlock()
+	if m.link == nil {
+		return nil, ErrBad
+	}
+	key := encapKey{addr: linkString(ea.Link), remotePort: ea.Port}
+	// Take the first free port, starting from a random one
+	var p [2]byte
+	if _, err := io.ReadFull(m.random, p[:]); err != nil {
+		return nil, ErrIO
+	}
+	n := 1<<16 - int(m.portMin)
+	start := int(DecodeUint16(p[:])) % n
+	for i := 0; i < n; i++ {
+		key.localPort = uint16(int(m.portMin) + (start+i)%n)
+		if m.conns[key] == nil {
+			c := newEncapConn(m, la, ea.Link, key)
+			m.conns[key] = c
+			return c, nil
+		}
+	}
+	return nil, ErrBusy
+}
+
+// Close closes the mux and signals all outstanding connections that it is time to terminate
+func (m *EncapMux) Close() error {
+	m.Lock()
+	link := m.link
+	m.link = nil
+	m.forecloseAll()
+	m.Unlock()
+	if link == nil {
+		return ErrBad
+	}
+	return link.Close()
+}
+
+// forecloseAll ends the reads of all active connections
+func (m *EncapMux) forecloseAll() {
+	m.AssertLocked()
+	for _, c := range m.conns {
+		c.foreclose()
+	}
+}
+
+func (m *EncapMux) readLoop() {
+	for {
+		// Check that mux is still open
+		m.Lock()
+		link := m.link
+		m.Unlock()
+		if link == nil {
+			break
+		}
+
+		// Read incoming packet
+		buf := make([]byte, link.GetMTU()+MuxReadSafety)
+		var n int
+		var addr net.Addr
+		var ecn byte
+		var err error
+		if el, ok := link.(ECNLink); ok {
+			n, addr, ecn, err = el.ReadFromECN(buf)
+		} else {
+			n, addr, err = link.ReadFrom(buf)
+		}
+		if err != nil {
+			break
+		}
+
+		// Check that packet is not oversized
+		if len(buf)-n < MuxReadSafety {
+			break
+		}
+
+		m.process(buf[:n], addr, ecn)
+	}
+	close(m.acceptChan)
+	m.Lock()
+	m.forecloseAll()
+	m.Unlock()
+}
+
+// process verifies and parses the DCCP packet p, received from the link-layer address addr,
+// and delivers it to its connection
+func (m *EncapMux) process(p []byte, addr net.Addr, ecn byte) {
+	// REMARK: By design, only one copy of process() can run at a time
+
+	if len(p) < 4 {
+		return
+	}
+	key := encapKey{
+		addr:       linkString(addr),
+		remotePort: DecodeUint16(p[0:2]),
+		localPort:  DecodeUint16(p[2:4]),
+	}
+	m.Lock()
+	c := m.conns[key]
+	m.Unlock()
+
+	if c == nil {
+		la, h := m.readUnknown(p, addr)
+		if h == nil {
+			return
+		}
+		h.ECN = ecn
+		if l := m.getListener(); l != nil {
+			m.listen(l, h, la, addr, key)
+			return
+		}
+		// Only a Request opens a connection. Other packets are answered with a Reset, as
+		// they would be by a connection in the CLOSED state, Section 8.3.1.
+		switch h.Type {
+		case Request:
+			if c = m.accept(la, addr, key); c == nil {
+				return
+			}
+		case Reset:
+			return
+		default:
+			lip, rip := encapPseudoIPs(la, addr)
+			m.reset(h, addr, key, lip, rip)
+			return
+		}
+		c.deliver(h)
+		return
+	}
+
+	h, err := ReadHeader(p, c.rip, c.lip, ProtoDCCP, true)
+	if err != nil {
+		return
+	}
+	h.ECN = ecn
+	c.deliver(h)
+}
+
+// readUnknown parses the packet p, which was received from addr and belongs to no connection,
+// and returns it along with the local address it was sent to. It returns a nil header if the
+// packet is malformed or its checksum does not verify. A link bound to the unspecified IP
+// address receives the packets sent to any local interface. Their checksum is verified
+// against the address of each interface in turn, which determines the local address.
+func (m *EncapMux) readUnknown(p []byte, addr net.Addr) (net.Addr, *Header) {
+	la := m.localAddr()
+	lua, ok := la.(*net.UDPAddr)
+	if !ok || !lua.IP.IsUnspecified() {
+		lip, rip := encapPseudoIPs(la, addr)
+		h, err := ReadHeader(p, rip, lip, ProtoDCCP, true)
+		if err != nil {
+			return nil, nil
+		}
+		return la, h
+	}
+	for _, ip := range m.localIPs {
+		ia := &net.UDPAddr{IP: ip, Port: lua.Port}
+		lip, rip := encapPseudoIPs(ia, addr)
+		if h, err := ReadHeader(p, rip, lip, ProtoDCCP, true); err == nil {
+			return ia, h
+		}
+	}
+	return nil, nil
+}
+
+// accept creates the connection with the remote endpoint at addr, identified by key, and
+// queues it for Accept. The local address of the link is la. If the queue is full, the
+// Request is dropped and accept returns nil. The other side retransmits it.
+func (m *EncapMux) accept(la, addr net.Addr, key encapKey) *encapConn {
+	c := newEncapConn(m, la, addr, key)
+	select {
+	case m.acceptChan <- c:
+	default:
+		return nil
+	}
+	m.Lock()
+	m.conns[key] = c
+	m.Unlock()
+	return c
+}
+
+// listen answers the packet h, which arrived from addr at the local address la and belongs to
+// no connection, with the listener l. No connection is created, unless l approves the packet.
+// Then the connection is accepted, with h as its first packet.
+func (m *EncapMux) listen(l *statelessListener, h *Header, la, addr net.Addr, key encapKey) {
+	local := &EncapAddr{Link: la, Port: key.localPort}
+	remote := &EncapAddr{Link: addr, Port: key.remotePort}
+	reply, accept := l.listen(h, local, remote)
+	if reply != nil {
+		lip, rip := encapPseudoIPs(la, addr)
+		m.reply(reply, addr, key, lip, rip)
+	}
+	if !accept {
+		return
+	}
+	if c := m.accept(la, addr, key); c != nil {
+		c.deliver(h)
+	}
+}
+
+// reset answers the packet h, which does not belong to any connection, with a No Connection
+// Reset
+func (m *EncapMux) reset(h *Header, addr net.Addr, key encapKey, lip, rip net.IP) {
+	m.reply(placeAbnormalSeqAck(newResetHeader(ResetNoConnection), h), addr, key, lip, rip)
+}
+
+// reply sends r, which answers a packet received from addr outside of any connection
+func (m *EncapMux) reply(r *Header, addr net.Addr, key encapKey, lip, rip net.IP) {
+	r.SourcePort, r.DestPort = key.localPort, key.remotePort
+	p, err := r.Write(lip, rip, ProtoDCCP, true)
+	if err != nil {
+		return
+	}
+	m.write(p, addr, ECNNotECT)
+}
+
+// del removes the connection identified by key, if it still exists
+func (m *EncapMux) del(key encapKey) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.conns, key)
+}
+
+// write sends the packet p to addr, with the ECN codepoint ecn if the link carries ECN
+// codepoints
+func (m *EncapMux) write(p []byte, addr net.Addr, ecn byte) error {
+	m.Lock()
+	link := m.link
+	m.Unlock()
+	if link == nil {
+		return ErrBad
+	}
+
+	var n int
+	var err error
+	if el, ok := link.(ECNLink); ok {
+		n, err = el.WriteToECN(p, addr, ecn)
+	} else {
+		n, err = link.WriteTo(p, addr)
+	}
+	if err != nil {
+		return ErrIO
+	}
+	if n != len(p) {
+		panic("block divided")
+	}
+	return nil
+}
+
+func (m *EncapMux) getMTU() int {
+	m.Lock()
+	defer m.Unlock()
+	if m.link == nil {
+		return 0
+	}
+	return m.link.GetMTU()
+}
+
+// isECNCapable returns true if the link carries ECN codepoints
+func (m *EncapMux) isECNCapable() bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.link.(ECNLink)
+	return ok
+}
+
+// localAddr returns the address of the local side of the link, or nil if it is not known
+func (m *EncapMux) localAddr() net.Addr {
+	m.Lock()
+	defer m.Unlock()
+	if al, ok := m.link.(AddrLink); ok {
+		return al.LocalAddr()
+	}
+	return nil
+}
+
+// localAddrTo returns the local address of the link, as seen by the remote endpoint at addr.
+// A link bound to the unspecified IP address sends from the address of the interface that
+// routes to addr. localAddrTo is used by Dial. It is too slow for the read path.
+func (m *EncapMux) localAddrTo(addr net.Addr) net.Addr {
+	la := m.localAddr()
+	lua, ok := la.(*net.UDPAddr)
+	if !ok || !lua.IP.IsUnspecified() {
+		return la
+	}
+	rua, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return la
+	}
+	// Dialing UDP sends no packets. It only chooses the route.
+	c, err := net.DialUDP("udp", nil, rua)
+	if err != nil {
+		return la
+	}
+	defer c.Close()
+	return &net.UDPAddr{IP: c.LocalAddr().(*net.UDPAddr).IP, Port: lua.Port}
+}
+
+// encapPseudoIPs returns the IP addresses of the checksum pseudo-header of the packets sent
+// from the link-layer address local to remote. Addresses that are not UDP addresses, e.g. on
+// links other than UDP, are taken to be zero.
+func encapPseudoIPs(local, remote net.Addr) (lip, rip net.IP) {
+	lip, rip = udpIP(local), udpIP(remote)
+	if lip.To4() != nil && rip.To4() != nil {
+		return lip.To4(), rip.To4()
+	}
+	return lip.To16(), rip.To16()
+}
+
+// interfaceIPs returns the IP addresses of the local network interfaces
+func interfaceIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok {
+			ips = append(ips, ipn.IP)
+		}
+	}
+	return ips
+}
+
+func udpIP(addr net.Addr) net.IP {
+	if ua, ok := addr.(*net.UDPAddr); ok && ua.IP != nil {
+		return ua.IP
+	}
+	return net.IPv4zero
+}
+
+// encapConn is the HeaderConn of a DCCP-UDP connection. It implements ECNHeaderConn and
+// AddrHeaderConn.
+type encapConn struct {
+	m      *EncapMux
+	key    encapKey
+	local  *EncapAddr
+	remote *EncapAddr
+	lip    net.IP // IP addresses of the checksum pseudo-header
+	rip    net.IP
+
+	Mutex        // protects the variables below
+	ch           chan *Header
+	closed       bool
+	readDeadline time.Time
+
+	rlk Mutex // synchronizes calls to Read()
+}
+
+// newEncapConn returns the connection identified by key, between the link-layer addresses la,
+// which is local, and addr
+func newEncapConn(m *EncapMux, la, addr net.Addr, key encapKey) *encapConn {
+	c := &encapConn{
+		m:            m,
+		key:          key,
+		local:        &EncapAddr{Link: la, Port: key.localPort},
+		remote:       &EncapAddr{Link: addr, Port: key.remotePort},
+		ch:           make(chan *Header, MuxFlowQueue),
+		readDeadline: time.Now().Add(-time.Second), // time in the past
+	}
+	c.lip, c.rip = encapPseudoIPs(la, addr)
+	return c
+}
+
+// GetMTU implements HeaderConn.GetMTU
+func (c *encapConn) GetMTU() int { return c.m.getMTU() }
+
+// Read implements HeaderConn.Read
+func (c *encapConn) Read() (h *Header, err error) {
+	c.rlk.Lock()
+	defer c.rlk.Unlock()
+
+	c.Lock()
+	ch := c.ch
+	readDeadline := c.readDeadline
+	c.Unlock()
+	readTimeout := readDeadline.Sub(time.Now())
+	if ch == nil {
+		return nil, ErrBad
+	}
+
+	var timer *time.Timer
+	var tmoch <-chan time.Time
+	if readTimeout > 0 {
+		timer = time.NewTimer(readTimeout)
+		defer timer.Stop()
+		tmoch = timer.C
+	}
+
+	var ok bool
+	select {
+	case h, ok = <-ch:
+		if !ok {
+			return nil, ErrIO
+		}
+	case <-tmoch:
+		return nil, ErrTimeout
+	}
+	return h, nil
+}
+
+// Write implements HeaderConn.Write. The ports of h are set to those of the connection.
+func (c *encapConn) Write(h *Header) error {
+	h.SourcePort, h.DestPort = c.key.localPort, c.key.remotePort
+	p, err := h.Write(c.lip, c.rip, ProtoDCCP, true)
+	if err != nil {
+		return err
+	}
+	return c.m.write(p, c.remote.Link, h.ECN)
+}
+
+// IsECNCapable implements ECNHeaderConn.IsECNCapable
+func (c *encapConn) IsECNCapable() bool {
+	return c.m.isECNCapable()
+}
+
+// LocalLabel implements HeaderConn.LocalLabel. DCCP-UDP has no flow labels. The address of
+// the endpoint is used instead.
+func (c *encapConn) LocalLabel() Bytes { return c.local }
+
+// RemoteLabel implements HeaderConn.RemoteLabel, see LocalLabel
+func (c *encapConn) RemoteLabel() Bytes { return c.remote }
+
+// LocalAddr implements AddrHeaderConn.LocalAddr
+func (c *encapConn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr implements AddrHeaderConn.RemoteAddr
+func (c *encapConn) RemoteAddr() net.Addr { return c.remote }
+
+// SetReadExpire implements HeaderConn.SetReadExpire
+func (c *encapConn) SetReadExpire(nsec int64) error {
+	if nsec < 0 {
+		return ErrInvalid
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.readDeadline = time.Now().Add(time.Duration(nsec))
+	return nil
+}
+
+// deliver queues an incoming packet for Read. Packets are dropped when the queue is full.
+func (c *encapConn) deliver(h *Header) {
+	c.Lock()
+	defer c.Unlock()
+	if c.ch == nil {
+		return
+	}
+	select {
+	case c.ch <- h:
+	default:
+	}
+}
+
+func (c *encapConn) foreclose() {
+	c.Lock()
+	defer c.Unlock()
+	if c.ch != nil {
+		close(c.ch)
+		c.ch = nil
+	}
+}
+
+// Close implements HeaderConn.Close
+func (c *encapConn) Close() error {
+	c.Lock()
+	if c.ch != nil {
+		close(c.ch)
+		c.ch = nil
+	}
+	closed := c.closed
+	c.closed = true
+	c.Unlock()
+	if closed {
+		return ErrBad
+	}
+	c.m.del(c.key)
+	return nil
+}
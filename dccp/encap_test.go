@@ -0,0 +1,196 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestEncapUDP checks the wire format of DCCP-UDP against a plain UDP socket, which stands in
+// for another DCCP-UDP implementation
+func TestEncapUDP(t *testing.T) {
+	lo := net.IPv4(127, 0, 0, 1).To4()
+	link, err := BindUDPLink("udp", &net.UDPAddr{IP: lo})
+	if err != nil {
+		t.Fatalf("bind udp link (%s)", err)
+	}
+	m := NewEncapMux(link)
+	defer m.Close()
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: lo})
+	if err != nil {
+		t.Fatalf("bind udp peer (%s)", err)
+	}
+	defer peer.Close()
+
+	// readPeer returns the next header received by the peer
+	buf := make([]byte, 1500)
+	readPeer := func() *Header {
+		n, _, err := peer.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("peer read (%s)", err)
+		}
+		h, err := ReadHeader(buf[:n], lo, lo, ProtoDCCP, true)
+		if err != nil {
+			t.Fatalf("peer parse (%s)", err)
+		}
+		return h
+	}
+	// writePeer sends h from the peer, with a corrupt checksum if corrupt is set
+	writePeer := func(h *Header, corrupt bool) {
+		p, err := h.Write(lo, lo, ProtoDCCP, true)
+		if err != nil {
+			t.Fatalf("peer encode (%s)", err)
+		}
+		if corrupt {
+			p[7] ^= 1
+		}
+		if _, err := peer.WriteTo(p, link.LocalAddr()); err != nil {
+			t.Fatalf("peer write (%s)", err)
+		}
+	}
+
+	// The standard header is sent in UDP with the checksum over the IP pseudo-header
+	hc, err := m.Dial(&EncapAddr{Link: peer.LocalAddr(), Port: 5001})
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	req := &Header{SeqNo: 100}
+	req.InitRequestHeader(7)
+	if err = hc.Write(req); err != nil {
+		t.Fatalf("write (%s)", err)
+	}
+	h := readPeer()
+	if h.Type != Request || h.DestPort != 5001 || h.SourcePort < EncapPortMin || h.ServiceCode != 7 {
+		t.Fatalf("peer received %v", h)
+	}
+
+	// Packets with a bad checksum are dropped
+	resp := &Header{SourcePort: 5001, DestPort: h.SourcePort, SeqNo: 200, AckNo: 100}
+	resp.InitResponseHeader(7)
+	writePeer(resp, true)
+	writePeer(resp, false)
+	hc.SetReadExpire(1e9)
+	if h, err = hc.Read(); err != nil || h.Type != Response || h.SeqNo != 200 {
+		t.Fatalf("read %v (%v)", h, err)
+	}
+	hc.SetReadExpire(1e8)
+	if h, err = hc.Read(); err != ErrTimeout {
+		t.Errorf("expecting timeout, read %v (%v)", h, err)
+	}
+
+	// Packets for unknown ports are reset
+	ack := &Header{SourcePort: 5002, DestPort: 6000, SeqNo: 300, AckNo: 400}
+	ack.InitAckHeader()
+	writePeer(ack, false)
+	if h = readPeer(); h.Type != Reset || h.ResetCode != ResetNoConnection || h.SourcePort != 6000 || h.DestPort != 5002 {
+		t.Errorf("expecting No Connection reset, peer received %v", h)
+	}
+
+	if err = hc.Close(); err != nil {
+		t.Errorf("close (%s)", err)
+	}
+}
+
+// TestEncapAccept checks that a mux bound to the unspecified IP address finds the local
+// address of an incoming connection from its checksum, and that Requests which find the
+// accept queue full are dropped without holding up other packets
+func TestEncapAccept(t *testing.T) {
+	lo := net.IPv4(127, 0, 0, 1).To4()
+	link, err := BindUDPLink("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("bind udp link (%s)", err)
+	}
+	m := NewEncapMux(link)
+	defer m.Close()
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: lo})
+	if err != nil {
+		t.Fatalf("bind udp peer (%s)", err)
+	}
+	defer peer.Close()
+	to := &net.UDPAddr{IP: lo, Port: link.LocalAddr().(*net.UDPAddr).Port}
+
+	// writePeer sends h from the peer
+	writePeer := func(h *Header) {
+		p, err := h.Write(lo, lo, ProtoDCCP, true)
+		if err != nil {
+			t.Fatalf("peer encode (%s)", err)
+		}
+		if _, err := peer.WriteTo(p, to); err != nil {
+			t.Fatalf("peer write (%s)", err)
+		}
+	}
+	// writeRequest sends a Request from the DCCP port port of the peer
+	writeRequest := func(port uint16) {
+		req := &Header{SourcePort: port, DestPort: 5001, SeqNo: 100}
+		req.InitRequestHeader(7)
+		writePeer(req)
+	}
+
+	writeRequest(6000)
+	hc, err := m.Accept()
+	if err != nil {
+		t.Fatalf("accept (%s)", err)
+	}
+	if la := hc.(*encapConn).LocalAddr().(*EncapAddr); !udpIP(la.Link).Equal(lo) || la.Port != 5001 {
+		t.Errorf("accepted connection has local address %s", la)
+	}
+
+	for i := 0; i <= MuxAcceptQueue; i++ {
+		writeRequest(uint16(6001 + i))
+	}
+	ack := &Header{SourcePort: 5002, DestPort: 6000, SeqNo: 300, AckNo: 400}
+	ack.InitAckHeader()
+	writePeer(ack)
+	buf := make([]byte, 1500)
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := peer.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("peer read (%s)", err)
+	}
+	if h, err := ReadHeader(buf[:n], lo, lo, ProtoDCCP, true); err != nil || h.Type != Reset {
+		t.Errorf("expecting reset, peer received %v (%v)", h, err)
+	}
+
+	m.Lock()
+	conns := len(m.conns)
+	m.Unlock()
+	if queued := len(m.acceptChan); queued != MuxAcceptQueue || conns != 1+MuxAcceptQueue {
+		t.Errorf("%d connections queued and %d open, expecting %d and %d", queued, conns, MuxAcceptQueue, 1+MuxAcceptQueue)
+	}
+}
+
+// TestEncapDialBusy checks that Dial fails, rather than blocks, when every local port is taken
+func TestEncapDialBusy(t *testing.T) {
+	lo := net.IPv4(127, 0, 0, 1).To4()
+	link, err := BindUDPLink("udp4", &net.UDPAddr{IP: lo})
+	if err != nil {
+		t.Fatalf("bind udp link (%s)", err)
+	}
+	m := NewEncapMux(link)
+	defer m.Close()
+	m.portMin = 1<<16 - 3
+	addr := &EncapAddr{Link: &net.UDPAddr{IP: lo, Port: 7000}, Port: 5001}
+
+	ports := make(map[uint16]bool)
+	for i := 0; i < 3; i++ {
+		hc, err := m.Dial(addr)
+		if err != nil {
+			t.Fatalf("dial %d (%s)", i, err)
+		}
+		ports[hc.(*encapConn).LocalAddr().(*EncapAddr).Port] = true
+	}
+	if len(ports) != 3 {
+		t.Errorf("dialed connections share local ports %v", ports)
+	}
+	if _, err := m.Dial(addr); err != ErrBusy {
+		t.Errorf("expecting ErrBusy, got %v", err)
+	}
+	// The same ports remain free for other remote endpoints
+	if _, err := m.Dial(&EncapAddr{Link: addr.Link, Port: 5002}); err != nil {
+		t.Errorf("dial other endpoint (%s)", err)
+	}
+}
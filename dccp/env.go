@@ -5,6 +5,8 @@
 package dccp
 
 import (
+	"crypto/rand"
+	"io"
 	"sync"
 	"time"
 	"github.com/petar/GoGauge/filter"
@@ -19,8 +21,9 @@ type Env struct {
 	gojoin  *GoJoin
 
 	sync.Mutex
-	timeZero int64 // Time when execution started
-	timeLast int64 // Time of last log message
+	timeZero int64     // Time when execution started
+	timeLast int64     // Time of last log message
+	random   io.Reader // Source of random Initial Sequence Numbers and ECN Nonces
 }
 
 func NewEnv(guzzle TraceWriter) *Env {
@@ -31,10 +34,45 @@ func NewEnv(guzzle TraceWriter) *Env {
 		gojoin:   NewGoJoin("Env"),
 		timeZero: now,
 		timeLast: now,
+		random:   rand.Reader,
 	}
 	return r
 }
 
+// SetRandom replaces the source of randomness, from which Initial Sequence Numbers and ECN
+// Nonces are chosen. It allows tests to obtain deterministic values. The default source,
+// crypto/rand, is cryptographically secure.
+func (t *Env) SetRandom(r io.Reader) {
+	t.Lock()
+	defer t.Unlock()
+	t.random = r
+}
+
+// ChooseISS chooses an unpredictable Initial Sequence Number from the entire 48-bit sequence
+// number space, excluding zero, Section 7.2
+func (t *Env) ChooseISS() int64 {
+	var d [6]byte
+	t.Lock()
+	_, err := io.ReadFull(t.random, d[:])
+	t.Unlock()
+	if err != nil {
+		panic("reading random source")
+	}
+	return int64(DecodeUint48(d[:])%(1<<48-1)) + 1
+}
+
+// ChooseNonces returns 64 unpredictable bits, which are used as ECN Nonces, Section 12.2
+func (t *Env) ChooseNonces() uint64 {
+	var d [8]byte
+	t.Lock()
+	_, err := io.ReadFull(t.random, d[:])
+	t.Unlock()
+	if err != nil {
+		panic("reading random source")
+	}
+	return uint64(DecodeUint32(d[:4]))<<32 | uint64(DecodeUint32(d[4:]))
+}
+
 // Go runs f in a new GoRoutine. The GoRoutine is also added to the GoJoin of the Env.
 func (t *Env) Go(f func(), fmt_ string, args_ ...interface{}) {
 	t.gojoin.Go(f, fmt_, args_...)
@@ -72,6 +110,14 @@ func (t *Env) Sleep(ns int64) {
 	time.Sleep(time.Duration(ns))
 }
 
+// Timer returns a channel that is closed ns nanoseconds from now, and a function that stops the
+// timer, if the channel is no longer needed
+func (t *Env) Timer(ns int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+	timer := time.AfterFunc(time.Duration(ns), func() { close(ch) })
+	return ch, func() { timer.Stop() }
+}
+
 func (t *Env) Snap() (sinceZero int64, sinceLast int64) {
 	t.Lock()
 	defer t.Unlock()
@@ -0,0 +1,49 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestChooseISS checks that a fixed random source gives deterministic ISS values within 48 bits,
+// and that the default source covers the entire sequence number space
+func TestChooseISS(t *testing.T) {
+	random := []byte{
+		0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0, 0, 0, 0, 0, 0,
+	}
+	env, again := NewEnv(nil), NewEnv(nil)
+	env.SetRandom(bytes.NewReader(random))
+	again.SetRandom(bytes.NewReader(random))
+	for _, want := range []int64{0x123456789abd, 1, 1} {
+		got := env.ChooseISS()
+		if got != want {
+			t.Errorf("expecting ISS %012x, got %012x", want, got)
+		}
+		if got < 1 || got >= 1<<48 {
+			t.Errorf("ISS %x out of range", got)
+		}
+		if other := again.ChooseISS(); other != got {
+			t.Errorf("same random source gives ISS %012x and %012x", got, other)
+		}
+	}
+
+	// The default source covers the entire sequence number space
+	env = NewEnv(nil)
+	var high bool
+	for i := 0; i < 64; i++ {
+		iss := env.ChooseISS()
+		if iss < 1 || iss >= 1<<48 {
+			t.Fatalf("ISS %x out of range", iss)
+		}
+		high = high || iss >= 1<<24
+	}
+	if !high {
+		t.Errorf("ISS values do not exceed 24 bits")
+	}
+}
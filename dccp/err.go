@@ -33,6 +33,7 @@ var (
 	ErrReset         = NewError("reset")
 	ErrTooBig        = NewError("too big")
 	ErrOverflow      = NewError("overflow")
+	ErrCookie        = NewError("bad init cookie")
 )
 
 // Connection errors
@@ -42,6 +43,7 @@ var (
 	ErrTimeout = NewError("i/o timeout")
 	ErrBad     = NewError("i/o bad connection")
 	ErrIO      = NewError("i/o error")
+	ErrBusy    = NewError("i/o busy")
 )
 
 // Congestion Control errors/events
@@ -59,3 +61,13 @@ func NewCongestionReset(resetCode byte) error { return CongestionReset(resetCode
 // CongestionAck is sent from Congestion Control to Conn to advise that an
 // Ack packet should be sent to the other side.
 var CongestionAck = NewError("cc-ack")
+
+// Feature negotiation errors
+
+// FeatureReset is returned by feature negotiation to indicate that the connection must be
+// reset. FeatureReset encloses the desired Reset Code.
+type FeatureReset byte
+
+func (fe FeatureReset) Error() string { return "feature-reset(" + resetCodeString(byte(fe)) + ")" }
+
+func (fe FeatureReset) ResetCode() byte { return byte(fe) }
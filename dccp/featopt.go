@@ -0,0 +1,75 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// FeatureOption represents the feature negotiation options Change L, Confirm L, Change R
+// and Confirm R, Section 6. The first byte of option data is the feature number, and the
+// subsequent bytes hold zero or more feature values.
+type FeatureOption struct {
+	Type      byte   // One of OptionChangeL, OptionConfirmL, OptionChangeR or OptionConfirmR
+	Feature   byte   // Feature number
+	Values    []byte // Raw feature value(s), whose format depends on the feature number
+	Mandatory bool
+}
+
+func isOptionFeature(optionType byte) bool {
+	return optionType >= OptionChangeL && optionType <= OptionConfirmR
+}
+
+func isOptionChange(optionType byte) bool {
+	return optionType == OptionChangeL || optionType == OptionChangeR
+}
+
+func (opt *FeatureOption) Encode() (*Option, error) {
+	if !isOptionFeature(opt.Type) {
+		return nil, ErrOption
+	}
+	// Change options contain at least one value, Section 6.1
+	if isOptionChange(opt.Type) && len(opt.Values) == 0 {
+		return nil, ErrOption
+	}
+	if len(opt.Values) > 255-3 {
+		return nil, ErrOversize
+	}
+	d := make([]byte, 1+len(opt.Values))
+	d[0] = opt.Feature
+	copy(d[1:], opt.Values)
+	return &Option{
+		Type:      opt.Type,
+		Data:      d,
+		Mandatory: opt.Mandatory,
+	}, nil
+}
+
+func DecodeFeatureOption(opt *Option) *FeatureOption {
+	if !isOptionFeature(opt.Type) || len(opt.Data) < 1 {
+		return nil
+	}
+	return &FeatureOption{
+		Type:      opt.Type,
+		Feature:   opt.Data[0],
+		Values:    opt.Data[1:],
+		Mandatory: opt.Mandatory,
+	}
+}
+
+// encodeFeatureValue encodes the integer feature value v into a big-endian byte string of length l
+func encodeFeatureValue(v uint64, l int) []byte {
+	d := make([]byte, l)
+	for i := l - 1; i >= 0; i-- {
+		d[i] = byte(v)
+		v >>= 8
+	}
+	return d
+}
+
+// decodeFeatureValue decodes a big-endian integer feature value
+func decodeFeatureValue(d []byte) uint64 {
+	var v uint64
+	for _, b := range d {
+		v = (v << 8) | uint64(b)
+	}
+	return v
+}
@@ -0,0 +1,521 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// Feature numbers, Section 6.4
+const (
+	FeatureCCID              = 1
+	FeatureAllowShortSeqNos  = 2
+	FeatureSequenceWindow    = 3
+	FeatureECNIncapable      = 4
+	FeatureAckRatio          = 5
+	FeatureSendAckVector     = 6
+	FeatureSendNDPCount      = 7
+	FeatureMinCsCov          = 8
+	FeatureCheckDataChecksum = 9
+	// Reserved 10 to 127
+	// CCID-specific 128 to 255
+)
+
+// Reconciliation rules, Section 6.3
+const (
+	featureServerPriority = iota + 1
+	featureNonNegotiable
+)
+
+// featureSpec describes a feature that is understood by this implementation.
+// Values of server-priority features are single bytes.
+type featureSpec struct {
+	Rule     int    // featureServerPriority or featureNonNegotiable
+	Len      int    // Length of a non-negotiable feature value in bytes
+	Initial  uint64 // Initial value of the feature, Section 6.4
+	Required bool   // Whether every DCCP implementation must understand the feature
+	Min, Max uint64 // Range of valid non-negotiable feature values
+}
+
+// featureSpecs lists the features understood by this implementation. Change options for
+// any other features are answered with empty Confirm options.
+var featureSpecs = map[byte]*featureSpec{
+	FeatureCCID:             &featureSpec{Rule: featureServerPriority, Initial: CCID2, Required: true},
+	FeatureAllowShortSeqNos: &featureSpec{Rule: featureServerPriority, Initial: 0, Required: true},
+	FeatureSequenceWindow: &featureSpec{
+		Rule: featureNonNegotiable, Len: 6, Initial: SEQWIN_INIT, Required: true,
+		Min: SEQWIN_MIN, Max: SEQWIN_MAX,
+	},
+	FeatureAckRatio: &featureSpec{
+		Rule: featureNonNegotiable, Len: 2, Initial: 2, Required: false,
+		Min: 1, Max: 0xffff,
+	},
+	FeatureECNIncapable:  &featureSpec{Rule: featureServerPriority, Initial: 0, Required: true},
+	FeatureSendAckVector: &featureSpec{Rule: featureServerPriority, Initial: 0, Required: false},
+	FeatureSendNDPCount:  &featureSpec{Rule: featureServerPriority, Initial: 0, Required: false},
+	FeatureMinCsCov:      &featureSpec{Rule: featureServerPriority, Initial: 0, Required: false},
+}
+
+// isValid returns true if the Change option values d are valid for this feature
+func (spec *featureSpec) isValid(d []byte) bool {
+	switch spec.Rule {
+	case featureServerPriority:
+		return len(d) > 0
+	case featureNonNegotiable:
+		if len(d) != spec.Len {
+			return false
+		}
+		v := decodeFeatureValue(d)
+		return spec.Min <= v && v <= spec.Max
+	}
+	panic("unknown reconciliation rule")
+}
+
+// Feature negotiation states, Section 6.6.2
+const (
+	featureStable = iota
+	featureChanging
+	featureUnstable
+)
+
+// feature holds the negotiation state of a feature at one of the two endpoints
+type feature struct {
+	spec      *featureSpec
+	Number    byte
+	Local     bool   // True if the feature is located at this endpoint, i.e. it is F/A
+	Value     uint64 // Current value of the feature
+	State     int    // One of featureStable, featureChanging or featureUnstable
+	Prefs     []byte // Preference list of a server-priority feature, most preferred first
+	Change    []byte // Values carried by the Change option of the current negotiation
+	Mandatory bool   // Whether the Change option is sent as Mandatory
+}
+
+// prefs returns the local preference list of a server-priority feature. Absent an explicit
+// preference list, the endpoint prefers the current value.
+func (ft *feature) prefs() []byte {
+	if ft.Prefs == nil {
+		return []byte{byte(ft.Value)}
+	}
+	return ft.Prefs
+}
+
+// featureSet maintains the values of all features of a connection and carries out their
+// negotiation, as described in Section 6.6.
+type featureSet struct {
+	features []*feature
+	fgsr     int64     // Feature Greatest Sequence Number Received, Section 6.6.4
+	fgss     int64     // Feature Greatest Sequence Number Sent, Section 6.6.4
+	confirms []*Option // Confirm options waiting to be sent on the next packet with an Ack No
+
+	changeTime    int64 // Earliest time when Change options can be retransmitted
+	changeBackoff int64 // Current retransmission interval of Change options
+	changePoll    int64 // Earliest time when PollChanges can request another packet
+}
+
+const (
+	// FeatureBackoffMax is the maximum interval between retransmissions of Change options, Section 6.6.3
+	FeatureBackoffMax = 64e9
+)
+
+// Init resets the featureSet for new use. All features assume their initial values.
+func (f *featureSet) Init() {
+	f.features = nil
+	for number := 0; number < 256; number++ {
+		spec, ok := featureSpecs[byte(number)]
+		if !ok {
+			continue
+		}
+		for _, local := range []bool{true, false} {
+			f.features = append(f.features, &feature{
+				spec:   spec,
+				Number: byte(number),
+				Local:  local,
+				Value:  spec.Initial,
+				State:  featureStable,
+			})
+		}
+	}
+	f.fgsr, f.fgss = 0, 0
+	f.confirms = nil
+	f.changeTime, f.changeBackoff, f.changePoll = 0, 0, 0
+}
+
+// SetISS initializes FGSS to the Initial Sequence number Sent
+func (f *featureSet) SetISS(iss int64) { f.fgss = iss }
+
+// SetISR initializes FGSR to one less than the Initial Sequence number Received
+func (f *featureSet) SetISR(isr int64) { f.fgsr = SeqAdd(isr, -1) }
+
+func (f *featureSet) get(number byte, local bool) *feature {
+	for _, ft := range f.features {
+		if ft.Number == number && ft.Local == local {
+			return ft
+		}
+	}
+	return nil
+}
+
+// Get returns the current value of a feature. If local is true, the value of the feature
+// located at this endpoint is returned.
+func (f *featureSet) Get(number byte, local bool) uint64 {
+	ft := f.get(number, local)
+	if ft == nil {
+		panic("unknown feature")
+	}
+	return ft.Value
+}
+
+// GetTarget returns the value under negotiation of a non-negotiable feature located at this
+// endpoint, or the current value if the feature is stable.
+func (f *featureSet) GetTarget(number byte) uint64 {
+	ft := f.get(number, true)
+	if ft == nil || ft.spec.Rule != featureNonNegotiable {
+		panic("target value of a non non-negotiable feature")
+	}
+	if ft.State == featureStable {
+		return ft.Value
+	}
+	return decodeFeatureValue(ft.Change)
+}
+
+// SetPrefs sets the preference list of a server-priority feature. The list is used to
+// reconcile Change options received from the other side. SetPrefs returns true if the
+// preference list changed. It does not start a negotiation, see Change.
+func (f *featureSet) SetPrefs(number byte, local bool, prefs []byte) bool {
+	ft := f.get(number, local)
+	if ft == nil || ft.spec.Rule != featureServerPriority {
+		panic("setting preferences of a non server-priority feature")
+	}
+	if string(ft.Prefs) == string(prefs) {
+		return false
+	}
+	ft.Prefs = append([]byte{}, prefs...)
+	// A preference change during negotiation requires a new Change option, Section 6.6.5
+	if ft.State == featureChanging {
+		ft.Change = ft.prefs()
+		ft.State = featureUnstable
+		f.changeTime = 0
+	}
+	return true
+}
+
+// Change starts the negotiation of a server-priority feature, offering the current
+// preference list. If mandatory is set, the Change options are sent as Mandatory.
+func (f *featureSet) Change(number byte, local bool, mandatory bool) {
+	ft := f.get(number, local)
+	if ft == nil || ft.spec.Rule != featureServerPriority {
+		panic("changing a non server-priority feature")
+	}
+	f.startChange(ft, ft.prefs(), mandatory)
+}
+
+// ChangeValue starts the negotiation of a non-negotiable feature, located at this
+// endpoint, towards value v. ChangeValue does nothing if v is the current value, or
+// the value under negotiation.
+func (f *featureSet) ChangeValue(number byte, v uint64) {
+	ft := f.get(number, true)
+	if ft == nil || ft.spec.Rule != featureNonNegotiable {
+		panic("changing value of a non non-negotiable feature")
+	}
+	if v < ft.spec.Min || v > ft.spec.Max {
+		panic("invalid feature value")
+	}
+	change := encodeFeatureValue(v, ft.spec.Len)
+	if ft.State == featureStable && ft.Value == v {
+		return
+	}
+	if ft.State != featureStable && string(ft.Change) == string(change) {
+		return
+	}
+	f.startChange(ft, change, false)
+}
+
+func (f *featureSet) startChange(ft *feature, change []byte, mandatory bool) {
+	ft.Change = change
+	ft.Mandatory = mandatory
+	// The UNSTABLE state ensures that a new Change option, rather than a retransmission,
+	// is sent next
+	ft.State = featureUnstable
+	f.changeTime, f.changeBackoff = 0, 0
+}
+
+// featureValue records the value of a feature, so that it can be carried outside of a
+// featureSet, e.g. in an Init Cookie
+type featureValue struct {
+	Number byte
+	Local  bool
+	Value  uint64
+}
+
+// isValid returns true if v is the value of a feature understood by this implementation,
+// and it lies within the feature's range
+func (v featureValue) isValid() bool {
+	spec, ok := featureSpecs[v.Number]
+	if !ok {
+		return false
+	}
+	switch spec.Rule {
+	case featureServerPriority:
+		return v.Value <= 0xff
+	case featureNonNegotiable:
+		return spec.Min <= v.Value && v.Value <= spec.Max
+	}
+	return false
+}
+
+// Values returns the current values of all features that differ from their initial values
+func (f *featureSet) Values() []featureValue {
+	var vs []featureValue
+	for _, ft := range f.features {
+		if ft.Value != ft.spec.Initial {
+			vs = append(vs, featureValue{Number: ft.Number, Local: ft.Local, Value: ft.Value})
+		}
+	}
+	return vs
+}
+
+// SetValues sets the current values of features, without negotiating them. It is used to
+// restore values that were negotiated elsewhere. Invalid values are ignored.
+func (f *featureSet) SetValues(vs []featureValue) {
+	for _, v := range vs {
+		if !v.isValid() {
+			continue
+		}
+		f.get(v.Number, v.Local).Value = v.Value
+	}
+}
+
+// IsStable returns true if the feature is not being negotiated
+func (f *featureSet) IsStable(number byte, local bool) bool {
+	return f.get(number, local).State == featureStable
+}
+
+// OnRead processes the feature negotiation options of a valid incoming packet h, according
+// to the pseudocode of Section 6.6.2. isServer indicates whether this endpoint is the server.
+// If the connection must be reset, OnRead returns a FeatureReset error.
+func (f *featureSet) OnRead(h *Header, isServer bool) error {
+	// Feature negotiation options received on DCCP-Data packets MUST be ignored
+	if h.Type == Data {
+		return nil
+	}
+	var seen bool
+	for _, opt := range h.Options {
+		fo := DecodeFeatureOption(opt)
+		if fo == nil {
+			continue
+		}
+		seen = true
+		if err := f.onOption(fo, h, isServer); err != nil {
+			return err
+		}
+	}
+	if seen {
+		f.fgsr = SeqMax(f.fgsr, h.SeqNo)
+	}
+	return nil
+}
+
+func (f *featureSet) onOption(fo *FeatureOption, h *Header, isServer bool) error {
+	isChange := isOptionChange(fo.Type)
+	// Change R and Confirm R options refer to features located at this endpoint
+	local := fo.Type == OptionChangeR || fo.Type == OptionConfirmR
+	confirmType := byte(OptionConfirmL)
+	if fo.Type == OptionChangeL {
+		confirmType = OptionConfirmR
+	}
+
+	// Check for unknown features, Section 6.6.7
+	ft := f.get(fo.Feature, local)
+	if ft == nil {
+		if isChange {
+			if fo.Mandatory {
+				return FeatureReset(ResetMandatoryError)
+			}
+			f.confirm(confirmType, fo.Feature, nil)
+		}
+		return nil
+	}
+
+	// Check for reordering, Section 6.6.4
+	if ft.State == featureUnstable || SeqLessEq(h.SeqNo, f.fgsr) ||
+		(!isChange && (!h.HasAckNo() || SeqLess(h.AckNo, f.fgss))) {
+
+		return nil
+	}
+
+	// Process Change options
+	if isChange {
+		// Change R and Confirm L options MUST NOT be sent for non-negotiable features
+		valid := ft.spec.isValid(fo.Values) &&
+			(ft.spec.Rule != featureNonNegotiable || fo.Type == OptionChangeL)
+		if !valid {
+			if fo.Mandatory {
+				return FeatureReset(ResetMandatoryError)
+			}
+			// Remain in existing state. If CHANGING, the Change option will be retransmitted.
+			f.confirm(confirmType, fo.Feature, nil)
+			return nil
+		}
+		switch ft.spec.Rule {
+		case featureServerPriority:
+			v, ok := reconcileServerPriority(ft.prefs(), fo.Values, isServer)
+			if !ok {
+				if fo.Mandatory {
+					return FeatureReset(ResetMandatoryError)
+				}
+				v = byte(ft.Value)
+			}
+			ft.Value = uint64(v)
+			f.confirm(confirmType, fo.Feature, append([]byte{v}, ft.prefs()...))
+		case featureNonNegotiable:
+			ft.Value = decodeFeatureValue(fo.Values)
+			f.confirm(confirmType, fo.Feature, fo.Values)
+		}
+		ft.State = featureStable
+		return nil
+	}
+
+	// Process Confirm options, but only in the CHANGING state
+	if ft.State != featureChanging {
+		return nil
+	}
+	if len(fo.Values) > 0 {
+		v, ok := checkConfirm(ft, fo.Values, isServer)
+		if !ok {
+			return FeatureReset(ResetOptionError)
+		}
+		ft.Value = v
+	} else if ft.spec.Required {
+		// The other side does not understand a feature that it is required to
+		return FeatureReset(ResetOptionError)
+	}
+	ft.State = featureStable
+	return nil
+}
+
+// reconcileServerPriority selects the first entry in the server's preference list that
+// also occurs in the client's list, Section 6.3.1. If isServer is true, ours is the server's
+// list.
+func reconcileServerPriority(ours, theirs []byte, isServer bool) (byte, bool) {
+	server, client := ours, theirs
+	if !isServer {
+		server, client = theirs, ours
+	}
+	for _, s := range server {
+		for _, c := range client {
+			if s == c {
+				return s, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// checkConfirm verifies that the values d, carried by a Confirm option, correctly conclude
+// the negotiation of feature ft, Section 6.6.8. It returns the confirmed value.
+func checkConfirm(ft *feature, d []byte, isServer bool) (uint64, bool) {
+	switch ft.spec.Rule {
+	case featureServerPriority:
+		v, ok := reconcileServerPriority(ft.Change, d[1:], isServer)
+		if !ok {
+			// Without a shared entry, the previous value is confirmed
+			v = byte(ft.Value)
+		}
+		return uint64(d[0]), d[0] == v
+	case featureNonNegotiable:
+		return decodeFeatureValue(d), string(d) == string(ft.Change)
+	}
+	panic("unknown reconciliation rule")
+}
+
+// confirm enqueues a Confirm option to be sent on the next packet with an Acknowledgement Number.
+// A pending Confirm of the same feature is replaced, since it answers an older Change option
+// and the other side would find its value invalid.
+func (f *featureSet) confirm(confirmType, number byte, values []byte) {
+	opt, err := (&FeatureOption{Type: confirmType, Feature: number, Values: values}).Encode()
+	if err != nil {
+		panic("encoding confirm option")
+	}
+	for i, c := range f.confirms {
+		if c.Type == confirmType && c.Data[0] == number {
+			f.confirms[i] = opt
+			return
+		}
+	}
+	f.confirms = append(f.confirms, opt)
+}
+
+// PendingConfirms returns true if there are Confirm options waiting to be sent
+func (f *featureSet) PendingConfirms() bool {
+	return len(f.confirms) > 0
+}
+
+// ChangesDue returns true if some feature is being negotiated and its Change option
+// should be (re)transmitted at time now
+func (f *featureSet) ChangesDue(now int64) bool {
+	if now < f.changeTime {
+		return false
+	}
+	for _, ft := range f.features {
+		if ft.State != featureStable {
+			return true
+		}
+	}
+	return false
+}
+
+// PollChanges returns true if a packet should be sent to carry Change options that are due
+// at time now. Once it returns true, PollChanges returns false for the following interval, so
+// that packets are not requested repeatedly while an earlier one waits in the send queue.
+func (f *featureSet) PollChanges(now, interval int64) bool {
+	if now < f.changePoll || !f.ChangesDue(now) {
+		return false
+	}
+	f.changePoll = now + interval
+	return true
+}
+
+// Write attaches pending Confirm options and due Change options to the outgoing packet h.
+// Change options are retransmitted using an exponential backoff timer, whose initial
+// interval is rtt. They are always attached to Request and Response packets.
+func (f *featureSet) Write(h *Header, now, rtt int64) {
+	// Feature negotiation options MUST NOT be sent on DCCP-Data packets
+	if h.Type == Data || h.Type == Reset {
+		return
+	}
+	// Any packet including a Confirm option MUST carry an Acknowledgement Number
+	if h.HasAckNo() && len(f.confirms) > 0 {
+		h.Options = append(h.Options, f.confirms...)
+		f.confirms = nil
+	}
+	if h.Type != Request && h.Type != Response && now < f.changeTime {
+		return
+	}
+	var sent bool
+	for _, ft := range f.features {
+		if ft.State == featureStable {
+			continue
+		}
+		typ := byte(OptionChangeR)
+		if ft.Local {
+			typ = OptionChangeL
+		}
+		opt, err := (&FeatureOption{Type: typ, Feature: ft.Number, Values: ft.Change, Mandatory: ft.Mandatory}).Encode()
+		if err != nil {
+			panic("encoding change option")
+		}
+		h.Options = append(h.Options, opt)
+		// A Change option generated in the UNSTABLE state is new
+		if ft.State == featureUnstable {
+			f.fgss = SeqMax(f.fgss, h.SeqNo)
+			ft.State = featureChanging
+		}
+		sent = true
+	}
+	if sent {
+		if f.changeBackoff == 0 {
+			f.changeBackoff = max64(rtt, RoundtripMin)
+		} else {
+			f.changeBackoff = min64(2*f.changeBackoff, FeatureBackoffMax)
+		}
+		f.changeTime = now + f.changeBackoff
+	}
+}
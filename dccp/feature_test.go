@@ -0,0 +1,156 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+// featureExchange transfers the feature negotiation options written by one featureSet at
+// time now, on a packet with the given type, seqno and ackno, to another featureSet.
+func featureExchange(from, to *featureSet, toIsServer bool, now int64, Type byte, seqNo, ackNo int64) error {
+	h := &Header{Type: Type, X: true, SeqNo: seqNo, AckNo: ackNo}
+	from.Write(h, now, RoundtripDefault)
+	return to.OnRead(h, toIsServer)
+}
+
+func newTestFeatureSets(client, server []byte) (c, s *featureSet) {
+	c, s = &featureSet{}, &featureSet{}
+	c.Init()
+	s.Init()
+	c.SetISS(100)
+	c.SetISR(500)
+	s.SetISS(500)
+	s.SetISR(100)
+	c.SetPrefs(FeatureCCID, true, client)
+	c.SetPrefs(FeatureCCID, false, client)
+	s.SetPrefs(FeatureCCID, true, server)
+	s.SetPrefs(FeatureCCID, false, server)
+	return c, s
+}
+
+func TestFeatureServerPriority(t *testing.T) {
+	c, s := newTestFeatureSets([]byte{CCID3, CCID2}, []byte{CCID2})
+	c.Change(FeatureCCID, true, true)
+	c.Change(FeatureCCID, false, true)
+
+	if err := featureExchange(c, s, true, 0, Request, 100, 0); err != nil {
+		t.Fatalf("server feature error (%s)", err)
+	}
+	if s.Get(FeatureCCID, true) != CCID2 || s.Get(FeatureCCID, false) != CCID2 {
+		t.Errorf("server did not select CCID 2")
+	}
+	if err := featureExchange(s, c, false, 0, Response, 500, 100); err != nil {
+		t.Fatalf("client feature error (%s)", err)
+	}
+	if c.Get(FeatureCCID, true) != CCID2 || c.Get(FeatureCCID, false) != CCID2 {
+		t.Errorf("client did not confirm CCID 2")
+	}
+	if !c.IsStable(FeatureCCID, true) || !c.IsStable(FeatureCCID, false) {
+		t.Errorf("client negotiation did not conclude")
+	}
+}
+
+func TestFeatureMandatoryFailure(t *testing.T) {
+	c, s := newTestFeatureSets([]byte{CCID3}, []byte{CCID2})
+	c.Change(FeatureCCID, true, true)
+	err := featureExchange(c, s, true, 0, Request, 100, 0)
+	if fe, ok := err.(FeatureReset); !ok || fe.ResetCode() != ResetMandatoryError {
+		t.Errorf("expecting mandatory failure, got %v", err)
+	}
+}
+
+func TestFeatureNonNegotiable(t *testing.T) {
+	c, s := newTestFeatureSets([]byte{CCID2}, []byte{CCID2})
+	s.ChangeValue(FeatureSequenceWindow, 1024)
+
+	// The first Change option is lost
+	h := &Header{Type: Response, X: true, SeqNo: 500, AckNo: 100}
+	s.Write(h, 0, RoundtripDefault)
+	if s.ChangesDue(0) {
+		t.Errorf("change retransmission is not backed off")
+	}
+	if !s.ChangesDue(RoundtripDefault) {
+		t.Errorf("change retransmission is not due")
+	}
+
+	if err := featureExchange(s, c, false, RoundtripDefault, Ack, 501, 100); err != nil {
+		t.Fatalf("client feature error (%s)", err)
+	}
+	if c.Get(FeatureSequenceWindow, false) != 1024 {
+		t.Errorf("client did not accept sequence window")
+	}
+	if s.Get(FeatureSequenceWindow, true) != SEQWIN_INIT {
+		t.Errorf("server changed sequence window before confirmation")
+	}
+
+	// A Confirm that does not acknowledge the latest Change is ignored
+	if err := featureExchange(c, s, true, RoundtripDefault, Ack, 101, 499); err != nil {
+		t.Fatalf("server feature error (%s)", err)
+	}
+	if s.IsStable(FeatureSequenceWindow, true) {
+		t.Errorf("server accepted a reordered confirm")
+	}
+
+	// Retransmit, and confirm
+	featureExchange(s, c, false, 3*RoundtripDefault, Ack, 502, 101)
+	if err := featureExchange(c, s, true, 3*RoundtripDefault, Ack, 102, 502); err != nil {
+		t.Fatalf("server feature error (%s)", err)
+	}
+	if s.Get(FeatureSequenceWindow, true) != 1024 || !s.IsStable(FeatureSequenceWindow, true) {
+		t.Errorf("server did not accept confirmation")
+	}
+}
+
+func TestFeatureChangeOvertaken(t *testing.T) {
+	c, s := newTestFeatureSets([]byte{CCID2}, []byte{CCID2})
+	s.ChangeValue(FeatureSequenceWindow, 1024)
+	h1 := &Header{Type: Ack, X: true, SeqNo: 501, AckNo: 100}
+	s.Write(h1, 0, RoundtripDefault)
+
+	// A new Change is sent before the first one is confirmed
+	s.ChangeValue(FeatureSequenceWindow, 2048)
+	h2 := &Header{Type: Ack, X: true, SeqNo: 502, AckNo: 100}
+	s.Write(h2, 0, RoundtripDefault)
+	if err := c.OnRead(h1, false); err != nil {
+		t.Fatalf("client feature error (%s)", err)
+	}
+	if err := c.OnRead(h2, false); err != nil {
+		t.Fatalf("client feature error (%s)", err)
+	}
+
+	// Both Change options are answered on the same packet, which must confirm the latest value
+	if err := featureExchange(c, s, true, 0, Ack, 101, 502); err != nil {
+		t.Fatalf("server feature error (%s)", err)
+	}
+	if s.Get(FeatureSequenceWindow, true) != 2048 || !s.IsStable(FeatureSequenceWindow, true) {
+		t.Errorf("server did not accept confirmation of the latest change")
+	}
+}
+
+func TestFeatureUnknown(t *testing.T) {
+	_, s := newTestFeatureSets([]byte{CCID2}, []byte{CCID2})
+	opt, _ := (&FeatureOption{Type: OptionChangeL, Feature: 200, Values: []byte{1}}).Encode()
+	h := &Header{Type: Ack, X: true, SeqNo: 101, AckNo: 500, Options: []*Option{opt}}
+	if err := s.OnRead(h, true); err != nil {
+		t.Fatalf("server feature error (%s)", err)
+	}
+	h = &Header{Type: Ack, X: true, SeqNo: 501, AckNo: 101}
+	s.Write(h, 0, RoundtripDefault)
+	if len(h.Options) != 1 {
+		t.Fatalf("expecting one confirm option, got %d", len(h.Options))
+	}
+	fo := DecodeFeatureOption(h.Options[0])
+	if fo == nil || fo.Type != OptionConfirmR || fo.Feature != 200 || len(fo.Values) != 0 {
+		t.Errorf("expecting empty confirm, got %v", fo)
+	}
+
+	// Mandatory unknown features reset the connection
+	opt.Mandatory = true
+	h = &Header{Type: Ack, X: true, SeqNo: 102, AckNo: 501, Options: []*Option{opt}}
+	if err := s.OnRead(h, true); err == nil {
+		t.Errorf("expecting reset on mandatory unknown feature")
+	}
+}
@@ -100,19 +100,38 @@ func (f *flow) getLocal() *Label {
 // LocalLabel implements SegmentConn.LocalLabel
 func (f *flow) LocalLabel() Bytes { return f.getLocal() }
 
+// LocalAddr implements AddrSegmentConn.LocalAddr
+func (f *flow) LocalAddr() net.Addr {
+	f.Lock()
+	m := f.m
+	f.Unlock()
+	if m == nil {
+		return nil
+	}
+	return m.localAddr()
+}
+
+// RemoteAddr implements AddrSegmentConn.RemoteAddr
+func (f *flow) RemoteAddr() net.Addr { return f.addr }
+
 func (f *flow) String() string {
 	return f.getLocal().String() + "--" + f.getRemote().String()
 }
 
 // Write implements SegmentConn.Write
 func (f *flow) Write(block []byte) error {
+	return f.WriteECN(block, ECNNotECT)
+}
+
+// WriteECN implements ECNSegmentConn.WriteECN
+func (f *flow) WriteECN(block []byte, ecn byte) error {
 	f.Lock()
 	m := f.m
 	f.Unlock()
 	if m == nil {
 		return ErrBad
 	}
-	err := m.write(&muxMsg{f.getLocal(), f.getRemote()}, block, f.addr)
+	err := m.write(&muxMsg{f.getLocal(), f.getRemote()}, block, f.addr, ecn)
 	if err != nil {
 		f.Lock()
 		f.lastWrite = time.Now()
@@ -123,6 +142,20 @@ func (f *flow) Write(block []byte) error {
 
 // Read implements SegmentConn.Read
 func (f *flow) Read() (block []byte, err error) {
+	block, _, err = f.ReadECN()
+	return block, err
+}
+
+// IsECNCapable implements ECNSegmentConn.IsECNCapable
+func (f *flow) IsECNCapable() bool {
+	f.Lock()
+	m := f.m
+	f.Unlock()
+	return m != nil && m.isECNCapable()
+}
+
+// ReadECN implements ECNSegmentConn.ReadECN
+func (f *flow) ReadECN() (block []byte, ecn byte, err error) {
 	f.rlk.Lock()
 	defer f.rlk.Unlock()
 
@@ -132,7 +165,7 @@ func (f *flow) Read() (block []byte, err error) {
 	f.Unlock()
 	readTimeout := readDeadline.Sub(time.Now())
 	if ch == nil {
-		return nil, ErrBad
+		return nil, 0, ErrBad
 	}
 
 	var timer *time.Timer
@@ -148,17 +181,31 @@ func (f *flow) Read() (block []byte, err error) {
 	select {
 	case header, ok = <-ch:
 		if !ok {
-			return nil, ErrIO
+			return nil, 0, ErrIO
 		}
 	case <-tmoch:
-		return nil, ErrTimeout
+		return nil, 0, ErrTimeout
 	}
 
 	f.Lock()
 	f.lastRead = time.Now()
 	f.Unlock()
 
-	return header.Cargo, nil
+	return header.Cargo, header.ECN, nil
+}
+
+// deliver queues an incoming packet for Read. Like a datagram socket, the flow drops packets
+// when its queue is full, so that a flow whose reader has stopped does not block the Mux.
+func (f *flow) deliver(h muxHeader) {
+	f.Lock()
+	defer f.Unlock()
+	if f.ch == nil {
+		return
+	}
+	select {
+	case f.ch <- h:
+	default:
+	}
 }
 
 func (f *flow) foreclose() {
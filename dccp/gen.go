@@ -39,6 +39,13 @@ func (c *Conn) generateSync() *writeHeader {
 	return h
 }
 
+// generatePMTUProbe generates a Sync packet, which is padded to size bytes when it is written
+func (c *Conn) generatePMTUProbe(size int32) *writeHeader {
+	h := c.generateSync()
+	h.PMTUProbe = size
+	return h
+}
+
 func (c *Conn) generateRequest(serviceCode uint32) *writeHeader {
 	h := &writeHeader{}
 	h.Header.InitRequestHeader(serviceCode)
@@ -60,6 +67,13 @@ func (c *Conn) generateClose() *writeHeader {
 	return h
 }
 
+func (c *Conn) generateCloseReq() *writeHeader {
+	h := &writeHeader{}
+	h.Header.InitCloseReqHeader()
+	h.SeqAckType = seqAckNormal
+	return h
+}
+
 func (c *Conn) generateAck() *writeHeader {
 	h := &writeHeader{}
 	h.Header.InitAckHeader()
@@ -74,9 +88,10 @@ func (c *Conn) generateData(data []byte) *writeHeader {
 	return h
 }
 
-func (c *Conn) generateDataAck(data []byte) *writeHeader {
+func (c *Conn) generateDataAck(data []byte, csCov byte) *writeHeader {
 	h := &writeHeader{}
 	h.Header.InitDataAckHeader(data)
+	h.Header.CsCov = c.getCsCov(csCov, len(data))
 	h.SeqAckType = seqAckNormal
 	return h
 }
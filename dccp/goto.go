@@ -13,11 +13,12 @@ const (
 
 	RESPOND_TIMEOUT            = 30e9     // Timeout in RESPOND state, 30 sec in ns
 
-	LISTEN_TIMEOUT             = REQUEST_BACKOFF_TIMEOUT    // Timeout in LISTEN state
-
 	CLOSING_BACKOFF_FREQ       = 64e9     // Backoff frequency of CLOSING timer, 64 seconds, Section 8.3
 	CLOSING_BACKOFF_TIMEOUT    = MSL/4    // Maximum time in CLOSING (RFC recommends MSL, but seems too long)
 
+	CLOSEREQ_BACKOFF_FREQ      = CLOSING_BACKOFF_FREQ     // Backoff frequency of CLOSEREQ timer, Section 8.3
+	CLOSEREQ_BACKOFF_TIMEOUT   = CLOSING_BACKOFF_TIMEOUT  // Maximum time in CLOSEREQ
+
 	TIMEWAIT_TIMEOUT           = MSL/2    // Time to stay in TIMEWAIT, Section 8.3 recommends MSL*2
 
 	PARTOPEN_BACKOFF_FIRST     = 200e6    // 200 miliseconds in ns, Section 8.1.5
@@ -44,20 +45,23 @@ func (c *Conn) gotoLISTEN() {
 			// Otherwise abort the connection
 			c.abortQuietly()
 		}, 
-		LISTEN_TIMEOUT, EXPIRE_INTERVAL, "gotoLISTEN")
+		c.config.RequestTimeout, min64(EXPIRE_INTERVAL, c.config.RequestTimeout), "gotoLISTEN")
 }
 
-func (c *Conn) gotoRESPOND(hServiceCode uint32, hSeqNo int64) {
+func (c *Conn) gotoRESPOND(hServiceCode uint32, iss, hSeqNo int64) {
 	c.AssertLocked()
 	c.socket.SetState(RESPOND)
 	c.emitSetState()
-	iss := c.socket.ChooseISS()
+	c.socket.SetISS(iss)
 	c.socket.SetGAR(iss)
 	c.socket.SetISR(hSeqNo)
+	c.feature.SetISS(iss)
+	c.feature.SetISR(hSeqNo)
 	c.socket.SetGSR(hSeqNo)
 	// TODO: To be more prudent, set service code only if it is currently 0,
 	// otherwise check that h.ServiceCode matches socket service code
 	c.socket.SetServiceCode(hServiceCode)
+	c.leaveLISTEN()
 
 	c.env.Expire(
 		func()bool {
@@ -69,7 +73,7 @@ func (c *Conn) gotoRESPOND(hServiceCode uint32, hSeqNo int64) {
 		func() {
 			c.abortQuietly()
 		}, 
-		RESPOND_TIMEOUT, EXPIRE_INTERVAL, "gotoRESPOND")
+		c.config.RespondTimeout, min64(EXPIRE_INTERVAL, c.config.RespondTimeout), "gotoRESPOND")
 }
 
 func (c *Conn) gotoREQUEST(serviceCode uint32) {
@@ -78,13 +82,15 @@ func (c *Conn) gotoREQUEST(serviceCode uint32) {
 	c.socket.SetState(REQUEST)
 	c.emitSetState()
 	c.socket.SetServiceCode(serviceCode)
-	iss := c.socket.ChooseISS()
+	iss := c.env.ChooseISS()
+	c.socket.SetISS(iss)
 	c.socket.SetGAR(iss)
+	c.feature.SetISS(iss)
 	c.inject(c.generateRequest(serviceCode))
 
 	// Resend Request using exponential backoff, if no response
 	c.env.Go(func() {
-		b := newBackOff(c.env, REQUEST_BACKOFF_FIRST, REQUEST_BACKOFF_TIMEOUT, REQUEST_BACKOFF_FREQ)
+		b := newBackOff(c.env, min64(REQUEST_BACKOFF_FIRST, c.config.RequestTimeout), c.config.RequestTimeout, REQUEST_BACKOFF_FREQ)
 		for {
 			err, _ := b.Sleep()
 			c.Lock()
@@ -114,6 +120,7 @@ func (c *Conn) openCCID() {
 	c.scc.Open()
 	c.rcc.Open()
 	c.ccidOpen = true
+	c.reportPMTU()
 	c.amb.E(EventMatch, "CCID open")
 }
 
@@ -164,6 +171,8 @@ func (c *Conn) gotoPARTOPEN() {
 func (c *Conn) gotoOPEN(hSeqNo int64) {
 	c.AssertLocked()
 	c.socket.SetOSR(hSeqNo)
+	// The handshake is complete, so Init Cookies are no longer echoed
+	c.initCookies = nil
 	c.socket.SetState(OPEN)
 	c.emitSetState()
 	c.openCCID()
@@ -178,10 +187,18 @@ func (c *Conn) gotoTIMEWAIT() {
 	c.emitSetState()
 	c.closeCCID()
 
-	c.env.Go(func() {
-		c.env.Sleep(TIMEWAIT_TIMEOUT)
-		c.abortQuietly()
-	}, "gotoTIMEWAIT")
+	c.env.Expire(
+		func()bool {
+			c.Lock()
+			state := c.socket.GetState()
+			c.Unlock()
+			// The connection may be aborted before TIMEWAIT elapses
+			return state != TIMEWAIT
+		}, 
+		func() {
+			c.abortQuietly()
+		}, 
+		TIMEWAIT_TIMEOUT, EXPIRE_INTERVAL, "gotoTIMEWAIT")
 }
 
 func (c *Conn) gotoCLOSING() {
@@ -219,13 +236,71 @@ func (c *Conn) gotoCLOSING() {
 	}, "gotoCLOSING")
 }
 
+// gotoCLOSEREQ is used by the server to ask the client to close the connection, so that the
+// client rather than the server holds TIMEWAIT state, Section 8.3
+func (c *Conn) gotoCLOSEREQ() {
+	c.AssertLocked()
+	c.setError(ErrEOF)
+	c.teardownUser()
+	c.socket.SetState(CLOSEREQ)
+	c.emitSetState()
+	c.closeCCID()
+	c.inject(c.generateCloseReq())
+	c.env.Go(func() {
+		c.Lock()
+		rtt := c.socket.GetRTT()
+		c.Unlock()
+		c.amb.E(EventInfo, fmt.Sprintf("CLOSEREQ RTT=%dns", rtt))
+		b := newBackOff(c.env, 2*rtt, CLOSEREQ_BACKOFF_TIMEOUT, CLOSEREQ_BACKOFF_FREQ)
+		for {
+			err, _ := b.Sleep()
+			c.Lock()
+			state := c.socket.GetState()
+			if state != CLOSEREQ {
+				c.Unlock()
+				break
+			}
+			// If the client does not respond, close without holding TIMEWAIT state
+			if err != nil {
+				c.reset(ResetClosed, ErrEOF)
+				c.Unlock()
+				break
+			}
+			c.amb.E(EventInfo, "Resend CloseReq")
+			c.inject(c.generateCloseReq())
+			c.Unlock()
+		}
+	}, "gotoCLOSEREQ")
+}
+
 // gotoCLOSED MUST be idempotent
 func (c *Conn) gotoCLOSED() {
 	c.AssertLocked()
 	c.emitSetState()
 	c.socket.SetState(CLOSED)
 	c.setError(ErrAbort)
+	c.leaveLISTEN()
 	c.teardownUser()
 	c.teardownWriteLoop()
 	c.closeCCID()
 }
+
+// leaveLISTEN releases the waiters of waitLISTEN, once a server has left LISTEN
+func (c *Conn) leaveLISTEN() {
+	c.AssertLocked()
+	if c.listenDone != nil {
+		close(c.listenDone)
+		c.listenDone = nil
+	}
+}
+
+// waitLISTEN blocks until a server has left LISTEN, either because it received a Request and
+// its Service Code is known, or because the connection was closed
+func (c *Conn) waitLISTEN() {
+	c.Lock()
+	ch := c.listenDone
+	c.Unlock()
+	if ch != nil {
+		<-ch
+	}
+}
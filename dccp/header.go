@@ -4,9 +4,8 @@
 
 package dccp
 
-// We use a subset of DCCP, defined as follows:
-//	X=1
-//      allowShortSeqNoFeature=0
+// Short sequence numbers (X=0) are used only on Data, Ack and DataAck packets, and only
+// when the Allow Short Seqnos feature permits it, see Section 7.6
 
 // Any DCCP header has a subset of the following subheaders, in this order:
 // (1a) Generic header
@@ -21,9 +20,9 @@ type Header struct {
 	CCVal       int8      // Used by the HC-Sender's CCID to transmit 4-bit values
 	CsCov       byte      // Specifies the parts of packet covered by the checksum
 	Type        byte      // Packet type: Data, Ack, Sync, etc.
-	X           bool      // Extended seq numbers: generally always true (for us)
-	SeqNo       int64     // 48-bit if X=1
-	AckNo       int64     // 48-bit if X=1
+	X           bool      // Extended seq numbers: false only for short seq numbers
+	SeqNo       int64     // 48-bit if X=1, 24-bit on the wire otherwise
+	AckNo       int64     // 48-bit if X=1, 24-bit on the wire otherwise
 	ServiceCode uint32    // ServiceCode: Applicaton level service (in Req,Resp pkts)
 	ResetCode   byte      // ResetCode: Reason for reset (in Reset pkts)
 	ResetData   []byte    // ResetData: Additional reset info (in Reset pkts)
@@ -31,10 +30,12 @@ type Header struct {
 	Data        []byte    // Application data (in Req, Resp, Data, DataAck pkts) 
 	// Ignored (in Ack, Close, CloseReq, Sync, SyncAck pkts)
 	// Error text (in Reset pkts)
+
+	ECN         byte      // ECN codepoint of the carrying IP packet, not part of the wire format
 }
 
 const (
-	SEQNOMAX = (2 << 48) - 1
+	SEQNOMAX = 1<<48 - 1 // Greatest sequence number, Section 7.1
 )
 
 // Packet types. Stored in the Type field of the generic header.
@@ -19,6 +19,12 @@ func (h *Header) InitCloseHeader() {
 	h.X    = true
 }
 
+// InitCloseReqHeader() creates a new CloseReq header
+func (h *Header) InitCloseReqHeader() {
+	h.Type = CloseReq
+	h.X    = true
+}
+
 // InitAckHeader() creates a new Ack header
 func (h *Header) InitAckHeader() {
 	h.Type = Ack
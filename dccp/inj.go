@@ -14,6 +14,8 @@ type writeHeader struct {
 	Header
 	SeqAckType   int
 	InResponseTo *Header
+	PMTUProbe    int32 // Size of the packet, if it is a Path MTU probe, or zero otherwise
+	Deadline     int64 // Time after which the packet is discarded, rather than sent, or zero if none
 }
 
 // inject adds the packet h to the outgoing non-Data pipeline, without blocking.  The
@@ -47,13 +49,13 @@ func (c *Conn) inject(h *writeHeader) {
 
 func (c *Conn) WriteCC(h *Header, timeWrite int64) {
 	// HC-Sender CCID
-	ccval, sropts := c.scc.OnWrite(&PreHeader{Type: h.Type, X: h.X, SeqNo: h.SeqNo, AckNo: h.AckNo, TimeWrite: timeWrite})
+	ccval, sropts := c.scc.OnWrite(&PreHeader{Type: h.Type, X: h.X, SeqNo: h.SeqNo, AckNo: h.AckNo, TimeWrite: timeWrite, DataLen: len(h.Data)})
 	if !validateCCIDSenderToReceiver(sropts) {
 		panic("sender congestion control writes disallowed options")
 	}
 	h.CCVal = ccval
 	// HC-Receiver CCID
-	rsopts := c.rcc.OnWrite(&PreHeader{Type: h.Type, X: h.X, SeqNo: h.SeqNo, AckNo: h.AckNo, TimeWrite: timeWrite})
+	rsopts := c.rcc.OnWrite(&PreHeader{Type: h.Type, X: h.X, SeqNo: h.SeqNo, AckNo: h.AckNo, TimeWrite: timeWrite, DataLen: len(h.Data)})
 	if !validateCCIDReceiverToSender(rsopts) {
 		panic("receiver congestion control writes disallowed options")
 	}
@@ -62,8 +64,191 @@ func (c *Conn) WriteCC(h *Header, timeWrite int64) {
 	c.amb.E(EventInfo, fmt.Sprintf("CC placed %d options", len(h.Options)), h)
 }
 
+// WriteFeatures attaches any pending feature negotiation options to h
+func (c *Conn) WriteFeatures(h *Header) {
+	c.AssertLocked()
+	c.feature.Write(h, c.env.Now(), c.socket.GetRTT())
+}
+
+// WriteAckVector attaches an Ack Vector option to h, if h carries an Acknowledgement
+// Number and Send Ack Vector is on
+func (c *Conn) WriteAckVector(h *Header) {
+	c.AssertLocked()
+	if !c.socket.GetSendAckVector() || !h.HasAckNo() {
+		return
+	}
+	av := c.ackVector.makeOption(h.AckNo)
+	if av == nil {
+		return
+	}
+	opt, err := av.Encode()
+	if err != nil {
+		c.amb.E(EventError, fmt.Sprintf("Ack Vector encode error (%s)", err), h)
+		return
+	}
+	h.Options = append(h.Options, opt)
+	c.ackVector.OnWrite(h.SeqNo, h.AckNo, av)
+}
+
+// WriteDataDropped attaches a Data Dropped option to h, if h carries an Acknowledgement
+// Number and the data of some acknowledged packets did not reach the application, Section 11.7
+func (c *Conn) WriteDataDropped(h *Header) {
+	c.AssertLocked()
+	if !h.HasAckNo() {
+		return
+	}
+	dd := c.dataDropped.makeOption(h.AckNo)
+	if dd == nil {
+		return
+	}
+	opt, err := dd.Encode()
+	if err != nil {
+		c.amb.E(EventError, fmt.Sprintf("Data Dropped encode error (%s)", err), h)
+		return
+	}
+	h.Options = append(h.Options, opt)
+	c.dataDropped.OnWrite(h.SeqNo, h.AckNo, dd)
+}
+
+// WriteECN sends h ECN-capable with a random ECN Nonce, if ECN is in use, Section 12.2. It
+// remembers the nonce of every packet, so that the Nonce Echoes of the other side's Ack Vectors
+// can be verified.
+func (c *Conn) WriteECN(h *Header) {
+	c.AssertLocked()
+	h.ECN = ECNNotECT
+	if c.socket.GetECN() {
+		h.ECN = c.ecnNonces.Choose(c.env)
+	}
+	c.ecnNonces.OnWrite(h.SeqNo, ecnNonce(h.ECN))
+}
+
+// WriteInitCookies echoes the Init Cookie options of the server's Response on every packet
+// sent in PARTOPEN, Section 8.1.4
+func (c *Conn) WriteInitCookies(h *Header) {
+	c.AssertLocked()
+	if c.socket.GetState() != PARTOPEN || h.Type == Data {
+		return
+	}
+	h.Options = append(h.Options, c.initCookies...)
+}
+
+// WriteNDPCount attaches an NDP Count option to h, if Send NDP Count/A is one and h follows a
+// run of non-data packets, Section 7.7. It counts the consecutive non-data packets sent.
+func (c *Conn) WriteNDPCount(h *Header) {
+	c.AssertLocked()
+	if c.socket.GetSendNDPCount() && c.ndpCount > 0 {
+		opt, err := (&NDPCountOption{Count: c.ndpCount}).Encode()
+		if err != nil {
+			c.amb.E(EventError, fmt.Sprintf("NDP Count encode error (%s)", err), h)
+		} else {
+			h.Options = append(h.Options, opt)
+		}
+	}
+	if isDataPacket(h.Type) {
+		c.ndpCount = 0
+	} else {
+		c.ndpCount++
+	}
+}
+
+// WriteSlowReceiver attaches a Slow Receiver option to h, while the application falls behind
+// in reading received data or has declared itself slow, Section 11.6
+func (c *Conn) WriteSlowReceiver(h *Header) {
+	c.AssertLocked()
+	if !c.slowReceiver && !c.isReadAppBackingUp() {
+		return
+	}
+	h.Options = append(h.Options, &Option{Type: OptionSlowReceiver, Mandatory: false})
+}
+
+// isReadAppBackingUp returns true if more than half of the read queue is occupied by data
+// that the application has not read yet
+func (c *Conn) isReadAppBackingUp() bool {
+	c.readAppLk.Lock()
+	defer c.readAppLk.Unlock()
+	return c.readApp != nil && len(c.readApp) > cap(c.readApp)/2
+}
+
+// WriteX clears the X bit of Data, Ack and DataAck packets, so that they carry short sequence
+// numbers, if Allow Short Seqnos/A is one and both sequence windows are narrow enough to extend
+// the numbers unambiguously, Section 7.6. Packets carrying feature negotiation options use long
+// sequence numbers, since the other side may not have concluded the negotiation yet. So do
+// packets echoing Init Cookies, since the other side has no sequence number state yet.
+func (c *Conn) WriteX(h *Header) {
+	c.AssertLocked()
+	if !c.socket.GetAllowShortSeqNosA() {
+		return
+	}
+	if h.Type != Data && h.Type != Ack && h.Type != DataAck {
+		return
+	}
+	if c.socket.GetSWAF() > SEQWIN_SHORT_MAX || c.socket.GetSWBF() > SEQWIN_SHORT_MAX {
+		return
+	}
+	for _, opt := range h.Options {
+		if isOptionFeature(opt.Type) || opt.Type == OptionInitCookie {
+			return
+		}
+	}
+	h.X = false
+}
+
+// isWriteNonDataBackingUp returns true if more than half of the queue of non-Data packets is
+// occupied by packets waiting to be sent
+func (c *Conn) isWriteNonDataBackingUp() bool {
+	c.writeNonDataLk.Lock()
+	defer c.writeNonDataLk.Unlock()
+	return len(c.writeNonData) > cap(c.writeNonData)/2
+}
+
+// WritePMTUProbe pads a Path MTU probe to its size, once all options have been attached, and
+// records its sequence number, Section 14.1
+func (c *Conn) WritePMTUProbe(h *writeHeader) {
+	c.AssertLocked()
+	if h.PMTUProbe == 0 {
+		return
+	}
+	if n, err := h.Header.getHeaderFootprint(false); err == nil && int(h.PMTUProbe) > n {
+		h.Data = make([]byte, int(h.PMTUProbe)-n)
+	}
+	c.pmtu.OnWrite(h.PMTUProbe, h.SeqNo, c.env.Now())
+}
+
+// isStale returns true if data with the given deadline, zero meaning none, must not be sent
+func (c *Conn) isStale(deadline int64) bool {
+	return deadline > 0 && c.env.Now() >= deadline
+}
+
+// dropStale discards a block of application data, whose deadline has passed before it could
+// be sent. The header h, which was to carry the data, is nil if it has not been generated.
+func (c *Conn) dropStale(h *Header) {
+	c.Lock()
+	c.staleCount++
+	c.Unlock()
+	if h != nil {
+		c.amb.E(EventDrop, "Stale data", h)
+	} else {
+		c.amb.E(EventDrop, "Stale data")
+	}
+}
+
 func (c *Conn) write(h *writeHeader) error {
-	c.scc.Strobe()
+	c.Lock()
+	scc := c.scc
+	c.Unlock()
+	// A strobe left unused by stale data is carried over to the next packet, so that
+	// discarding data does not slow down the sending rate
+	if !c.strobeHeld {
+		scc.Strobe(h.Type)
+	}
+	c.strobeHeld = false
+
+	// Data may have gone stale while waiting for the strobe
+	if c.isStale(h.Deadline) {
+		c.strobeHeld = true
+		c.dropStale(&h.Header)
+		return nil
+	}
 
 	// Tell the CCID about h right before it gets sent, so we can fill in
 	// the nearly exact time of sending.  This way, the roundtrip
@@ -74,7 +259,19 @@ func (c *Conn) write(h *writeHeader) error {
 	// before the CCID gets to see it?
 	c.Lock()
 	c.WriteSeqAck(h)
+	c.WriteECN(&h.Header)
+	c.WriteFeatures(&h.Header)
+	c.WriteInitCookies(&h.Header)
+	c.WriteAckVector(&h.Header)
+	c.WriteDataDropped(&h.Header)
+	c.WriteNDPCount(&h.Header)
+	c.WriteSlowReceiver(&h.Header)
+	c.WriteX(&h.Header)
 	c.WriteCC(&h.Header, c.writeTime.Now())
+	c.WritePMTUProbe(h)
+	if h.Type == Data || h.Type == DataAck {
+		c.dataTime = c.writeTime.Last()
+	}
 	c.Unlock()
 
 	c.amb.E(EventWrite, "Write to header link", h)
@@ -83,7 +280,7 @@ func (c *Conn) write(h *writeHeader) error {
 
 // writeLoop() sends headers incoming on the writeData and writeNonData channels, while
 // giving priority to writeNonData. It continues to do so until writeNonData is closed.
-func (c *Conn) writeLoop(writeNonData chan *writeHeader, writeData chan []byte) {
+func (c *Conn) writeLoop(writeNonData chan *writeHeader, writeData chan *appData) {
 
 	// The presence of multiple loops below allows user calls to Write to
 	// block in "writeNonData <-" while the connection moves into a state where
@@ -113,10 +310,16 @@ _Loop_I:
 		}
 		c.Lock()
 		state := c.socket.GetState()
+		echo := len(c.initCookies) > 0
 		c.Unlock()
 		switch state {
-		case OPEN, PARTOPEN:
+		case OPEN:
 			goto _Loop_II
+		case PARTOPEN:
+			// Data must not be sent while Init Cookies are echoed, Section 8.1.4
+			if !echo {
+				goto _Loop_II
+			}
 		}
 		continue _Loop_I
 	}
@@ -128,7 +331,7 @@ _Loop_II:
 	for {
 		var h *writeHeader
 		var ok bool
-		var appData []byte
+		var d *appData
 		select {
 		// Note that non-Data packets take precedence
 		case h, ok = <-writeNonData:
@@ -136,7 +339,7 @@ _Loop_II:
 				// Closing writeNonData means that the Conn is done and dead
 				goto _Exit
 			}
-		case appData, ok = <-writeData:
+		case d, ok = <-writeData:
 			if !ok {
 				// When writeData is closed, we transition to the 3rd loop,
 				// which accepts only non-Data packets
@@ -147,13 +350,20 @@ _Loop_II:
 			// received, and so AckNo can be filled in meaningfully (below) in the
 			// DataAck packet
 
+			// Stale data is discarded without waiting for the strobe
+			if c.isStale(d.Deadline) {
+				c.dropStale(nil)
+				continue _Loop_II
+			}
+
 			// We allow 0-length app data packets. No reason not to.
 			// XXX: I am not sure if Header.Data == nil (rather than
 			// Header.Data = []byte{}) would cause a problem in Header.Write
 			// It should be that it doesn't. Must verify this.
 			c.Lock()
-			h = c.generateDataAck(appData)
+			h = c.generateDataAck(d.Data, d.CsCov)
 			c.Unlock()
+			h.Deadline = d.Deadline
 		}
 		if h != nil {
 			err := c.write(h)
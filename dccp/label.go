@@ -6,9 +6,10 @@ package dccp
 
 import (
 	"bytes"
+	"crypto/rand"
 	"errors"
 	"hash/crc64"
-	"math/rand"
+	"io"
 	"strings"
 )
 
@@ -48,14 +49,17 @@ func isZero(bb []byte) bool {
 	return true
 }
 
-// ChooseLabel() creates a new label by choosing its bytes randomly
+// ChooseLabel() creates a new label by choosing its bytes from a cryptographically
+// secure random source
 func ChooseLabel() *Label {
+	return ChooseLabelFrom(rand.Reader)
+}
+
+// ChooseLabelFrom() creates a new label by reading its bytes from the random source r
+func ChooseLabelFrom(r io.Reader) *Label {
 	label := &Label{}
-	for i := 0; i < LabelLen/2; i++ {
-		q := rand.Int()
-		label.data[2*i] = byte(q & 0xff)
-		q >>= 8
-		label.data[2*i+1] = byte(q & 0xff)
+	if _, err := io.ReadFull(r, label.data[:]); err != nil {
+		panic("reading random source")
 	}
 	label.hash()
 	return label
@@ -37,3 +37,23 @@ type Link interface {
 	// Close terminates the link gracefully
 	Close() error
 }
+
+// ECNLink is implemented by links that can set and observe the ECN codepoint, carried in the
+// IP header, of every packet, RFC 3168. The codepoints are ECNNotECT, ECNECT0, ECNECT1 and ECNCE.
+type ECNLink interface {
+	Link
+
+	// ReadFromECN is like ReadFrom, except that it also returns the ECN codepoint of the packet
+	ReadFromECN(buf []byte) (n int, addr net.Addr, ecn byte, err error)
+
+	// WriteToECN is like WriteTo, except that the packet is sent with the ECN codepoint ecn
+	WriteToECN(buf []byte, addr net.Addr, ecn byte) (n int, err error)
+}
+
+// AddrLink is implemented by links that know their own address
+type AddrLink interface {
+	Link
+
+	// LocalAddr returns the address of the local side of the link
+	LocalAddr() net.Addr
+}
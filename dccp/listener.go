@@ -4,94 +4,66 @@
 
 package dccp
 
-/*
 import (
 	"net"
+	"sync"
 )
 
-
-// Listener{} takes care of listening for incoming connections.
-// It handles the LISTEN state.
+// Listener accepts the connections of a Stack, which request the Service Code of the
+// Listener. It implements net.Listener. Accepted connections are returned as NetConns.
 type Listener struct {
-	m     *Mux
-	conns []*Conn	// List of active connections
-			// TODO: Lookups in a short array should be fine for now. Hashing?
-}
-
-func Listen(m *Mux) net.Listener {
-	l := &Listener{ m: m, }
-	go l.loop()
-	return l
-}
-
-// loop() reads and processes incoming packets
-func (l *Listener) loop() {
-	for {
-		h,err := e.readPacket(l.phy, zeroFlowID)
-		if err != nil {
-			continue XX // no continue
-		}
-		?
-	}
+	s           *Stack
+	serviceCode uint32
+	svc         *service
+	closeOnce   sync.Once
 }
 
-// readAndSwitch() reads an incoming packet and sends it over to
-// its Conn{} destination if any, or returns it otherwise
-func (l *listener) readAndSwitch() (h *Header, err os.Error) {
-	h, err = read(l.phy)
-	if err != nil {
-		return 
+// Listen returns a Listener for the connections that request the Service Code serviceCode.
+// If ccids are given, these connections support them instead of the congestion controls of the
+// Stack. Listen registers the Service Code like Handle, which describes how connections are
+// routed.
+func (s *Stack) Listen(serviceCode uint32, ccids ...CCID) (net.Listener, error) {
+	svc := &service{ccids: ccids, accepted: make(chan *Conn), done: make(chan int)}
+	if err := s.register(serviceCode, svc); err != nil {
+		return nil, err
 	}
-	?
+	return &Listener{s: s, serviceCode: serviceCode, svc: svc}, nil
 }
 
-// read() reads the next buffer of data from the link layer
-// and tries to parse it into a valid Header{}
-func read(r Reader) (*Header, os.Error) {
-
-	// Read packet from physical layer
-	buf, phyFlowID, err := r.Read()
-	if err != nil {
-		return nil, err
-	}
-	// Parse generic header
-	h, err := ReadHeader(buf, zeroPhyFlowID.SourceAddr, zeroPhyFlowID.DestAddr, AnyProto, false)
+// Accept implements net.Listener.Accept
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.AcceptDCCP()
 	if err != nil {
 		return nil, err
 	}
-	// Ensure extended SeqNo's 
-	if !h.X {
-		return nil, ErrUnsupported
-	}
-	return h, nil
+	return NewNetConn(c), nil
 }
 
-func arrayEqual(a,b []byte) bool {
-	if len(a) != len(b) {
-		return false
+// AcceptDCCP is like Accept, except that it returns the underlying Conn
+func (l *Listener) AcceptDCCP() (*Conn, error) {
+	select {
+	case c := <-l.svc.accepted:
+		return c, nil
+	case <-l.svc.done:
+		return nil, ErrBad
+	case <-l.s.routeDone:
+		return nil, l.s.routeErr
 	}
-	for i := 0; i < len(a); i++ {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
+	panic("unreach")
 }
 
-func (l *Listener) Accept() (c Conn, err os.Error) {
-	? //XX
-	h,err := e.readPacket()
-	if err != nil {
-		return nil, err
-	}
-
+// Close implements net.Listener.Close. The Service Code of the Listener is unregistered, and
+// blocked and subsequent calls to Accept return ErrBad. Connections accepted earlier are not
+// affected.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.s.unregister(l.serviceCode, l.svc)
+		close(l.svc.done)
+	})
+	return nil
 }
 
-func (l *Listener) Close() os.Error {
-	? //XX
-	return l.phy.Close();
+// Addr implements net.Listener.Addr. It returns the address of the local side of the link.
+func (l *Listener) Addr() net.Addr {
+	return &FlowAddr{Link: l.s.mux.localAddr()}
 }
-
-func (l *Listener) Addr() net.Addr { return DefaultAddr }
-
-*/
@@ -22,6 +22,13 @@ func (x *monotoneTime) Init(env *Env) {
 	x.last = 0
 }
 
+// Last returns the time returned by the latest call to Now, or zero if there was none
+func (x *monotoneTime) Last() int64 {
+	x.Lock()
+	defer x.Unlock()
+	return x.last
+}
+
 func (x *monotoneTime) Now() int64 {
 	x.Lock()
 	defer x.Unlock()
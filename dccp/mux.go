@@ -5,6 +5,8 @@
 package dccp
 
 import (
+	"crypto/rand"
+	"io"
 	"net"
 	"time"
 )
@@ -25,12 +27,16 @@ type Mux struct {
 	lingerLocal  map[uint64]time.Time // Local labels of recently-closed flows mapped to time of closure
 	lingerRemote map[uint64]time.Time
 	acceptChan   chan *flow
+	random       io.Reader          // Source of random local labels
+	listener     *statelessListener // Answers the packets of flows that are not accepted, or nil
 }
 
 const (
-	MuxLingerTime = 60e9  // 1 min in nanoseconds
-	MuxExpireTime = 600e9 // 10 min in nanoseconds
-	MuxReadSafety = 5
+	MuxLingerTime  = 60e9  // 1 min in nanoseconds
+	MuxExpireTime  = 600e9 // 10 min in nanoseconds
+	MuxReadSafety  = 5
+	MuxFlowQueue   = 64    // Number of incoming packets queued per flow
+	MuxAcceptQueue = 16    // Number of incoming connections queued for Accept
 )
 
 // muxHeader is an internal data structure that carries a parsed switch packet,
@@ -38,6 +44,7 @@ const (
 type muxHeader struct {
 	Msg   *muxMsg
 	Cargo []byte
+	ECN   byte // ECN codepoint of the carrying packet
 }
 
 // NewMux creates a new Mux object, using the connection-less packet interface link
@@ -49,6 +56,7 @@ func NewMux(link Link) *Mux {
 		lingerLocal:  make(map[uint64]time.Time),
 		lingerRemote: make(map[uint64]time.Time),
 		acceptChan:   make(chan *flow),
+		random:       rand.Reader,
 	}
 	go m.readLoop()
 	go m.expireLingeringLoop()
@@ -56,6 +64,35 @@ func NewMux(link Link) *Mux {
 	return m
 }
 
+// SetRandom replaces the source of randomness, from which the local labels of new flows are
+// chosen. It allows tests to obtain deterministic labels. The default source, crypto/rand,
+// is cryptographically secure.
+func (m *Mux) SetRandom(r io.Reader) {
+	m.Lock()
+	defer m.Unlock()
+	m.random = r
+}
+
+// setListener makes the Mux pass the packets of flows, which it has not accepted, to l, see
+// statelessListener. Flows are then accepted only when l approves them.
+func (m *Mux) setListener(l *statelessListener) {
+	m.Lock()
+	defer m.Unlock()
+	m.listener = l
+}
+
+func (m *Mux) getListener() *statelessListener {
+	m.Lock()
+	defer m.Unlock()
+	return m.listener
+}
+
+func (m *Mux) chooseLabel() *Label {
+	m.Lock()
+	defer m.Unlock()
+	return ChooseLabelFrom(m.random)
+}
+
 // Accept() returns the first incoming flow request
 func (m *Mux) Accept() (c SegmentConn, err error) {
 	f, ok := <-m.acceptChan
@@ -67,8 +104,8 @@ func (m *Mux) Accept() (c SegmentConn, err error) {
 
 // Dial opens a packet-based connection to the Link-layer addr
 func (m *Mux) Dial(addr net.Addr) (c SegmentConn, err error) {
-	ch := make(chan muxHeader)
-	local := ChooseLabel()
+	ch := make(chan muxHeader, MuxFlowQueue)
+	local := m.chooseLabel()
 	f := newFlow(addr, m, ch, m.cargoMaxLen(), local, nil)
 
 	m.Lock()
@@ -109,7 +146,15 @@ func (m *Mux) readLoop() {
 
 		// Read incoming packet
 		buf := make([]byte, m.link.GetMTU()+MuxReadSafety)
-		n, addr, err := link.ReadFrom(buf)
+		var n int
+		var addr net.Addr
+		var ecn byte
+		var err error
+		if el, ok := link.(ECNLink); ok {
+			n, addr, ecn, err = el.ReadFromECN(buf)
+		} else {
+			n, addr, err = link.ReadFrom(buf)
+		}
 		if err != nil {
 			break
 		}
@@ -125,7 +170,7 @@ func (m *Mux) readLoop() {
 			continue
 		}
 
-		m.process(msg, cargo, addr)
+		m.process(msg, cargo, addr, ecn)
 	}
 	close(m.acceptChan)
 	m.Lock()
@@ -138,7 +183,7 @@ func (m *Mux) readLoop() {
 	m.Unlock()
 }
 
-func (m *Mux) process(msg *muxMsg, cargo []byte, addr net.Addr) {
+func (m *Mux) process(msg *muxMsg, cargo []byte, addr net.Addr, ecn byte) {
 	// REMARK: By design, only one copy of process() can run at a time (*)
 
 	// Every packet must have a source (remote) label
@@ -157,6 +202,10 @@ func (m *Mux) process(msg *muxMsg, cargo []byte, addr net.Addr) {
 		// If yes, then we must have a matching flow
 		f = m.findLocal(msg.Sink)
 		if f == nil {
+			// The local label may have been chosen by the listener
+			if l := m.getListener(); l != nil {
+				m.listen(l, msg, cargo, addr, ecn)
+			}
 			return
 		}
 		// Check if this is the first time we hear about the remote label on this flow
@@ -173,20 +222,48 @@ func (m *Mux) process(msg *muxMsg, cargo []byte, addr net.Addr) {
 	} else {
 		f = m.findRemote(msg.Source)
 		if f == nil {
-			f = m.accept(msg.Source, addr)
+			if l := m.getListener(); l != nil {
+				m.listen(l, msg, cargo, addr, ecn)
+				return
+			}
+			f = m.accept(m.chooseLabel(), msg.Source, addr)
 		}
 	}
 
-	f.ch <- muxHeader{msg, cargo}
+	f.deliver(muxHeader{msg, cargo, ecn})
+}
+
+// listen answers the packet, which arrived from addr on a flow that has not been accepted, with
+// the listener l. No flow is created, unless l approves the packet. Then the flow is accepted,
+// with the packet as its first.
+func (m *Mux) listen(l *statelessListener, msg *muxMsg, cargo []byte, addr net.Addr, ecn byte) {
+	local := msg.Sink
+	if local == nil {
+		local = l.chooseLabel(msg.Source)
+	}
+	// Like a HeaderConn, the listener passes zero labels for the Source and Dest IPs
+	h, err := ReadHeader(cargo, LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, true)
+	if err != nil {
+		return
+	}
+	h.ECN = ecn
+	reply, accept := l.listen(h, local, msg.Source)
+	if reply != nil {
+		if p, err := reply.Write(LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, true); err == nil {
+			m.write(&muxMsg{local, msg.Source}, p, addr, ECNNotECT)
+		}
+	}
+	if accept {
+		m.accept(local, msg.Source, addr).deliver(muxHeader{msg, cargo, ecn})
+	}
 }
 
-func (m *Mux) accept(remote *Label, addr net.Addr) *flow {
+func (m *Mux) accept(local, remote *Label, addr net.Addr) *flow {
 	if remote == nil {
 		panic("remote == nil")
 	}
 
-	ch := make(chan muxHeader)
-	local := ChooseLabel()
+	ch := make(chan muxHeader, MuxFlowQueue)
 	f := newFlow(addr, m, ch, m.cargoMaxLen(), local, remote)
 
 	m.Lock()
@@ -327,7 +404,26 @@ func (m *Mux) del(local *Label, remote *Label) {
 
 func (m *Mux) cargoMaxLen() int { return m.link.GetMTU() - muxMsgFootprint }
 
-func (m *Mux) write(msg *muxMsg, block []byte, addr net.Addr) error {
+// isECNCapable returns true if the link carries ECN codepoints
+func (m *Mux) isECNCapable() bool {
+	m.Lock()
+	defer m.Unlock()
+	_, ok := m.link.(ECNLink)
+	return ok
+}
+
+// localAddr returns the address of the local side of the link, or nil if it is not known
+func (m *Mux) localAddr() net.Addr {
+	m.Lock()
+	defer m.Unlock()
+	if al, ok := m.link.(AddrLink); ok {
+		return al.LocalAddr()
+	}
+	return nil
+}
+
+// write sends block to addr, with the ECN codepoint ecn if the link carries ECN codepoints
+func (m *Mux) write(msg *muxMsg, block []byte, addr net.Addr, ecn byte) error {
 	m.Lock()
 	link := m.link
 	m.Unlock()
@@ -339,7 +435,13 @@ func (m *Mux) write(msg *muxMsg, block []byte, addr net.Addr) error {
 	msg.Write(buf)
 	copy(buf[muxMsgFootprint:], block)
 
-	n, err := link.WriteTo(buf, addr)
+	var n int
+	var err error
+	if el, ok := link.(ECNLink); ok {
+		n, err = el.WriteToECN(buf, addr, ecn)
+	} else {
+		n, err = link.WriteTo(buf, addr)
+	}
 	if n != muxMsgFootprint+len(block) {
 		panic("block divided")
 	}
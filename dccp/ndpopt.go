@@ -0,0 +1,45 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// NDPCountOption, Section 7.7
+// NDP Count reports the number of consecutive non-data packets sent immediately before the
+// packet carrying the option. Packets without an NDP Count option have NDP Count zero.
+type NDPCountOption struct {
+	Count uint64
+}
+
+func (opt *NDPCountOption) Encode() (*Option, error) {
+	if opt.Count >= 1<<48 {
+		return nil, ErrOverflow
+	}
+	// The smallest option format that can hold the NDP Count SHOULD be used
+	l := 1
+	for l < 6 && opt.Count >= 1<<(8*uint(l)) {
+		l++
+	}
+	return &Option{
+		Type:      OptionNDPCount,
+		Data:      encodeFeatureValue(opt.Count, l),
+		Mandatory: false,
+	}, nil
+}
+
+func DecodeNDPCountOption(opt *Option) *NDPCountOption {
+	if opt.Type != OptionNDPCount || len(opt.Data) < 1 || len(opt.Data) > 6 {
+		return nil
+	}
+	return &NDPCountOption{Count: decodeFeatureValue(opt.Data)}
+}
+
+// getNDPCount returns the NDP Count reported by the options opts
+func getNDPCount(opts []*Option) uint64 {
+	for _, opt := range opts {
+		if ndp := DecodeNDPCountOption(opt); ndp != nil {
+			return ndp.Count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+func TestNDPCountOption(t *testing.T) {
+	for _, tt := range []struct {
+		count uint64
+		len   int
+	}{{1, 1}, {255, 1}, {256, 2}, {1 << 24, 4}, {1<<48 - 1, 6}} {
+		opt, err := (&NDPCountOption{Count: tt.count}).Encode()
+		if err != nil {
+			t.Fatalf("encoding NDP count %d (%s)", tt.count, err)
+		}
+		if len(opt.Data) != tt.len {
+			t.Errorf("NDP count %d encoded in %d bytes, expecting %d", tt.count, len(opt.Data), tt.len)
+		}
+		if dec := DecodeNDPCountOption(opt); dec == nil || dec.Count != tt.count {
+			t.Errorf("NDP count %d decodes to %v", tt.count, dec)
+		}
+	}
+	if _, err := (&NDPCountOption{Count: 1 << 48}).Encode(); err == nil {
+		t.Errorf("encoded oversized NDP count")
+	}
+}
+
+// TestWriteNDPCount checks the NDP Counts of the example in Section 7.7.1
+func TestWriteNDPCount(t *testing.T) {
+	c := &Conn{amb: NoLogging}
+	c.socket.SetSendNDPCount(true)
+	types := []byte{Ack, Ack, Data, Ack, Data, Data, Ack, Data, Data, Data, Data, Ack, Ack, Data}
+	expect := []uint64{0, 1, 2, 0, 1, 0, 0, 1, 0, 0, 0, 0, 1, 2}
+	for i, typ := range types {
+		h := &Header{Type: typ}
+		c.WriteNDPCount(h)
+		if count := getNDPCount(h.Options); count != expect[i] {
+			t.Errorf("packet %d: NDP count %d, expecting %d", i, count, expect[i])
+		}
+	}
+}
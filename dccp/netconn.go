@@ -0,0 +1,133 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// NetConn adapts a Conn to the net.Conn interface. Every Read returns data from at most one
+// packet, so that message boundaries are preserved. The part of a packet that does not fit
+// into the buffer given to Read is returned by the subsequent Reads. Every Write sends its
+// data in a single packet, unless the data is larger than the MTU of the Conn, in which case
+// it is split into several packets.
+type NetConn struct {
+	c   *Conn
+	rlk Mutex  // Synchronizes calls to Read
+	buf []byte // Unread rest of the last packet
+}
+
+// NewNetConn returns a net.Conn, which reads and writes on the Conn c
+func NewNetConn(c *Conn) *NetConn {
+	return &NetConn{c: c}
+}
+
+// Conn returns the underlying Conn
+func (nc *NetConn) Conn() *Conn { return nc.c }
+
+// Read implements net.Conn.Read
+func (nc *NetConn) Read(p []byte) (n int, err error) {
+	nc.rlk.Lock()
+	defer nc.rlk.Unlock()
+	if len(nc.buf) == 0 {
+		nc.buf, err = nc.c.Read()
+		if err != nil {
+			return 0, netError(err)
+		}
+	}
+	n = copy(p, nc.buf)
+	nc.buf = nc.buf[n:]
+	return n, nil
+}
+
+// Write implements net.Conn.Write
+func (nc *NetConn) Write(p []byte) (n int, err error) {
+	mtu := nc.c.GetMTU()
+	if mtu <= 0 {
+		// The link has no room for data, for instance because it has been closed
+		if err = nc.c.Error(); err != nil {
+			return 0, netError(err)
+		}
+		return 0, ErrInvalid
+	}
+	for {
+		k := len(p) - n
+		if k > mtu {
+			k = mtu
+		}
+		if err = nc.c.Write(p[n : n+k]); err != nil {
+			return n, netError(err)
+		}
+		n += k
+		if n == len(p) {
+			return n, nil
+		}
+	}
+}
+
+// Close implements net.Conn.Close
+func (nc *NetConn) Close() error {
+	return nc.c.Close()
+}
+
+// LocalAddr implements net.Conn.LocalAddr, see Conn.LocalAddr
+func (nc *NetConn) LocalAddr() net.Addr {
+	return nc.c.LocalAddr()
+}
+
+// RemoteAddr implements net.Conn.RemoteAddr, see Conn.RemoteAddr
+func (nc *NetConn) RemoteAddr() net.Addr {
+	return nc.c.RemoteAddr()
+}
+
+// SetDeadline implements net.Conn.SetDeadline
+func (nc *NetConn) SetDeadline(t time.Time) error {
+	nc.SetReadDeadline(t)
+	nc.SetWriteDeadline(t)
+	return nil
+}
+
+// SetReadDeadline implements net.Conn.SetReadDeadline
+func (nc *NetConn) SetReadDeadline(t time.Time) error {
+	nc.c.SetReadDeadline(nc.envTime(t))
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn.SetWriteDeadline
+func (nc *NetConn) SetWriteDeadline(t time.Time) error {
+	nc.c.SetWriteDeadline(nc.envTime(t))
+	return nil
+}
+
+// envTime converts the wall clock time t to the time of the Env of the Conn. The zero time,
+// meaning no deadline, is converted to zero.
+func (nc *NetConn) envTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return nc.c.env.Now() + int64(t.Sub(time.Now()))
+}
+
+// netError converts the errors of Conn to the ones expected from a net.Conn
+func netError(err error) error {
+	switch err {
+	case ErrTimeout:
+		return errNetTimeout
+	case ErrEOF:
+		return io.EOF
+	}
+	return err
+}
+
+// netTimeoutError is the net.Error returned by NetConn when a deadline expires
+type netTimeoutError struct{}
+
+var errNetTimeout net.Error = netTimeoutError{}
+
+func (netTimeoutError) Error() string   { return ErrTimeout.Error() }
+func (netTimeoutError) Timeout() bool   { return true }
+func (netTimeoutError) Temporary() bool { return true }
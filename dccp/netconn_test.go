@@ -0,0 +1,42 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetConnNoMTU(t *testing.T) {
+	hc := newTestHeaderConn()
+	hc.mtu = 0
+	hc.expire = time.Second
+	c := newConnClient(NewEnv(nil), NoLogging, hc, []CCID{CCFixed{}}, 7, nil)
+	nc := NewNetConn(c)
+
+	// A link without room for data fails the Write, rather than splitting the data endlessly
+	write := func() error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := nc.Write([]byte{1, 2, 3})
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write does not return")
+		}
+		panic("unreach")
+	}
+	if err := write(); err != ErrInvalid {
+		t.Errorf("expecting invalid write, got %v", err)
+	}
+	// A closed connection reports its error
+	c.Abort()
+	if err := write(); err != ErrAbort {
+		t.Errorf("expecting aborted write, got %v", err)
+	}
+}
@@ -111,3 +111,13 @@ func isOptionValidForType(optionType, Type byte) bool {
 	}
 	panic("unreach")
 }
+
+// hasSlowReceiver returns true if opts contain a Slow Receiver option, Section 11.6
+func hasSlowReceiver(opts []*Option) bool {
+	for _, opt := range opts {
+		if opt.Type == OptionSlowReceiver {
+			return true
+		}
+	}
+	return false
+}
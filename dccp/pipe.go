@@ -4,6 +4,8 @@
 
 package dccp
 
+import "fmt"
+
 func (c *Conn) readHeader() (h *Header, err error) {
 	h, err = c.hc.Read()
 	if err != nil {
@@ -12,10 +14,6 @@ func (c *Conn) readHeader() (h *Header, err error) {
 		}
 		return nil, err
 	}
-	// We don't support non-extended (short) SeqNo's 
-	if !h.X {
-		return nil, ErrUnsupported
-	}
 	return h, nil
 }
 
@@ -27,8 +25,18 @@ func (c *Conn) idleLoop() {
 
 		c.Lock()
 		c.syncWithCongestionControl()
+		c.syncSequenceWindow()
 		rtt := c.socket.GetRTT()
 		state := c.socket.GetState()
+		// Send a feature negotiation packet, if Change options are due for retransmission
+		if (state == OPEN || state == PARTOPEN) && c.feature.PollChanges(c.env.Now(), max64(rtt, RoundtripMin)) {
+			c.inject(c.generateAck())
+		} else if state == OPEN && c.isHeartbeatDue() {
+			c.inject(c.generateAck())
+		}
+		if state == OPEN {
+			c.pollPMTU()
+		}
 		c.Unlock()
 
 		if state == CLOSED {
@@ -36,10 +44,22 @@ func (c *Conn) idleLoop() {
 		}
 		// This emit prints very often. Use when really necessary
 		//c.amb.E(EventIdle, "")
-		c.env.Sleep(max64(RoundtripMin, min64(rtt, RoundtripDefault)))
+		sleep := max64(RoundtripMin, min64(rtt, RoundtripDefault))
+		if hb := c.config.Heartbeat; hb > 0 {
+			sleep = min64(sleep, hb)
+		}
+		c.env.Sleep(sleep)
 	}
 }
 
+// isHeartbeatDue returns true if the connection sends heartbeats, and nothing has been sent
+// for a heartbeat interval, see Config.Heartbeat
+func (c *Conn) isHeartbeatDue() bool {
+	c.AssertLocked()
+	hb := c.config.Heartbeat
+	return hb > 0 && c.env.Now()-c.writeTime.Last() >= hb
+}
+
 func (c *Conn) readLoop() {
 	for {
 		c.Lock()
@@ -77,6 +97,11 @@ func (c *Conn) readLoop() {
 		c.amb.E(EventRead, "", h)
 
 		c.Lock()
+		// A packet read while the connection was being aborted is not processed. In particular,
+		// a Reset must not move the connection from CLOSED back into TIMEWAIT.
+		if c.socket.GetState() == CLOSED {
+			goto Done
+		}
 		c.syncWithCongestionControl()
 		if c.step2_ProcessTIMEWAIT(h) != nil {
 			goto Done
@@ -131,7 +156,10 @@ func (c *Conn) readLoop() {
 
 func (c *Conn) pollCongestionControl() {
 	now := c.env.Now()
-	if e := c.scc.OnIdle(now); e != nil {
+	c.Lock()
+	scc, rcc := c.scc, c.rcc
+	c.Unlock()
+	if e := scc.OnIdle(now); e != nil {
 		if re, ok := e.(CongestionReset); ok {
 			c.abortWith(re.ResetCode())
 			return
@@ -144,7 +172,7 @@ func (c *Conn) pollCongestionControl() {
 		}
 		c.amb.E(EventError, "Sender CC unknown idle error")
 	}
-	if e := c.rcc.OnIdle(now); e != nil {
+	if e := rcc.OnIdle(now); e != nil {
 		if re, ok := e.(CongestionReset); ok {
 			c.abortWith(re.ResetCode())
 			return
@@ -163,9 +191,188 @@ func (c *Conn) syncWithCongestionControl() {
 	c.AssertLocked()
 	c.socket.SetRTT(c.scc.GetRTT())
 	c.socket.SetCCMPS(c.scc.GetCCMPS())
+	if ar, ok := c.scc.(AckRatioSender); ok {
+		c.feature.ChangeValue(FeatureAckRatio, uint64(max64(1, min64(0xffff, ar.GetAckRatio()))))
+	}
+}
+
+// syncSequenceWindow adapts the Sequence Window feature located at this endpoint to about
+// five times the number of packets sent per round-trip time, Section 7.5.2. The window is
+// never narrower than the one requested by the application.
+func (c *Conn) syncSequenceWindow() {
+	c.AssertLocked()
+	w := c.seqWindow
+	if pr, ok := c.scc.(PacketRateSender); ok {
+		w = max64(w, 5*pr.GetPacketsPerRTT())
+	}
+	w = min64(w, SEQWIN_MAX)
+	// Widen the window as soon as the rate grows, but narrow it only when it is much too wide,
+	// so that small rate fluctuations do not cause renegotiations
+	target := int64(c.feature.GetTarget(FeatureSequenceWindow))
+	if w > target || 4*w < target {
+		c.feature.ChangeValue(FeatureSequenceWindow, uint64(w))
+	}
+}
+
+// syncWithFeatures applies the current feature values to the socket and the congestion
+// controls. It returns false if the CCIDs in effect are not supported.
+func (c *Conn) syncWithFeatures() bool {
+	c.AssertLocked()
+	c.socket.SetCCIDA(byte(c.feature.Get(FeatureCCID, true)))
+	c.socket.SetCCIDB(byte(c.feature.Get(FeatureCCID, false)))
+	c.socket.SetSWAF(int64(c.feature.Get(FeatureSequenceWindow, true)))
+	c.socket.SetSWBF(int64(c.feature.Get(FeatureSequenceWindow, false)))
+	c.socket.SetSendAckVector(c.feature.Get(FeatureSendAckVector, true) == 1)
+	c.socket.SetSendNDPCount(c.feature.Get(FeatureSendNDPCount, true) == 1)
+	c.socket.SetECN(c.isLinkECNCapable() && c.feature.Get(FeatureECNIncapable, false) == 0)
+	c.socket.SetMinCsCovA(byte(c.feature.Get(FeatureMinCsCov, true)))
+	c.socket.SetMinCsCovB(byte(c.feature.Get(FeatureMinCsCov, false)))
+	c.socket.SetAllowShortSeqNosA(c.feature.Get(FeatureAllowShortSeqNos, true) == 1)
+	c.socket.SetAllowShortSeqNosB(c.feature.Get(FeatureAllowShortSeqNos, false) == 1)
+	ok := c.syncCCID()
+	if ar, ok := c.rcc.(AckRatioReceiver); ok {
+		ar.SetAckRatio(int64(c.feature.Get(FeatureAckRatio, false)))
+	}
+	// CCID 2 requires the HC-Receiver to send Ack Vectors, RFC 4341 Section 3
+	if c.socket.GetCCIDA() == CCID2 && c.feature.SetPrefs(FeatureSendAckVector, false, []byte{1}) {
+		c.feature.Change(FeatureSendAckVector, false, false)
+	}
+	// CCID 3 receivers use NDP Counts to tell lost data packets from lost non-data packets
+	if c.socket.GetCCIDB() == CCID3 && c.feature.SetPrefs(FeatureSendNDPCount, false, []byte{1}) {
+		c.feature.Change(FeatureSendNDPCount, false, false)
+	}
+	return ok
+}
+
+// syncCCID replaces the sender and receiver congestion controls, if they do not implement the
+// CCIDs in effect. Until the CCIDs are negotiated, the most preferred congestion control is
+// used in place of an unsupported CCID. syncCCID returns false if a CCID is not supported.
+func (c *Conn) syncCCID() bool {
+	c.AssertLocked()
+	ok := true
+	if id := c.socket.GetCCIDA(); c.scc == nil || c.scc.GetID() != id {
+		ccid := c.findCCID(id)
+		if ccid == nil {
+			ok = false
+		}
+		if ccid != nil || c.scc == nil {
+			if ccid == nil {
+				ccid = c.ccids[0]
+			}
+			if c.scc != nil && c.ccidOpen {
+				c.scc.Close()
+			}
+			c.scc = ccid.NewSender(c.env, c.amb, c.config.CCIDArgs...)
+			if c.config.Heartbeat > 0 {
+				c.scc.SetHeartbeat(c.config.Heartbeat)
+			}
+			if c.ccidOpen {
+				c.scc.Open()
+				c.reportPMTU()
+			}
+		}
+	}
+	if id := c.socket.GetCCIDB(); c.rcc == nil || c.rcc.GetID() != id {
+		ccid := c.findCCID(id)
+		if ccid == nil {
+			ok = false
+		}
+		if ccid != nil || c.rcc == nil {
+			if ccid == nil {
+				ccid = c.ccids[0]
+			}
+			if c.rcc != nil && c.ccidOpen {
+				c.rcc.Close()
+			}
+			c.rcc = ccid.NewReceiver(c.env, c.amb, c.config.CCIDArgs...)
+			if c.ccidOpen {
+				c.rcc.Open()
+			}
+		}
+	}
+	return ok
+}
+
+// setServiceCCIDs makes a server in LISTEN support the congestion controls ccids, which serve
+// the Service Code requested by the client. It returns false if ccids is empty, i.e. if the
+// Service Code is not accepted.
+func (c *Conn) setServiceCCIDs(ccids []CCID) bool {
+	c.AssertLocked()
+	if len(ccids) == 0 {
+		return false
+	}
+	c.ccids = ccids
+	initCCIDPrefs(&c.feature, ccids)
+	// The congestion controls have not been opened yet, and are replaced by those of the service
+	c.scc, c.rcc = nil, nil
+	c.syncCCID()
+	c.syncWithCongestionControl()
+	return true
+}
+
+// findCCID returns the supported congestion control with the given CCID, or nil otherwise
+func (c *Conn) findCCID(id byte) CCID {
+	for _, ccid := range c.ccids {
+		if ccid.GetID() == id {
+			return ccid
+		}
+	}
+	return nil
 }
 
 func (c *Conn) syncWithLink() {
 	c.AssertLocked()
-	c.socket.SetPMTU(int32(c.hc.GetMTU()))
+	c.pmtu.SetLinkMTU(int32(c.hc.GetMTU()))
+	c.syncPMTU()
+	// An endpoint that cannot read ECN codepoints declares itself ECN Incapable, Section 12.1
+	if !c.isLinkECNCapable() && c.feature.SetPrefs(FeatureECNIncapable, true, []byte{1}) {
+		c.feature.Change(FeatureECNIncapable, true, false)
+	}
+}
+
+// syncPMTU applies the Path MTU found by discovery to the socket, and reports its changes to
+// the sender congestion control
+func (c *Conn) syncPMTU() {
+	c.AssertLocked()
+	pmtu := c.pmtu.PMTU()
+	if pmtu == c.socket.GetPMTU() {
+		return
+	}
+	c.socket.SetPMTU(pmtu)
+	c.amb.E(EventInfo, fmt.Sprintf("PMTU=%d", pmtu))
+	c.reportPMTU()
+}
+
+// reportPMTU tells the sender congestion control the current Path MTU, if it wants to know it
+func (c *Conn) reportPMTU() {
+	c.AssertLocked()
+	if ps, ok := c.scc.(PMTUSender); ok && c.socket.GetPMTU() > 0 {
+		ps.SetPMTU(c.socket.GetPMTU(), dataHeaderOverhead())
+	}
+}
+
+// pollPMTU sends a Path MTU probe, if one is due, and applies the outcome of lost probes.
+// Probes wait while the queue of non-Data packets is backing up, so that they do not crowd
+// out acknowledgements, which may be all that a slow sender can send. Probes also wait while
+// the connection is idle or the sending rate is too low to afford them.
+func (c *Conn) pollPMTU() {
+	c.AssertLocked()
+	now := c.env.Now()
+	if c.isWriteNonDataBackingUp() || now-c.dataTime >= PMTUIdleTimeout {
+		return
+	}
+	if pi, ok := c.scc.(PacketIntervalSender); ok && pi.GetPacketInterval() > PMTUProbeMaxInterval {
+		return
+	}
+	size := c.pmtu.Poll(now, c.socket.GetRTT())
+	c.syncPMTU()
+	if size > 0 {
+		c.inject(c.generatePMTUProbe(size))
+	}
+}
+
+// isLinkECNCapable returns true if the HeaderConn carries ECN codepoints
+func (c *Conn) isLinkECNCapable() bool {
+	ec, ok := c.hc.(ECNHeaderConn)
+	return ok && ec.IsECNCapable()
 }
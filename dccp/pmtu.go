@@ -0,0 +1,161 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// Packetization Layer Path MTU Discovery, RFC 4821 and Section 14 of RFC 4340.
+//
+// The PMTU is searched between PMTUBase, which is assumed to be delivered by any path, and
+// the MTU of the link. Sizes are probed with DCCP-Sync packets, which are padded with data
+// that the other side ignores, Section 14.1. Probes do not risk the loss of application data.
+// A probe is delivered if the DCCP-SyncAck acknowledging its sequence number arrives. Once
+// the search has converged, the PMTU is validated periodically, so that a path, which stops
+// delivering packets of the current size, is detected and searched again.
+type pmtuSearch struct {
+	link         int32 // MTU of the link, the upper bound of the search
+	low          int32 // Largest size known to be delivered. This is the PMTU.
+	high         int32 // Largest size that is not known to fail
+	raiseTime    int64 // Time when high is raised back to link, or zero if high equals link
+
+	probe        int32 // Size of the outstanding probe, or zero if there is none
+	probeSeqNo   int64 // Sequence number of the outstanding probe, or -1 if it has not been sent yet
+	probeTime    int64 // Time when the outstanding probe was sent
+	lost         int   // Number of consecutive lost probes of size lostSize
+	lostSize     int32
+	validateTime int64 // Time when the PMTU is validated next
+}
+
+const (
+	// PMTUBase is the initial PMTU and the smallest size probed, RFC 4821 Section 7.2
+	PMTUBase = 1024
+
+	// PMTUPrecision is the width of the size range, below which the search stops
+	PMTUPrecision = 32
+
+	// PMTUMaxProbes is the number of consecutive lost probes, after which a size is deemed
+	// not to be delivered, RFC 4821 Section 7.3
+	PMTUMaxProbes = 3
+
+	// PMTUProbeTimeoutMin is the smallest time in ns, after which an unacknowledged probe is
+	// considered lost. The timeout is otherwise three round-trip times. It leaves room for
+	// the SyncAck to wait for a slow sending rate at the other side.
+	PMTUProbeTimeoutMin = 1e9
+
+	// PMTUValidateInterval is the interval in ns between probes that validate the PMTU
+	PMTUValidateInterval = 5e9
+
+	// PMTUIdleTimeout is the time in ns without data sent or received, after which the
+	// connection is idle. Idle connections send no probes.
+	PMTUIdleTimeout = 1e9
+
+	// PMTUProbeMaxInterval is the longest average time in ns between the packets allowed by
+	// the sender congestion control, at which probes are sent. Slower senders would spend a
+	// significant share of their packets on probes, and a probe could wait for its turn as
+	// long as it takes to declare it lost.
+	PMTUProbeMaxInterval = PMTUProbeTimeoutMin / 10
+
+	// PMTURaiseInterval is the time in ns, after which sizes that have failed are probed
+	// again, RFC 4821 Section 7.7
+	PMTURaiseInterval = 600e9
+)
+
+// Init resets the search for new use
+func (s *pmtuSearch) Init() {
+	*s = pmtuSearch{}
+}
+
+// SetLinkMTU sets the MTU of the link. The PMTU and the search range are clamped to it.
+func (s *pmtuSearch) SetLinkMTU(mtu int32) {
+	if mtu == s.link {
+		return
+	}
+	if s.link == 0 {
+		s.low = min32(PMTUBase, mtu)
+	}
+	if mtu > s.link && s.high == s.link {
+		s.high = mtu
+	}
+	s.link = mtu
+	s.low = min32(s.low, mtu)
+	s.high = max32(s.low, min32(s.high, mtu))
+}
+
+// PMTU returns the current Path MTU
+func (s *pmtuSearch) PMTU() int32 {
+	return s.low
+}
+
+// Poll returns the size of the probe that should be sent at time now, or zero if no probe
+// is due. Lost probes are accounted for, given that the round-trip time is rtt. Poll may
+// lower the PMTU, if the PMTU itself is no longer delivered.
+func (s *pmtuSearch) Poll(now, rtt int64) int32 {
+	if s.probe > 0 {
+		if now < s.probeTime+max64(3*rtt, PMTUProbeTimeoutMin) {
+			return 0
+		}
+		if s.probeSeqNo < 0 {
+			// The probe was never written, e.g. it was dropped by a slow strobe. It is not lost.
+			s.probe = 0
+		} else {
+			s.onLost(now)
+		}
+	}
+	if s.raiseTime > 0 && now >= s.raiseTime {
+		s.high, s.raiseTime = s.link, 0
+	}
+	var size int32
+	if s.high-s.low >= PMTUPrecision {
+		size = (s.low + s.high + 1) / 2
+	} else if s.low > PMTUBase && now >= s.validateTime {
+		size = s.low
+	} else {
+		return 0
+	}
+	if size != s.lostSize {
+		s.lost, s.lostSize = 0, size
+	}
+	s.probe, s.probeSeqNo, s.probeTime = size, -1, now
+	return size
+}
+
+// onLost accounts for the loss of the outstanding probe
+func (s *pmtuSearch) onLost(now int64) {
+	size := s.probe
+	s.probe = 0
+	s.lost++
+	if s.lost < PMTUMaxProbes {
+		return
+	}
+	s.lost = 0
+	if size <= s.low {
+		// Black hole: the PMTU is no longer delivered. The search starts over from the base.
+		s.low = min32(PMTUBase, s.link)
+	}
+	s.high = max32(s.low, size-1)
+	if s.raiseTime == 0 {
+		s.raiseTime = now + PMTURaiseInterval
+	}
+}
+
+// OnWrite records that a probe of the given size is sent with sequence number seqNo at time
+// now. Probes other than the outstanding one are ignored.
+func (s *pmtuSearch) OnWrite(size int32, seqNo, now int64) {
+	if size != s.probe || s.probeSeqNo >= 0 {
+		return
+	}
+	s.probeSeqNo, s.probeTime = seqNo, now
+}
+
+// OnSyncAck processes a DCCP-SyncAck with acknowledgement number ackNo, received at time now.
+// It returns true if the packet acknowledges the outstanding probe.
+func (s *pmtuSearch) OnSyncAck(ackNo, now int64) bool {
+	if s.probe == 0 || s.probeSeqNo < 0 || ackNo != s.probeSeqNo {
+		return false
+	}
+	s.low = max32(s.low, s.probe)
+	s.high = max32(s.high, s.low)
+	s.probe, s.lost = 0, 0
+	s.validateTime = now + PMTUValidateInterval
+	return true
+}
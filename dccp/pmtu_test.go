@@ -0,0 +1,82 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+// pmtuPath runs the search s against a path that delivers packets of up to limit bytes,
+// starting at time now. It returns the time when no more probes are due.
+func pmtuPath(s *pmtuSearch, limit int32, now int64) int64 {
+	var seqNo int64
+	for i := 0; i < 100; i++ {
+		size := s.Poll(now, RoundtripMin)
+		if size == 0 {
+			if s.probe == 0 {
+				return now
+			}
+			now += PMTUProbeTimeoutMin
+			continue
+		}
+		seqNo++
+		s.OnWrite(size, seqNo, now)
+		if size <= limit {
+			s.OnSyncAck(seqNo, now+RoundtripMin)
+		}
+		now += RoundtripMin
+	}
+	panic("pmtu search does not converge")
+}
+
+func TestPMTUSearch(t *testing.T) {
+	var s pmtuSearch
+	s.Init()
+	s.SetLinkMTU(1500)
+	if s.PMTU() != PMTUBase {
+		t.Fatalf("expecting initial pmtu %d, got %d", PMTUBase, s.PMTU())
+	}
+	now := pmtuPath(&s, 1300, 0)
+	if p := s.PMTU(); p > 1300 || p <= 1300-PMTUPrecision {
+		t.Errorf("expecting pmtu close to 1300, got %d", p)
+	}
+
+	// The path stops delivering packets of the current size
+	now = pmtuPath(&s, 1100, now+PMTUValidateInterval)
+	if p := s.PMTU(); p > 1100 || p <= 1100-PMTUPrecision {
+		t.Errorf("expecting pmtu close to 1100, got %d", p)
+	}
+
+	// Larger sizes are probed again after a while
+	pmtuPath(&s, 1500, now+PMTURaiseInterval)
+	if p := s.PMTU(); p <= 1500-PMTUPrecision {
+		t.Errorf("expecting pmtu close to 1500, got %d", p)
+	}
+}
+
+func TestPMTUSmallLink(t *testing.T) {
+	var s pmtuSearch
+	s.Init()
+	s.SetLinkMTU(500)
+	if s.PMTU() != 500 || s.Poll(0, RoundtripMin) != 0 {
+		t.Errorf("expecting pmtu of the link without probes")
+	}
+}
+
+func TestPMTUUnwrittenProbe(t *testing.T) {
+	var s pmtuSearch
+	s.Init()
+	s.SetLinkMTU(1500)
+	var now int64
+	for i := 0; i < 2*PMTUMaxProbes; i++ {
+		if s.Poll(now, RoundtripMin) == 0 {
+			t.Fatalf("expecting a probe")
+		}
+		now += PMTUProbeTimeoutMin
+	}
+	if s.high != 1500 {
+		t.Errorf("probes that were never written are counted as lost")
+	}
+}
@@ -82,3 +82,36 @@ func diff(t *testing.T, prefix string, have, want interface{}) {
 		}
 	}
 }
+
+func TestReadWriteShortSeqNo(t *testing.T) {
+	gh := &Header{
+		SourcePort: 33,
+		DestPort:   77,
+		Type:       DataAck,
+		X:          false,
+		SeqNo:      0x0000334455667788,
+		AckNo:      0x0000112233445566,
+		Data:       []byte{1, 2, 3},
+	}
+	if _, err := gh.Write([]byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}, 34, false); err != ErrSemantic {
+		t.Errorf("short seqnos written without the Allow Short Seqnos feature")
+	}
+	hd, err := gh.Write([]byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}, 34, true)
+	if err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	// Generic header and Acknowledgement Number subheader with short sequence numbers
+	if len(hd) != 12+4+len(gh.Data) {
+		t.Errorf("short header has length %d", len(hd)-len(gh.Data))
+	}
+	gh2, err := ReadHeader(hd, []byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}, 34, true)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	if gh2.X || gh2.SeqNo != gh.SeqNo&0xffffff || gh2.AckNo != gh.AckNo&0xffffff {
+		t.Errorf("read X=%v SeqNo=%x AckNo=%x", gh2.X, gh2.SeqNo, gh2.AckNo)
+	}
+	if !bytes.Equal(gh2.Data, gh.Data) {
+		t.Errorf("read data %v, want %v", gh2.Data, gh.Data)
+	}
+}
@@ -23,20 +23,18 @@ func (t *reorderBuffer) Init(size int) {
 
 func (t *reorderBuffer) PushPop(h *Header) *Header {
 	// XXX: Must guarantee strictly ascending order
-	var popSeqNo int64 = SEQNOMAX + 1
+	var pop *Header
 	var popIndex int
 	for i, g := range t.headers {
 		if g == nil {
 			t.headers[i] = h
 			return nil
 		}
-		// XXX: This must employ circular comparison
-		if g.SeqNo < popSeqNo {
+		if pop == nil || SeqLess(g.SeqNo, pop.SeqNo) {
 			popIndex = i
-			popSeqNo = g.SeqNo
+			pop = g
 		}
 	}
-	r := t.headers[popIndex]
 	t.headers[popIndex] = h
-	return r
+	return pop
 }
@@ -0,0 +1,70 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+const (
+	ccid2Duration         = 5e9 // Duration of the CCID 2 transfer test, in ns
+	ccid2PacketsPerSecond = 100 // Transmission rate of the client-to-server line
+)
+
+// TestCCID2 checks that a CCID 2 connection establishes, carries a bulk transfer at a good
+// fraction of the line rate, and closes.
+func TestCCID2(t *testing.T) {
+
+	env, _ := NewEnv("ccid2")
+	clientConn, serverConn, clientToServer, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+	clientToServer.SetWriteRate(1e9, ccid2PacketsPerSecond)
+
+	buf := make([]byte, 100)
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		t0 := env.Now()
+		for env.Now() - t0 < ccid2Duration {
+			if err := clientConn.Write(buf); err != nil {
+				t.Errorf("error writing (%s)", err)
+				break
+			}
+		}
+		clientConn.Close()
+		close(cchan)
+	}, "test client")
+
+	var nread int
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			_, err := serverConn.Read()
+			if err == dccp.ErrEOF {
+				break
+			} else if err != nil {
+				t.Errorf("error reading (%s)", err)
+				break
+			}
+			nread++
+		}
+		close(schan)
+	}, "test server")
+
+	_, _ = <-cchan
+	_, _ = <-schan
+
+	if min := int(ccid2Duration / 1e9 * ccid2PacketsPerSecond / 2); nread < min {
+		t.Errorf("server received %d packets, expected at least %d", nread, min)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
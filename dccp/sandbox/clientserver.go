@@ -24,15 +24,45 @@ func NewEnv(guzzleFilename string, guzzles ...dccp.TraceWriter) (env *dccp.Env,
 // server to its endpoints. In addition to sending all emits to a standard DCCP log file, it sends a
 // copy of all emits to the dup TraceWriter.
 func NewClientServerPipe(env *dccp.Env) (clientConn, serverConn *dccp.Conn, clientToServer, serverToClient *headerHalfPipe) {
+	return NewClientServerPipeCCID(env, ccid3.CCID3{})
+}
+
+// NewClientServerPipeCCID is like NewClientServerPipe, except that both endpoints use the
+// congestion control ccid.
+func NewClientServerPipeCCID(env *dccp.Env, ccid dccp.CCID) (clientConn, serverConn *dccp.Conn, clientToServer, serverToClient *headerHalfPipe) {
+	return NewClientServerPipeCCIDs(env, []dccp.CCID{ccid}, []dccp.CCID{ccid})
+}
+
+// NewClientServerPipeCCIDs is like NewClientServerPipe, except that the client and the server
+// support the congestion controls clientCCIDs and serverCCIDs, respectively.
+func NewClientServerPipeCCIDs(env *dccp.Env, clientCCIDs, serverCCIDs []dccp.CCID) (clientConn, serverConn *dccp.Conn, clientToServer, serverToClient *headerHalfPipe) {
 	llog := dccp.NewAmb("line", env)
 	hca, hcb, _ := NewPipe(env, llog, "client", "server")
-	ccid := ccid3.CCID3{}
 
 	clog := dccp.NewAmb("client", env)
-	clientConn = dccp.NewConnClient(env, clog, hca, ccid.NewSender(env, clog), ccid.NewReceiver(env, clog), 0)
+	clientConn = dccp.NewConnClient(env, clog, hca, clientCCIDs, 0)
 
 	slog := dccp.NewAmb("server", env)
-	serverConn = dccp.NewConnServer(env, slog, hcb, ccid.NewSender(env, slog), ccid.NewReceiver(env, slog))
+	serverConn = dccp.NewConnServer(env, slog, hcb, serverCCIDs)
 
 	return clientConn, serverConn, hca, hcb
 }
+
+// NewCCIDArgs returns a congestion control, which is like ccid, except that it passes args
+// to the sender and receiver it creates, as the CCIDArgs of a dccp.Config would
+func NewCCIDArgs(ccid dccp.CCID, args ...interface{}) dccp.CCID {
+	return ccidArgs{ ccid, args }
+}
+
+type ccidArgs struct {
+	dccp.CCID
+	args []interface{}
+}
+
+func (x ccidArgs) NewSender(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.SenderCongestionControl {
+	return x.CCID.NewSender(env, amb, append(append([]interface{}{}, x.args...), args...)...)
+}
+
+func (x ccidArgs) NewReceiver(env *dccp.Env, amb *dccp.Amb, args ...interface{}) dccp.ReceiverCongestionControl {
+	return x.CCID.NewReceiver(env, amb, append(append([]interface{}{}, x.args...), args...)...)
+}
@@ -0,0 +1,93 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+// TestCloseReq verifies that a server closing with CloseReq leaves TIMEWAIT state to the client
+func TestCloseReq(t *testing.T) {
+	states := newStateTracker()
+	env, _ := NewEnv("closereq", states)
+	clientConn, serverConn, _, _ := NewClientServerPipe(env)
+
+	env.Sleep(1e9)
+	if err := serverConn.CloseReq(); err != nil {
+		t.Errorf("server close error (%s)", err)
+	}
+	if _, err := clientConn.Read(); err != dccp.ErrEOF {
+		t.Errorf("client read error (%s), expected EOF", err)
+	}
+	env.Sleep(2e9)
+	if err := serverConn.Error(); err != dccp.ErrEOF {
+		t.Errorf("server error (%s), expected EOF", err)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+
+	if !states.Entered("server", "CLOSEREQ") || states.Entered("server", "TIMEWAIT") {
+		t.Errorf("server went through states %v", states.States("server"))
+	}
+	if !states.Entered("client", "TIMEWAIT") {
+		t.Errorf("client went through states %v", states.States("client"))
+	}
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("Error closing runtime (%s)", err)
+	}
+}
+
+// stateTracker is a TraceWriter that records the states entered by each endpoint
+type stateTracker struct {
+	sync.Mutex
+	states map[string][]string
+}
+
+func newStateTracker() *stateTracker {
+	return &stateTracker{states: make(map[string][]string)}
+}
+
+func (x *stateTracker) Write(r *dccp.Trace) {
+	if len(r.Labels) == 0 || r.State == "" {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	endpoint := r.Labels[0]
+	ss := x.states[endpoint]
+	if len(ss) == 0 || ss[len(ss)-1] != r.State {
+		x.states[endpoint] = append(ss, r.State)
+	}
+}
+
+// States returns the states entered by endpoint, in order
+func (x *stateTracker) States(endpoint string) []string {
+	x.Lock()
+	defer x.Unlock()
+	return x.states[endpoint]
+}
+
+// Entered returns true if endpoint has entered state
+func (x *stateTracker) Entered(endpoint, state string) bool {
+	for _, s := range x.States(endpoint) {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *stateTracker) Sync() error {
+	return nil
+}
+
+func (x *stateTracker) Close() error {
+	return nil
+}
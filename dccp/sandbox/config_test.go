@@ -0,0 +1,114 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestConfig checks that the connections of a Stack are traced, send heartbeats and time out
+// their handshakes as configured at Stack level and for individual Dials
+func TestConfig(t *testing.T) {
+	acks := &readCounter{endpoint: "server", typ: "Ack"}
+	alink, dlink := dccp.NewChanPipe()
+	astack := dccp.NewStack(alink, ccid2.CCID2{})
+	astack.SetConfig(&dccp.Config{TraceWriter: acks})
+	l, err := astack.Listen(7)
+	if err != nil {
+		t.Fatalf("listen (%s)", err)
+	}
+	dstack := dccp.NewStack(dlink, ccid2.CCID2{})
+	dstack.SetConfig(&dccp.Config{Heartbeat: 1e8})
+
+	// The client sends heartbeats while idle, which are traced by the server
+	client, err := dstack.DialConfig(nil, 7, &dccp.Config{ReadBuffer: 1})
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	if err := client.Write([]byte{1}); err != nil {
+		t.Fatalf("write (%s)", err)
+	}
+	server, err := l.(*dccp.Listener).AcceptDCCP()
+	if err != nil {
+		t.Fatalf("accept (%s)", err)
+	}
+	server.SetReadExpire(5e9)
+	if _, err := server.Read(); err != nil {
+		t.Fatalf("read (%s)", err)
+	}
+	n0 := acks.Count()
+	time.Sleep(1e9)
+	if n := acks.Count() - n0; n < 5 {
+		t.Errorf("server read %d acks from an idle client", n)
+	}
+	client.Abort()
+	server.Abort()
+
+	// A Request, which is never answered, times out as configured for the Dial
+	blink, clink := dccp.NewChanPipe()
+	go func() {
+		m := dccp.NewMux(blink)
+		for {
+			f, err := m.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for {
+					if _, err := f.Read(); err != nil && err != dccp.ErrTimeout {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	c, err := dccp.NewStack(clink, ccid2.CCID2{}).DialConfig(nil, 7, &dccp.Config{RequestTimeout: 5e8})
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	c.SetReadExpire(10e9)
+	t0 := time.Now()
+	if _, err := c.Read(); err != dccp.ErrAbort {
+		t.Errorf("unanswered request returned (%v)", err)
+	}
+	if d := time.Since(t0); d > 3*time.Second {
+		t.Errorf("unanswered request timed out after %s", d)
+	}
+}
+
+// readCounter is a TraceWriter that counts the packets of a given type, read by an endpoint
+type readCounter struct {
+	sync.Mutex
+	endpoint, typ string
+	count         int
+}
+
+func (x *readCounter) Write(r *dccp.Trace) {
+	if r.Event != dccp.EventRead || len(r.Labels) == 0 || r.Labels[0] != x.endpoint || r.Type != x.typ {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	x.count++
+}
+
+// Count returns the number of matching traces so far
+func (x *readCounter) Count() int {
+	x.Lock()
+	defer x.Unlock()
+	return x.count
+}
+
+func (x *readCounter) Sync() error {
+	return nil
+}
+
+func (x *readCounter) Close() error {
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"net"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+// TestInitCookie checks that a server, which answers the handshake statelessly, creates the
+// connection once the client echoes the Init Cookie, and that data flows afterwards.
+func TestInitCookie(t *testing.T) {
+
+	env, _ := NewEnv("initcookie")
+	llog := dccp.NewAmb("line", env)
+	hca, hcb, _ := NewPipe(env, llog, "client", "server")
+	ccids := []dccp.CCID{ccid3.CCID3{}}
+
+	connchan := make(chan *dccp.Conn, 1)
+	env.Go(func() {
+		serverConn, err := dccp.AcceptStateless(env, dccp.NewAmb("server", env), hcb, ccids, dccp.NewCookieJar(nil))
+		if err != nil {
+			t.Errorf("stateless accept (%s)", err)
+		}
+		connchan <- serverConn
+	}, "test accept")
+	clientConn := dccp.NewConnClient(env, dccp.NewAmb("client", env), hca, ccids, 0)
+
+	const npackets = 10
+	payload := []byte{1, 2, 3}
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < npackets; i++ {
+			if err := clientConn.Write(payload); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+			env.Sleep(1e8)
+		}
+		close(cchan)
+	}, "test client")
+
+	serverConn := <-connchan
+	if serverConn == nil {
+		t.Fatalf("no server connection")
+	}
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < npackets; i++ {
+			if _, err := serverConn.Read(); err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	if a, b := serverConn.GetCCID(); a != dccp.CCID3 || b != dccp.CCID3 {
+		t.Errorf("server CCIDs %d/%d, expected %d/%d", a, b, dccp.CCID3, dccp.CCID3)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
+
+// TestStackCookie checks that Stacks, which answer handshakes statelessly, route the connections
+// of completed handshakes to their services, over both flows and DCCP-UDP
+func TestStackCookie(t *testing.T) {
+	for _, encap := range []bool{false, true} {
+		alink, dlink := dccp.NewChanPipe()
+		newStack := dccp.NewStack
+		var addr net.Addr
+		if encap {
+			newStack = dccp.NewEncapStack
+			addr = &dccp.EncapAddr{Port: 5001}
+		}
+		astack, dstack := newStack(alink, ccid2.CCID2{}), newStack(dlink, ccid2.CCID2{})
+		astack.SetCookieJar(dccp.NewCookieJar(nil))
+		l, err := astack.Listen(7)
+		if err != nil {
+			t.Fatalf("listen (%s)", err)
+		}
+
+		// A Request for a Service Code that is not registered is rejected
+		c, err := dstack.Dial(addr, 8)
+		if err != nil {
+			t.Fatalf("dial (%s)", err)
+		}
+		if err := c.Write([]byte{1}); err == nil {
+			t.Errorf("write to unregistered service succeeded")
+		}
+
+		client, err := dstack.DialConfig(addr, 7, nil)
+		if err != nil {
+			t.Fatalf("dial (%s)", err)
+		}
+		if err := client.Write([]byte{2}); err != nil {
+			t.Fatalf("write (%s)", err)
+		}
+		server, err := l.(*dccp.Listener).AcceptDCCP()
+		if err != nil {
+			t.Fatalf("accept (%s)", err)
+		}
+		server.SetReadExpire(5e9)
+		if data, err := server.Read(); err != nil || len(data) != 1 || data[0] != 2 {
+			t.Errorf("encap=%v: server read %v (%v)", encap, data, err)
+		}
+		client.Abort()
+		server.Abort()
+		l.Close()
+	}
+}
+
+// TestCookieClose checks that a connection, whose handshake was answered statelessly, can be
+// closed as soon as it is accepted, while it is still in RESPOND
+func TestCookieClose(t *testing.T) {
+	alink, dlink := dccp.NewChanPipe()
+	astack, dstack := dccp.NewStack(alink, ccid2.CCID2{}), dccp.NewStack(dlink, ccid2.CCID2{})
+	astack.SetCookieJar(dccp.NewCookieJar(nil))
+
+	client, err := dstack.Dial(nil, 7)
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	server, err := astack.Accept()
+	if err != nil {
+		t.Fatalf("accept (%s)", err)
+	}
+	if err := server.Close(); err != nil {
+		t.Errorf("close (%s)", err)
+	}
+	// The client learns that the connection was closed
+	client.SetReadExpire(5e9)
+	if _, err := client.Read(); err == nil || err == dccp.ErrTimeout {
+		t.Errorf("client read after server close (%v)", err)
+	}
+	client.Close()
+}
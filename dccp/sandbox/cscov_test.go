@@ -0,0 +1,85 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestCsCov checks that a client sends partial checksum coverage once the server has announced
+// its Minimum Checksum Coverage, that the coverage is raised to that minimum, and that the
+// server's reader is told which data was only partially covered.
+func TestCsCov(t *testing.T) {
+
+	env, _ := NewEnv("cscov")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+	if err := serverConn.SetMinCsCov(dccp.CsCov8); err != nil {
+		t.Fatalf("server min cscov (%s)", err)
+	}
+	if err := clientConn.SetCsCov(dccp.CsCov4); err != nil {
+		t.Fatalf("client cscov (%s)", err)
+	}
+
+	const n = 20
+	payload := make([]byte, 100)
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < n; i++ {
+			var err error
+			if i < n-1 {
+				err = clientConn.Write(payload)
+			} else {
+				err = clientConn.WriteCsCov(payload, dccp.CsCovAllData)
+			}
+			if err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+			env.Sleep(1e8)
+		}
+		close(cchan)
+	}, "test client")
+
+	var npartial int
+	var lastPartial bool
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < n; i++ {
+			_, partial, err := serverConn.ReadPartial()
+			if err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+			if partial {
+				npartial++
+			}
+			lastPartial = partial
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	if _, remote := clientConn.GetMinCsCov(); remote != dccp.CsCov8 {
+		t.Errorf("client sees minimum coverage %d, expected %d", remote, dccp.CsCov8)
+	}
+	if npartial == 0 {
+		t.Errorf("no partially covered data received")
+	}
+	if lastPartial {
+		t.Errorf("data written with full coverage received as partial")
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
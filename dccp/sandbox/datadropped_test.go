@@ -0,0 +1,47 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestDataDropped checks that data, which the server application is too slow to read, is
+// reported back to the client as dropped in the receive buffer.
+func TestDataDropped(t *testing.T) {
+
+	env, _ := NewEnv("datadropped")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+
+	// The server application does not read, so its receive buffer overflows
+	const npackets = 20
+	buf := make([]byte, 100)
+	for i := 0; i < npackets; i++ {
+		if err := clientConn.Write(buf); err != nil {
+			t.Fatalf("error writing (%s)", err)
+		}
+	}
+	env.Sleep(1e9)
+
+	counts := clientConn.GetDataDropped()
+	if counts[dccp.DropReceiveBuffer] == 0 || counts[dccp.DropReceiveBuffer] >= npackets {
+		t.Errorf("client learned of %d receive buffer drops", counts[dccp.DropReceiveBuffer])
+	}
+	for code, n := range counts {
+		if code != dccp.DropReceiveBuffer && n != 0 {
+			t.Errorf("client learned of %d drops with code %d", n, code)
+		}
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+const (
+	dataLimitedLatency  = 250e6 // Latency of the path in each direction, in ns
+	dataLimitedRate     = 50    // Transmission rate of the path in pps
+	dataLimitedBusy     = 8e9   // Duration of the initial period, when the client writes as fast as it can, in ns
+	dataLimitedQuiet    = 4e9   // Duration of the quiet periods, in ns
+	dataLimitedInterval = 100e6 // Interval between writes in quiet periods, in ns
+	dataLimitedBursts   = 3     // Number of bursts, each following a quiet period
+	dataLimitedBurst    = 20    // Number of packets in a burst
+	dataLimitedMaxBurst = 500e6 // Longest time it should take to send a burst, in ns
+)
+
+// TestDataLimited checks that the allowed sending rate of an application, which sends bursts
+// of data after quiet periods, does not collapse to the low receive rate reported during the
+// quiet periods. The first burst is not checked, since the sender may not have reached a rate
+// well above the quiet rate by the time it is sent.
+func TestDataLimited(t *testing.T) {
+
+	env, _ := NewEnv("datalimited")
+	clientConn, serverConn, clientToServer, serverToClient := NewClientServerPipe(env)
+	clientToServer.SetWriteLatency(dataLimitedLatency)
+	serverToClient.SetWriteLatency(dataLimitedLatency)
+	clientToServer.SetWriteRate(1e9, dataLimitedRate)
+
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		defer close(cchan)
+		defer clientConn.Close()
+		buf := make([]byte, 200)
+		t0 := env.Now()
+		for env.Now()-t0 < dataLimitedBusy {
+			if err := clientConn.Write(buf); err != nil {
+				t.Errorf("client write (%s)", err)
+				return
+			}
+		}
+		for i := 0; i < dataLimitedBursts; i++ {
+			t0 = env.Now()
+			for env.Now()-t0 < dataLimitedQuiet {
+				if err := clientConn.Write(buf); err != nil {
+					t.Errorf("client write (%s)", err)
+					return
+				}
+				env.Sleep(dataLimitedInterval)
+			}
+			t0 = env.Now()
+			for j := 0; j < dataLimitedBurst; j++ {
+				if err := clientConn.Write(buf); err != nil {
+					t.Errorf("client write (%s)", err)
+					return
+				}
+			}
+			if d := env.Now() - t0; i > 0 && d > dataLimitedMaxBurst {
+				t.Errorf("burst %d took %s ns to send", i, dccp.Nstoa(d))
+			}
+		}
+	}, "test client")
+
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
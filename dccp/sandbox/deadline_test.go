@@ -0,0 +1,71 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestDeadline checks that expired and cancelled calls to Read and Write return ErrTimeout,
+// and that the connection remains usable afterwards.
+func TestDeadline(t *testing.T) {
+
+	env, _ := NewEnv("deadline")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+
+	// A read with no data in sight expires
+	serverConn.SetReadExpire(2e8)
+	t0 := env.Now()
+	if _, err := serverConn.Read(); err != dccp.ErrTimeout {
+		t.Errorf("expired read returned (%v)", err)
+	}
+	if d := env.Now() - t0; d < 2e8 || d > 1e9 {
+		t.Errorf("read expired after %d ns", d)
+	}
+
+	// A blocked read is cancelled by a deadline in the past
+	serverConn.SetReadExpire(0)
+	rchan := make(chan error, 1)
+	env.Go(func() {
+		_, err := serverConn.Read()
+		rchan <- err
+	}, "test reader")
+	env.Sleep(2e8)
+	serverConn.SetReadDeadline(env.Now() - 1)
+	if err := <-rchan; err != dccp.ErrTimeout {
+		t.Errorf("cancelled read returned (%v)", err)
+	}
+
+	// A write past its deadline is not sent
+	clientConn.SetWriteDeadline(env.Now() - 1)
+	if err := clientConn.Write([]byte{1}); err != dccp.ErrTimeout {
+		t.Errorf("expired write returned (%v)", err)
+	}
+
+	// Data flows once the deadlines are removed
+	serverConn.SetReadDeadline(0)
+	clientConn.SetWriteDeadline(0)
+	if err := clientConn.Write([]byte{2}); err != nil {
+		t.Fatalf("client write (%s)", err)
+	}
+	if data, err := serverConn.Read(); err != nil || len(data) != 1 || data[0] != 2 {
+		t.Errorf("server read %v (%v)", data, err)
+	}
+	for _, conn := range []*dccp.Conn{clientConn, serverConn} {
+		if err := conn.Error(); err != nil {
+			t.Errorf("connection error (%s)", err)
+		}
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestECN checks that a sender reacts to the ECN marks of a congested pipe, and that the
+// Nonce Echoes of the receiver's Ack Vectors are verified successfully.
+func TestECN(t *testing.T) {
+
+	events := newCommentCounter("client", "Congestion event, marked")
+	env, _ := NewEnv("ecn", events)
+	clientConn, serverConn, clientToServer, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+	clientToServer.SetWriteRate(1e9, 20)
+	clientToServer.SetMarkECN(true)
+
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+	}, "test server")
+
+	buf := make([]byte, 100)
+	t0 := env.Now()
+	for env.Now()-t0 < 5e9 {
+		if err := clientConn.Write(buf); err != nil {
+			t.Fatalf("error writing (%s)", err)
+		}
+	}
+	if events.Count() == 0 {
+		t.Errorf("client did not react to ECN marks")
+	}
+	if err := clientConn.Error(); err != nil {
+		t.Errorf("client connection error (%s)", err)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
+
+// commentCounter is a TraceWriter that counts the traces of an endpoint, whose comments begin
+// with a given prefix
+type commentCounter struct {
+	sync.Mutex
+	endpoint, prefix string
+	count            int
+}
+
+func newCommentCounter(endpoint, prefix string) *commentCounter {
+	return &commentCounter{endpoint: endpoint, prefix: prefix}
+}
+
+func (x *commentCounter) Write(r *dccp.Trace) {
+	if len(r.Labels) == 0 || r.Labels[0] != x.endpoint || !strings.HasPrefix(r.Comment, x.prefix) {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	x.count++
+}
+
+// Count returns the number of matching traces so far
+func (x *commentCounter) Count() int {
+	x.Lock()
+	defer x.Unlock()
+	return x.count
+}
+
+func (x *commentCounter) Sync() error {
+	return nil
+}
+
+func (x *commentCounter) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestEncap checks that Stacks exchange data over connections encapsulated in DCCP-UDP, and
+// that connections to distinct DCCP ports are kept apart
+func TestEncap(t *testing.T) {
+	alink, dlink := dccp.NewChanPipe()
+	astack := dccp.NewEncapStack(alink, ccid2.CCID2{})
+	dstack := dccp.NewEncapStack(dlink, ccid2.CCID2{})
+	if _, err := dstack.Dial(nil, 7); err != dccp.ErrInvalid {
+		t.Errorf("dial without a DCCP port (%v)", err)
+	}
+
+	accepted := make(chan *dccp.Conn, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			c, err := astack.Accept()
+			if err != nil {
+				t.Errorf("accept (%s)", err)
+				break
+			}
+			accepted <- c.(*dccp.Conn)
+		}
+	}()
+
+	var clients, servers [2]*dccp.Conn
+	for i := range clients {
+		c, err := dstack.Dial(&dccp.EncapAddr{Port: uint16(5001 + i)}, 7)
+		if err != nil {
+			t.Fatalf("dial (%s)", err)
+		}
+		clients[i] = c.(*dccp.Conn)
+		if err := clients[i].Write([]byte{byte(i)}); err != nil {
+			t.Fatalf("write (%s)", err)
+		}
+		servers[i] = <-accepted
+		servers[i].SetReadExpire(5e9)
+		data, err := servers[i].Read()
+		if err != nil || len(data) != 1 || data[0] != byte(i) {
+			t.Errorf("server %d read %v (%v)", i, data, err)
+		}
+	}
+
+	for i := range clients {
+		local, remote := clients[i].LocalAddr().String(), servers[i].RemoteAddr().String()
+		if local != remote {
+			t.Errorf("client local address %s, server remote address %s", local, remote)
+		}
+		if addr := servers[i].LocalAddr().String(); addr != (&dccp.EncapAddr{Port: uint16(5001 + i)}).String() {
+			t.Errorf("server local address %s", addr)
+		}
+		clients[i].Abort()
+		servers[i].Abort()
+	}
+}
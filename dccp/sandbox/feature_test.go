@@ -0,0 +1,169 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+// TestCCIDNegotiation checks that a client offering CCIDs 3 and 2 agrees with a server that
+// only supports CCID 2 to use CCID 2 in both directions, and that data flows afterwards.
+func TestCCIDNegotiation(t *testing.T) {
+
+	env, _ := NewEnv("negotiation")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCIDs(env,
+		[]dccp.CCID{ccid3.CCID3{}, ccid2.CCID2{}}, []dccp.CCID{ccid2.CCID2{}})
+
+	payload := []byte{1, 2, 3}
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < 10; i++ {
+			if err := clientConn.Write(payload); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+			env.Sleep(1e8)
+		}
+		close(cchan)
+	}, "test client")
+
+	var nread int
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for nread < 10 {
+			if _, err := serverConn.Read(); err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+			nread++
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	for _, conn := range []*dccp.Conn{clientConn, serverConn} {
+		if a, b := conn.GetCCID(); a != dccp.CCID2 || b != dccp.CCID2 {
+			t.Errorf("negotiated CCIDs %d/%d, expected %d/%d", a, b, dccp.CCID2, dccp.CCID2)
+		}
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
+
+// TestSequenceWindow checks that a Sequence Window requested by the application is negotiated
+// with the other side, and that it does not affect the window of the opposite direction.
+func TestSequenceWindow(t *testing.T) {
+
+	env, _ := NewEnv("seqwin")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+	clientConn.SetSequenceWindow(5000)
+
+	payload := []byte{1, 2, 3}
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < 10; i++ {
+			if err := clientConn.Write(payload); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+			env.Sleep(1e8)
+		}
+		close(cchan)
+	}, "test client")
+
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < 10; i++ {
+			if _, err := serverConn.Read(); err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	if local, remote := clientConn.GetSequenceWindow(); local != 5000 || remote != dccp.SEQWIN_FIXED {
+		t.Errorf("client sequence windows %d/%d, expected %d/%d", local, remote, 5000, dccp.SEQWIN_FIXED)
+	}
+	if local, remote := serverConn.GetSequenceWindow(); local != dccp.SEQWIN_FIXED || remote != 5000 {
+		t.Errorf("server sequence windows %d/%d, expected %d/%d", local, remote, dccp.SEQWIN_FIXED, 5000)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
+
+// TestShortSeqNos checks that data flows over a connection, after both endpoints agree to use
+// short sequence numbers.
+func TestShortSeqNos(t *testing.T) {
+
+	env, _ := NewEnv("shortseqno")
+	clientConn, serverConn, _, _ := NewClientServerPipe(env)
+	clientConn.SetAllowShortSeqNos(true)
+	serverConn.SetAllowShortSeqNos(true)
+
+	const npackets = 20
+	payload := []byte{1, 2, 3}
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		for i := 0; i < npackets; i++ {
+			if err := clientConn.Write(payload); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+			env.Sleep(1e8)
+		}
+		close(cchan)
+	}, "test client")
+
+	var nread int
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for nread < npackets {
+			if _, err := serverConn.Read(); err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+			nread++
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	for _, conn := range []*dccp.Conn{clientConn, serverConn} {
+		if local, remote := conn.GetAllowShortSeqNos(); !local || !remote {
+			t.Errorf("short seqnos not allowed, local=%v remote=%v", local, remote)
+		}
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+// TestLossFromIntervals checks that a sender, which computes the loss event rate from the Loss
+// Intervals option, detects loss and agrees with the Loss Event Rate reported by an honest receiver
+func TestLossFromIntervals(t *testing.T) {
+
+	counter := &lossCounter{}
+	env, _ := NewEnv("lossintervals", counter)
+	ccid := NewCCIDArgs(ccid3.CCID3{}, &ccid3.Config{LossFromIntervals: true})
+	clientConn, serverConn, clientToServer, _ := NewClientServerPipeCCID(env, ccid)
+
+	// Force packet loss, as in TestLoss
+	clientConn.Amb().Flags().SetUint32("FixRate", lossSendRate)
+	serverConn.Amb().Flags().SetUint32("FixRate", lossSendRate)
+	clientToServer.SetWriteRate(1e9, lossTransmitRate)
+
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		buf := make([]byte, 3)
+		t0 := env.Now()
+		for env.Now()-t0 < lossDuration {
+			if err := clientConn.Write(buf); err != nil {
+				break
+			}
+		}
+		clientConn.Close()
+		close(cchan)
+	}, "test client")
+
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+
+	counter.Lock()
+	defer counter.Unlock()
+	if counter.lossy == 0 {
+		t.Errorf("sender computed no loss from the loss intervals")
+	}
+	if counter.misreports > 0 {
+		t.Errorf("%d loss event rates reported by the receiver disagreed with the loss intervals", counter.misreports)
+	}
+}
+
+// lossCounter is a TraceWriter, which counts the client's loss event rate estimates that
+// show loss, and the feedback packets it finds misreporting loss
+type lossCounter struct {
+	sync.Mutex
+	lossy      int
+	misreports int
+}
+
+func (x *lossCounter) Write(r *dccp.Trace) {
+	if len(r.Labels) == 0 || r.Labels[0] != "client" {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	if r.Event == dccp.EventWarn && strings.HasPrefix(r.Comment, "Receiver reports loss rate inv") {
+		x.misreports++
+	}
+	if reading, ok := r.Sample(); ok && reading.Series == ccid3.LossSenderEstimateSample && reading.Value > 1e-6 {
+		x.lossy++
+	}
+}
+
+func (x *lossCounter) Sync() error  { return nil }
+func (x *lossCounter) Close() error { return nil }
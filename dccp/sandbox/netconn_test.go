@@ -0,0 +1,88 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"net"
+	"testing"
+	"time"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestNetConn checks that connections accepted by a Listener and dialed by a Stack work as
+// net.Conns, which preserve message boundaries and report timeouts as net.Errors
+func TestNetConn(t *testing.T) {
+	alink, dlink := dccp.NewChanPipe()
+	l, err := dccp.NewStack(alink, ccid2.CCID2{}).Listen(7)
+	if err != nil {
+		t.Fatalf("listen (%s)", err)
+	}
+	dstack := dccp.NewStack(dlink, ccid2.CCID2{})
+	dc, err := dstack.Dial(nil, 7)
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	client := dccp.NewNetConn(dc.(*dccp.Conn))
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		server, err := l.Accept()
+		if err != nil {
+			t.Errorf("accept (%s)", err)
+		}
+		accepted <- server
+	}()
+
+	for _, msg := range []string{"hello", "world"} {
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatalf("write (%s)", err)
+		}
+	}
+	server := <-accepted
+	if server == nil {
+		t.FailNow()
+	}
+
+	// Reads do not cross message boundaries, and the rest of a message is read next
+	buf := make([]byte, 3)
+	for _, expect := range []string{"hel", "lo", "wor", "ld"} {
+		n, err := server.Read(buf)
+		if err != nil || string(buf[:n]) != expect {
+			t.Fatalf("expecting %q, read %q (%v)", expect, buf[:n], err)
+		}
+	}
+
+	server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = server.Read(buf)
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("expecting timeout, got %v", err)
+	}
+	server.SetReadDeadline(time.Time{})
+
+	if addr := server.RemoteAddr(); addr.Network() != "dccp" || addr.String() != client.LocalAddr().String() {
+		t.Errorf("server remote address %v, client local address %v", addr, client.LocalAddr())
+	}
+	if sc := server.(*dccp.NetConn).Conn().GetServiceCode(); sc != 7 {
+		t.Errorf("accepted service code %d", sc)
+	}
+
+	// Connections requesting another Service Code are reset
+	go l.Accept()
+	other, err := dstack.Dial(nil, 8)
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	other.SetReadExpire(5e9)
+	if _, err = other.Read(); err != dccp.ErrAbort {
+		t.Errorf("connection with bad service code not reset (%v)", err)
+	}
+
+	client.Conn().Abort()
+	server.(*dccp.NetConn).Conn().Abort()
+	l.Close()
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("accept on closed listener")
+	}
+}
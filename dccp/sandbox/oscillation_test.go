@@ -0,0 +1,118 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+const (
+	oscillationDuration = 20e9  // Duration of the experiment in ns
+	oscillationStep     = 14e9  // Time when the latency of the path steps up, in ns
+	oscillationLatency  = 100e6 // Latency of the path in each direction before the step, in ns
+	oscillationQueueing = 400e6 // Queueing delay, which the step adds to the latency, in ns
+	oscillationRate     = 50    // Transmission rate of the path in pps
+)
+
+// TestOscillation checks that a sender with oscillation prevention lowers its instantaneous
+// sending rate as soon as the round-trip time rises above its long-term average, as it does
+// when a queue builds up at the bottleneck of the path, and sends at the allowed rate while
+// the round-trip time is steady
+func TestOscillation(t *testing.T) {
+
+	xinst := &xInstMoment{step: oscillationStep}
+	xinst.before.Init()
+	xinst.after.Init()
+	env, _ := NewEnv("oscillation", xinst)
+	ccid := NewCCIDArgs(ccid3.CCID3{}, &ccid3.Config{ReduceOscillations: true})
+	clientConn, serverConn, clientToServer, serverToClient := NewClientServerPipeCCID(env, ccid)
+	clientToServer.SetWriteLatency(oscillationLatency)
+	serverToClient.SetWriteLatency(oscillationLatency)
+	clientToServer.SetWriteRate(1e9, oscillationRate)
+
+	env.Go(func() {
+		env.Sleep(oscillationStep)
+		clientToServer.SetWriteLatency(oscillationLatency + oscillationQueueing)
+	}, "test controller")
+
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		buf := make([]byte, 200)
+		t0 := env.Now()
+		for env.Now()-t0 < oscillationDuration {
+			if err := clientConn.Write(buf); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+		}
+		clientConn.Close()
+		close(cchan)
+	}, "test client")
+
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+
+	xinst.Lock()
+	defer xinst.Unlock()
+	if math.IsNaN(xinst.before.Min()) || math.IsNaN(xinst.after.Min()) {
+		t.Fatalf("no instantaneous rate samples")
+	}
+	// While the round-trip time is steady, the instantaneous rate follows the allowed rate
+	if min, max := xinst.before.Min(), xinst.before.Max(); min < 90 || max > 110 {
+		t.Errorf("instantaneous rate ranged %.1f%%—%.1f%% of the allowed rate at a steady RTT", min, max)
+	}
+	// Once the round-trip time rises, the instantaneous rate falls well below the allowed rate
+	if min := xinst.after.Min(); min > 75 {
+		t.Errorf("instantaneous rate fell only to %.1f%% of the allowed rate after the RTT rose", min)
+	}
+}
+
+// xInstMoment is a TraceWriter, which collects the client's instantaneous sending rate samples
+// before and after a given time
+type xInstMoment struct {
+	sync.Mutex
+	step   int64
+	before Moment
+	after  Moment
+}
+
+func (x *xInstMoment) Write(r *dccp.Trace) {
+	reading, ok := r.Sample()
+	if !ok || reading.Series != ccid3.XInstSample || len(r.Labels) == 0 || r.Labels[0] != "client" {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	if r.Time < x.step {
+		x.before.Add(reading.Value)
+	} else {
+		x.after.Add(reading.Value)
+	}
+}
+
+func (x *xInstMoment) Sync() error  { return nil }
+func (x *xInstMoment) Close() error { return nil }
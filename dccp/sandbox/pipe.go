@@ -10,9 +10,10 @@ import (
 	"github.com/petar/GoDCCP/dccp"
 )
 
-// Pipe is an in-process commincation channel, whose two ends implement dccp.HeaderConn.
+// Pipe is an in-process commincation channel, whose two ends implement dccp.ECNHeaderConn.
 // It supports rate limiting, latency emulation and receive buffer emulation (in order to
-// capture slow readers).
+// capture slow readers). It can also emulate a router that marks packets with ECN, instead
+// of dropping them, when its queue builds up.
 type Pipe struct {
 	amb *dccp.Amb
 	ha, hb headerHalfPipe
@@ -61,6 +62,15 @@ type headerHalfPipe struct {
 	// rateIntervalCounter-th time interval
 	rateIntervalFill       uint32
 
+	// If markECN is set, ECN-capable packets in excess of the rate limit are marked Congestion
+	// Experienced rather than dropped, as long as no more than ratePacketsPerInterval excess
+	// packets are transmitted per interval
+	markECN                bool
+
+	// maxPacketSize is the size of the largest packet delivered, emulating the MTU of the
+	// path. Larger packets are dropped. Zero means no limit.
+	maxPacketSize          int
+
 	// readDeadline is the absolute time deadline for the reads on this side of the connection
 	readDeadlineLk         sync.Mutex
 	readDeadline           int64
@@ -108,6 +118,28 @@ func (x *headerHalfPipe) SetWriteRate(rateInterval int64, ratePacketsPerInterval
 	x.rateIntervalFill = 0
 }
 
+// SetMarkECN determines whether ECN-capable packets that exceed the write rate are marked
+// Congestion Experienced, rather than dropped. Marking emulates a router with Active Queue
+// Management, whose queue absorbs up to one more interval's worth of packets.
+func (x *headerHalfPipe) SetMarkECN(markECN bool) {
+	x.rateLk.Lock()
+	defer x.rateLk.Unlock()
+	x.markECN = markECN
+}
+
+// SetMaxPacketSize makes the pipe drop packets, whose wire format is larger than size bytes,
+// like a path with a smaller MTU than the link. A size of zero removes the limit.
+func (x *headerHalfPipe) SetMaxPacketSize(size int) {
+	x.rateLk.Lock()
+	defer x.rateLk.Unlock()
+	x.maxPacketSize = size
+}
+
+// IsECNCapable implements dccp.ECNHeaderConn.IsECNCapable
+func (x *headerHalfPipe) IsECNCapable() bool {
+	return true
+}
+
 // GetMTU implements dccp.HeaderConn.GetMTU
 func (x *headerHalfPipe) GetMTU() int {
 	return 1500
@@ -187,7 +219,18 @@ func (x *headerHalfPipe) Write(h *dccp.Header) (err error) {
 		return dccp.ErrBad
 	}
 
-	if x.rateFilter() {
+	if x.isTooBig(h) {
+		x.amb.E(dccp.EventDrop, "Too big", h)
+		return nil
+	}
+	pass, mark := x.rateFilter(h.ECN == dccp.ECNECT0 || h.ECN == dccp.ECNECT1)
+	if mark {
+		x.amb.E(dccp.EventInfo, "Mark CE", h)
+		marked := *h
+		marked.ECN = dccp.ECNCE
+		h = &marked
+	}
+	if pass {
 		if len(x.write) >= cap(x.write) {
 			x.amb.E(dccp.EventDrop, "Slow reader", h)
 		} else {
@@ -204,9 +247,22 @@ func (x *headerHalfPipe) Write(h *dccp.Header) (err error) {
 	return nil
 }
 
+// isTooBig returns true if h exceeds the packet size limit set by SetMaxPacketSize
+func (x *headerHalfPipe) isTooBig(h *dccp.Header) bool {
+	x.rateLk.Lock()
+	max := x.maxPacketSize
+	x.rateLk.Unlock()
+	if max == 0 {
+		return false
+	}
+	n, err := h.Len()
+	return err == nil && n > max
+}
+
 // rateFilter returns true if another packet can be sent now without violating the rate
-// limit set by SetWriteRate
-func (x *headerHalfPipe) rateFilter() bool {
+// limit set by SetWriteRate. If ECN marking is on and ect is set, indicating an ECN-capable
+// packet, rateFilter may also let an excess packet through, in which case mark is true.
+func (x *headerHalfPipe) rateFilter(ect bool) (pass, mark bool) {
 	x.rateLk.Lock()
 	defer x.rateLk.Unlock()
 
@@ -215,12 +271,15 @@ func (x *headerHalfPipe) rateFilter() bool {
 	if gctr != x.rateIntervalCounter {
 		x.rateIntervalCounter = gctr
 		x.rateIntervalFill = 1
-		return true
+		return true, false
 	} else if x.rateIntervalFill < x.ratePacketsPerInterval {
 		x.rateIntervalFill++
-		return true
+		return true, false
+	} else if x.markECN && ect && x.rateIntervalFill < 2*x.ratePacketsPerInterval {
+		x.rateIntervalFill++
+		return true, true
 	}
-	return false
+	return false, false
 }
 
 // Close implements dccp.HeaderConn.Close
@@ -0,0 +1,85 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+const (
+	pmtuInterval = 50e6                  // Interval between writes of the client = 50 ms
+	pmtuRate     = 2 * 1e9 / pmtuInterval // Fixed send rate for both endpoints in packets per second = 40 pps
+)
+
+// TestPMTU checks that the MTU of a connection converges to the largest packet size delivered
+// by the path, and that it is lowered when the path stops delivering packets of that size
+func TestPMTU(t *testing.T) {
+
+	ccmps := newCommentCounter("client", "CCMPS=")
+	env, _ := NewEnv("pmtu", ccmps)
+	clientConn, serverConn, clientToServer, _ := NewClientServerPipe(env)
+	clientToServer.SetMaxPacketSize(1400)
+
+	// A fixed send rate with room for probes keeps acknowledgements from being dropped by
+	// a slow strobe, which would be mistaken for lost probes
+	clientConn.Amb().Flags().SetUint32("FixRate", pmtuRate)
+	serverConn.Amb().Flags().SetUint32("FixRate", pmtuRate)
+
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+	}, "test server")
+
+	// Payload size of a DataAck packet of the given size, as reported by GetMTU
+	payload := func(size int) int { return size - 48 }
+
+	// converged reports whether the MTU of conn matches a path MTU of size
+	converged := func(conn *dccp.Conn, size int) bool {
+		mtu := conn.GetMTU()
+		return mtu <= payload(size) && mtu > payload(size-dccp.PMTUPrecision)
+	}
+
+	// write sends data from the client until done returns true or the duration elapses
+	write := func(duration int64, done func() bool) {
+		buf := make([]byte, 100)
+		t0 := env.Now()
+		for env.Now()-t0 < duration && !done() {
+			if err := clientConn.Write(buf); err != nil {
+				t.Fatalf("error writing (%s)", err)
+			}
+			env.Sleep(pmtuInterval)
+		}
+	}
+
+	write(20e9, func() bool { return converged(clientConn, 1400) && converged(serverConn, 1500) })
+	if !converged(clientConn, 1400) {
+		t.Errorf("client mtu %d does not match a path mtu of 1400", clientConn.GetMTU())
+	}
+	if !converged(serverConn, 1500) {
+		t.Errorf("server mtu %d does not match a path mtu of 1500", serverConn.GetMTU())
+	}
+	if ccmps.Count() == 0 {
+		t.Errorf("client congestion control was not told the path mtu")
+	}
+
+	// The path drops packets that used to be delivered
+	clientToServer.SetMaxPacketSize(1200)
+	write(dccp.PMTUValidateInterval+20e9, func() bool { return converged(clientConn, 1200) })
+	if !converged(clientConn, 1200) {
+		t.Errorf("client mtu %d does not match a path mtu of 1200", clientConn.GetMTU())
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+// TestServices checks that a Stack routes new connections to the handlers and listeners
+// registered for their Service Codes, that each service negotiates its own CCID, and that
+// unregistered Service Codes are rejected.
+func TestServices(t *testing.T) {
+	echo, _ := dccp.ParseServiceCode([]byte("SC:echo"))
+	perf, _ := dccp.ParseServiceCode([]byte("SC:perf"))
+
+	alink, dlink := dccp.NewChanPipe()
+	astack := dccp.NewStack(alink, ccid2.CCID2{})
+	l, err := astack.Listen(echo)
+	if err != nil {
+		t.Fatalf("listen (%s)", err)
+	}
+	served := make(chan byte, 1)
+	err = astack.HandleFunc(perf, func(c *dccp.Conn) {
+		defer c.Abort()
+		c.SetReadExpire(5e9)
+		if _, err := c.Read(); err != nil {
+			t.Errorf("handler read (%s)", err)
+		}
+		ccid, _ := c.GetCCID()
+		served <- ccid
+	}, ccid3.CCID3{})
+	if err != nil {
+		t.Fatalf("handle (%s)", err)
+	}
+	if err := astack.HandleFunc(echo, func(c *dccp.Conn) {}); err != dccp.ErrInvalid {
+		t.Errorf("service code registered twice (%v)", err)
+	}
+	if _, err := astack.Accept(); err != dccp.ErrInvalid {
+		t.Errorf("accept while routing (%v)", err)
+	}
+
+	dstack := dccp.NewStack(dlink, ccid2.CCID2{}, ccid3.CCID3{})
+
+	// The handler of perf is served over CCID 3
+	pc, err := dstack.Dial(nil, perf)
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	if err := pc.Write([]byte{1}); err != nil {
+		t.Fatalf("write (%s)", err)
+	}
+	if ccid := <-served; ccid != dccp.CCID3 {
+		t.Errorf("perf served over CCID %d, expected %d", ccid, dccp.CCID3)
+	}
+	pc.Close()
+
+	// The listener of echo accepts its connections over CCID 2
+	ec, err := dstack.Dial(nil, echo)
+	if err != nil {
+		t.Fatalf("dial (%s)", err)
+	}
+	if err := ec.Write([]byte{2}); err != nil {
+		t.Fatalf("write (%s)", err)
+	}
+	server, err := l.(*dccp.Listener).AcceptDCCP()
+	if err != nil {
+		t.Fatalf("accept (%s)", err)
+	}
+	if sc := server.GetServiceCode(); sc != echo {
+		t.Errorf("accepted %s, expected %s", dccp.ServiceCodeString(sc), dccp.ServiceCodeString(echo))
+	}
+	if ccid, _ := server.GetCCID(); ccid != dccp.CCID2 {
+		t.Errorf("echo served over CCID %d, expected %d", ccid, dccp.CCID2)
+	}
+	ec.Close()
+	server.Abort()
+
+	// Unregistered Service Codes are reset, including that of a closed Listener
+	l.Close()
+	for _, sc := range []uint32{echo, 9} {
+		c, err := dstack.Dial(nil, sc)
+		if err != nil {
+			t.Fatalf("dial (%s)", err)
+		}
+		c.SetReadExpire(5e9)
+		if _, err = c.Read(); err != dccp.ErrAbort {
+			t.Errorf("connection to %s not reset (%v)", dccp.ServiceCodeString(sc), err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+)
+
+// TestSlowReceiver checks that a server, whose application falls behind in reading, declares
+// itself a slow receiver to the client.
+func TestSlowReceiver(t *testing.T) {
+
+	env, _ := NewEnv("slowreceiver")
+	clientConn, serverConn, _, _ := NewClientServerPipeCCID(env, ccid2.CCID2{})
+
+	buf := make([]byte, 100)
+	var slow bool
+	for i := 0; i < 20 && !slow; i++ {
+		if err := clientConn.Write(buf); err != nil {
+			t.Fatalf("error writing (%s)", err)
+		}
+		env.Sleep(1e7)
+		slow = clientConn.IsReceiverSlow()
+	}
+	if !slow {
+		t.Errorf("client did not learn of slow receiver")
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+)
+
+const (
+	staleRate   = 10  // Fixed send rate, in packets per second
+	staleExpire = 5e7 // Expiry of written data, in ns, shorter than the send interval
+	staleCount  = 20  // Number of expiring blocks of data written
+)
+
+// TestStale checks that data written with WriteExpire is discarded, rather than sent, when the
+// congestion control holds it past its expiry, and that the discarded data does not take
+// up sending slots of the data that follows.
+func TestStale(t *testing.T) {
+
+	env, _ := NewEnv("stale")
+	clientConn, serverConn, _, _ := NewClientServerPipe(env)
+	clientConn.Amb().Flags().SetUint32("FixRate", staleRate)
+	serverConn.Amb().Flags().SetUint32("FixRate", staleRate)
+
+	// The server counts blocks of expiring data until it reads the end marker
+	done := make(chan int, 1)
+	env.Go(func() {
+		var n int
+		serverConn.SetReadExpire(30e9)
+		for {
+			data, err := serverConn.Read()
+			if err != nil {
+				t.Errorf("server read (%s)", err)
+				break
+			}
+			if len(data) == 1 && data[0] == 2 {
+				break
+			}
+			if len(data) == 1 && data[0] == 1 {
+				n++
+			}
+		}
+		done <- n
+	}, "test server")
+
+	// Warm up, until feedback from the server fixes the send rate
+	for i := 0; i < 2*staleRate; i++ {
+		if err := clientConn.Write([]byte{0}); err != nil {
+			t.Fatalf("client write (%s)", err)
+		}
+	}
+	if n := clientConn.GetStaleCount(); n != 0 {
+		t.Errorf("%d blocks of data without expiry were discarded", n)
+	}
+
+	for i := 0; i < staleCount; i++ {
+		if err := clientConn.WriteExpire([]byte{1}, staleExpire); err != nil {
+			t.Fatalf("client write expire (%s)", err)
+		}
+	}
+	if err := clientConn.WriteExpire([]byte{1}, -1); err != dccp.ErrInvalid {
+		t.Errorf("negative expiry returned (%v)", err)
+	}
+	if err := clientConn.Write([]byte{2}); err != nil {
+		t.Fatalf("client write (%s)", err)
+	}
+
+	received := <-done
+	stale := clientConn.GetStaleCount()
+	if stale == 0 || received == 0 {
+		t.Errorf("%d blocks of data received, %d discarded", received, stale)
+	}
+	if int64(received)+stale != staleCount {
+		t.Errorf("%d blocks of data received and %d discarded, out of %d", received, stale, staleCount)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
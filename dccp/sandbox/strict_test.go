@@ -0,0 +1,113 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+const (
+	strictLatency = 100e6 // Latency of the path in each direction, in ns
+	strictRate    = 50    // Fixed send rate for both endpoints in pps
+)
+
+// TestStrictRFC4342 checks that, without the private Roundtrip Report option, the receiver
+// estimates the round-trip time accurately from the window counters
+func TestStrictRFC4342(t *testing.T) {
+
+	rtt := &strictRoundtrip{}
+	rtt.window.Init()
+	env, _ := NewEnv("strict", rtt)
+	ccid := NewCCIDArgs(ccid3.CCID3{}, &ccid3.Config{StrictRFC4342: true})
+	clientConn, serverConn, clientToServer, serverToClient := NewClientServerPipeCCID(env, ccid)
+	clientToServer.SetWriteLatency(strictLatency)
+	serverToClient.SetWriteLatency(strictLatency)
+
+	// Fix the send rate, as in TestRoundtripEstimation. The sender advances the window counter
+	// only with the packets it sends, so the estimate is coarser at lower rates.
+	clientConn.Amb().Flags().SetUint32("FixRate", strictRate)
+	serverConn.Amb().Flags().SetUint32("FixRate", strictRate)
+
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		buf := make([]byte, 3)
+		t0 := env.Now()
+		for env.Now()-t0 < roundtripDuration {
+			if err := clientConn.Write(buf); err != nil {
+				break
+			}
+		}
+		clientConn.Close()
+		close(cchan)
+	}, "test client")
+
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+
+	rtt.Lock()
+	defer rtt.Unlock()
+	if rtt.reports > 0 {
+		t.Errorf("server read %d roundtrip reports", rtt.reports)
+	}
+	if math.IsNaN(rtt.window.Average()) {
+		t.Fatalf("server made no window counter RTT estimates")
+	}
+	// Each window begins with the first packet sent at least a quarter of an RTT after the
+	// previous one began, so the estimate exceeds the RTT by up to four send intervals
+	lo, hi := NanoToMilli(2*strictLatency), NanoToMilli(2*strictLatency+4*1e9/strictRate)
+	if rtt.last < 0.9*lo || rtt.last > 1.1*hi {
+		t.Errorf("server RTT estimate %0.1fms is outside %0.1fms—%0.1fms", rtt.last, lo, hi)
+	}
+}
+
+// strictRoundtrip is a TraceWriter, which collects the server's window counter RTT estimates
+// and counts the roundtrip reports it reads
+type strictRoundtrip struct {
+	sync.Mutex
+	window  Moment
+	last    float64
+	reports int
+}
+
+func (x *strictRoundtrip) Write(r *dccp.Trace) {
+	reading, ok := r.Sample()
+	if !ok || len(r.Labels) == 0 || r.Labels[0] != "server" {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	switch reading.Series {
+	case ccid3.RoundtripWindowSample:
+		x.window.Add(reading.Value)
+		x.last = reading.Value
+	case ccid3.RoundtripReportSample:
+		x.reports++
+	}
+}
+
+func (x *strictRoundtrip) Sync() error  { return nil }
+func (x *strictRoundtrip) Close() error { return nil }
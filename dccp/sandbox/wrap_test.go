@@ -0,0 +1,155 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package sandbox
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"github.com/petar/GoDCCP/dccp"
+	"github.com/petar/GoDCCP/dccp/ccid2"
+	"github.com/petar/GoDCCP/dccp/ccid3"
+)
+
+const (
+	wrapDuration     = 5e9 // Duration of the experiment in ns
+	wrapSendRate     = 40  // Fixed sender rate in pps
+	wrapTransmitRate = 30  // Fixed transmission rate of the network in pps
+	wrapSeqNos       = 15  // Number of sequence numbers before the wrap, starting from the ISS
+)
+
+// wrapRandom is a source of randomness, from which both endpoints choose an Initial Sequence
+// Number wrapSeqNos packets before the wrap of the sequence number space
+type wrapRandom struct{}
+
+func (wrapRandom) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0xff
+	}
+	p[len(p)-1] = 0xff - wrapSeqNos
+	return len(p), nil
+}
+
+// TestWrap checks that a CCID 3 connection, whose sequence numbers wrap shortly after it
+// opens, continues to exchange data and track its losses
+func TestWrap(t *testing.T) {
+	testWrap(t, "wrap", ccid3.CCID3{})
+}
+
+// TestWrapCCID2 is like TestWrap, except that the connection uses CCID 2, whose Ack Vectors
+// and acknowledgement history must follow the sequence numbers across the wrap. A loss after
+// the wrap must reduce the congestion window only once per recovery period, which ends with
+// the greatest sequence number sent when the loss was detected.
+func TestWrapCCID2(t *testing.T) {
+	lost := &wrapLost{}
+	testWrap(t, "wrapccid2", ccid2.CCID2{}, lost)
+
+	// Without Ack Vectors, the sender cannot tell which packets were lost
+	lost.Lock()
+	defer lost.Unlock()
+	if lost.afterWrap == 0 {
+		t.Errorf("client inferred the loss of no packet sent after the wrap")
+	}
+	if lost.badRecover > 0 {
+		t.Errorf("%d congestion events ended recovery before the lost packet", lost.badRecover)
+	}
+	if lost.repeated > 0 {
+		t.Errorf("%d losses reduced the window again within a recovery period", lost.repeated)
+	}
+}
+
+func testWrap(t *testing.T, name string, ccid dccp.CCID, guzzles ...dccp.TraceWriter) {
+
+	env, _ := NewEnv(name, guzzles...)
+	env.SetRandom(wrapRandom{})
+	clientConn, serverConn, clientToServer, _ := NewClientServerPipeCCID(env, ccid)
+
+	// Loss intervals start and end on both sides of the wrap. CCID 2 ignores the fixed
+	// rate, and loses packets as its congestion window outgrows the network.
+	clientConn.Amb().Flags().SetUint32("FixRate", wrapSendRate)
+	serverConn.Amb().Flags().SetUint32("FixRate", wrapSendRate)
+	clientToServer.SetWriteRate(1e9, wrapTransmitRate)
+
+	cchan := make(chan int, 1)
+	env.Go(func() {
+		t0 := env.Now()
+		for env.Now()-t0 < wrapDuration {
+			if err := clientConn.Write([]byte{1, 2, 3}); err != nil {
+				t.Errorf("client write (%s)", err)
+				break
+			}
+		}
+		clientConn.Close()
+		close(cchan)
+	}, "test client")
+
+	var received int
+	schan := make(chan int, 1)
+	env.Go(func() {
+		for {
+			if _, err := serverConn.Read(); err != nil {
+				break
+			}
+			received++
+		}
+		close(schan)
+	}, "test server")
+
+	<-cchan
+	<-schan
+
+	// The connection must remain open well past the wrap
+	if received < 4*wrapSeqNos {
+		t.Errorf("server received %d packets", received)
+	}
+	if err := clientConn.Error(); err != nil && err != dccp.ErrEOF {
+		t.Errorf("client connection error (%s)", err)
+	}
+
+	clientConn.Abort()
+	serverConn.Abort()
+	env.NewGoJoin("end-of-test", clientConn.Joiner(), serverConn.Joiner()).Join()
+	dccp.NewAmb("line", env).E(dccp.EventMatch, "Server and client done.")
+	if err := env.Close(); err != nil {
+		t.Errorf("error closing runtime (%s)", err)
+	}
+}
+
+// wrapLost is a TraceWriter, which counts the client's CCID 2 congestion events caused by the
+// loss of packets sent after the wrap, as well as the events whose recovery period does not
+// cover the lost packet, or which fall within the recovery period of the previous event
+type wrapLost struct {
+	sync.Mutex
+	afterWrap  int
+	badRecover int
+	repeated   int
+	recover    int64 // End of the recovery period of the last congestion event
+	recoverSet bool
+}
+
+func (x *wrapLost) Write(r *dccp.Trace) {
+	if len(r.Labels) == 0 || r.Labels[0] != "client" {
+		return
+	}
+	var seqNo, recover int64
+	if _, err := fmt.Sscanf(r.Comment, "Congestion event, lost=%d, recover=%d,", &seqNo, &recover); err != nil {
+		return
+	}
+	x.Lock()
+	defer x.Unlock()
+	if seqNo < 1<<47 {
+		x.afterWrap++
+	}
+	if !dccp.SeqLessEq(seqNo, recover) {
+		x.badRecover++
+	}
+	if x.recoverSet && dccp.SeqLessEq(seqNo, x.recover) {
+		x.repeated++
+	}
+	x.recover, x.recoverSet = recover, true
+}
+
+func (x *wrapLost) Sync() error  { return nil }
+func (x *wrapLost) Close() error { return nil }
@@ -40,6 +40,33 @@ type SegmentConn interface {
 	Close() error
 }
 
+// ECNSegmentConn is implemented by SegmentConns that can carry the ECN codepoints of blocks
+type ECNSegmentConn interface {
+	SegmentConn
+
+	// IsECNCapable returns true if the underlying link carries ECN codepoints. Otherwise,
+	// blocks are read with codepoint ECNNotECT and the codepoints of written blocks are lost.
+	IsECNCapable() bool
+
+	// ReadECN is like Read, except that it also returns the ECN codepoint of the block
+	ReadECN() (block []byte, ecn byte, err error)
+
+	// WriteECN is like Write, except that the block is sent with the ECN codepoint ecn
+	WriteECN(block []byte, ecn byte) (err error)
+}
+
+// AddrSegmentConn is implemented by SegmentConns that know the link-layer addresses of their
+// endpoints
+type AddrSegmentConn interface {
+	SegmentConn
+
+	// LocalAddr returns the link-layer address of the local endpoint, or nil if it is not known
+	LocalAddr() net.Addr
+
+	// RemoteAddr returns the link-layer address of the remote endpoint
+	RemoteAddr() net.Addr
+}
+
 // SegmentDialAccepter represents a type that can accept and dial lossy packet connections
 type SegmentDialAccepter interface {
 	Accept() (c SegmentConn, err error)
@@ -70,6 +97,24 @@ type HeaderConn interface {
 	Close() error
 }
 
+// ECNHeaderConn is implemented by HeaderConns that can carry ECN codepoints. Such a HeaderConn
+// reads every header with the ECN codepoint of its packet in Header.ECN, and sends every
+// header with the codepoint Header.ECN, Section 12.
+type ECNHeaderConn interface {
+	HeaderConn
+
+	// IsECNCapable returns true if the underlying link carries ECN codepoints
+	IsECNCapable() bool
+}
+
+// AddrHeaderConn is implemented by HeaderConns that know the link-layer addresses of their
+// endpoints. Its methods behave like those of AddrSegmentConn.
+type AddrHeaderConn interface {
+	HeaderConn
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
 // —————
 // NewHeaderConn creates a HeaderConn on top of a SegmentConn
 func NewHeaderConn(bc SegmentConn) HeaderConn {
@@ -87,23 +132,45 @@ func (hc *headerConn) GetMTU() int {
 // Since a SegmentConn already has the notion of a flow, both Read
 // and Write pass zero labels for the Source and Dest IPs
 // to the DCCP header's read and write functions.
+// Short sequence numbers are accepted syntactically. Whether they are
+// allowed on a connection is decided by Conn, see step6_CheckSeqNo.
 
 func (hc *headerConn) Read() (h *Header, err error) {
-	p, err := hc.bc.Read()
+	var p []byte
+	var ecn byte
+	if ec, ok := hc.bc.(ECNSegmentConn); ok {
+		p, ecn, err = ec.ReadECN()
+	} else {
+		p, err = hc.bc.Read()
+	}
+	if err != nil {
+		return nil, err
+	}
+	h, err = ReadHeader(p, LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, true)
 	if err != nil {
 		return nil, err
 	}
-	return ReadHeader(p, LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, false)
+	h.ECN = ecn
+	return h, nil
 }
 
 func (hc *headerConn) Write(h *Header) (err error) {
-	p, err := h.Write(LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, false)
+	p, err := h.Write(LabelZero.Bytes(), LabelZero.Bytes(), AnyProto, true)
 	if err != nil {
 		return err
 	}
+	if ec, ok := hc.bc.(ECNSegmentConn); ok {
+		return ec.WriteECN(p, h.ECN)
+	}
 	return hc.bc.Write(p)
 }
 
+// IsECNCapable implements ECNHeaderConn.IsECNCapable
+func (hc *headerConn) IsECNCapable() bool {
+	ec, ok := hc.bc.(ECNSegmentConn)
+	return ok && ec.IsECNCapable()
+}
+
 func (hc *headerConn) LocalLabel() Bytes {
 	return hc.bc.LocalLabel()
 }
@@ -112,6 +179,22 @@ func (hc *headerConn) RemoteLabel() Bytes {
 	return hc.bc.RemoteLabel()
 }
 
+// LocalAddr implements AddrHeaderConn.LocalAddr
+func (hc *headerConn) LocalAddr() net.Addr {
+	if ac, ok := hc.bc.(AddrSegmentConn); ok {
+		return ac.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr implements AddrHeaderConn.RemoteAddr
+func (hc *headerConn) RemoteAddr() net.Addr {
+	if ac, ok := hc.bc.(AddrSegmentConn); ok {
+		return ac.RemoteAddr()
+	}
+	return nil
+}
+
 func (hc *headerConn) SetReadExpire(nsec int64) error {
 	return hc.bc.SetReadExpire(nsec)
 }
@@ -11,12 +11,12 @@ func (c *Conn) PlaceSeqAck(h *Header) {
 
 	// Update GSR
 	gsr := c.socket.GetGSR()
-	c.socket.SetGSR(max64(gsr, h.SeqNo))
+	c.socket.SetGSR(SeqMax(gsr, h.SeqNo))
 
 	// Update GAR
 	if h.HasAckNo() {
 		gar := c.socket.GetGAR()
-		c.socket.SetGAR(max64(gar, h.AckNo))
+		c.socket.SetGAR(SeqMax(gar, h.AckNo))
 	}
 }
 
@@ -47,7 +47,7 @@ func (c *Conn) WriteSeqAck(h *writeHeader) {
 func (c *Conn) takeSeqAck(h *Header) *Header {
 	c.AssertLocked()
 
-	h.SeqNo = max64(c.socket.GetISS(), c.socket.GetGSS() + 1)
+	h.SeqNo = SeqAdd(c.socket.GetGSS(), 1)
 	c.socket.SetGSS(h.SeqNo)
 	h.AckNo = c.socket.GetGSR()
 
@@ -56,9 +56,14 @@ func (c *Conn) takeSeqAck(h *Header) *Header {
 
 func (c *Conn) takeAbnormalSeqAck(h, inResponseTo *Header) *Header {
 	c.AssertLocked()
+	return placeAbnormalSeqAck(h, inResponseTo)
+}
 
+// placeAbnormalSeqAck fills in the sequence and acknowledgement numbers of a packet sent in
+// response to inResponseTo, when no sequence number state is available, Section 8.3.1
+func placeAbnormalSeqAck(h, inResponseTo *Header) *Header {
 	if inResponseTo.HasAckNo() {
-		h.SeqNo = inResponseTo.AckNo + 1
+		h.SeqNo = SeqAdd(inResponseTo.AckNo, 1)
 	} else {
 		h.SeqNo = 0
 	}
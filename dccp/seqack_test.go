@@ -0,0 +1,25 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+func TestExtendSeqNo(t *testing.T) {
+	tests := []struct{ ref, want int64 }{
+		{0x0000123456789a, 0x00001234567890},
+		{0x00001234ffff00, 0x00001235000010}, // Low-order bits wrapped forward
+		{0x00001235000010, 0x00001234ffff00}, // Low-order bits wrapped backward
+		{0x00000000000010, 0xffffffffffff00}, // Wrap of the entire sequence space
+		{0xffffffffffff00, 0x00000000000010},
+	}
+	for _, tt := range tests {
+		s := tt.want & 0xffffff
+		if got := ExtendSeqNo(s, tt.ref); got != tt.want&(1<<48-1) {
+			t.Errorf("extending %06x with reference %012x: got %012x, want %012x", s, tt.ref, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// Sequence and Acknowledgement Numbers are 48-bit unsigned integers, which are added and
+// compared in circular sequence space, modulo 2^48, Section 7.1. A number precedes the numbers
+// up to 2^47 - 1 positions after it, and follows the rest.
+
+// SeqAdd returns the sequence number d positions after s, or before s if d is negative
+func SeqAdd(s, d int64) int64 { return (s + d) & SEQNOMAX }
+
+// SeqDist returns the circular distance from s to t, that is the number of positions t lies
+// after s. The result is negative if t precedes s.
+func SeqDist(s, t int64) int64 {
+	d := (t - s) & SEQNOMAX
+	if d >= 1<<47 {
+		d -= 1 << 48
+	}
+	return d
+}
+
+// SeqLess returns true if s precedes t
+func SeqLess(s, t int64) bool { return SeqDist(s, t) > 0 }
+
+// SeqLessEq returns true if s precedes or equals t
+func SeqLessEq(s, t int64) bool { return SeqDist(s, t) >= 0 }
+
+// SeqMax returns the later of s and t
+func SeqMax(s, t int64) int64 {
+	if SeqLess(s, t) {
+		return t
+	}
+	return s
+}
+
+// SeqInWindow returns true if s lies within the window of sequence numbers that starts at lo
+// and runs forward to hi, inclusive. The window may span the wrap of the sequence space.
+func SeqInWindow(s, lo, hi int64) bool {
+	return (s-lo)&SEQNOMAX <= (hi-lo)&SEQNOMAX
+}
+
+// ExtendSeqNo extends the 24-bit sequence number s to 48 bits, using the 48-bit sequence
+// number ref as reference. ref is GSS if s is an Acknowledgement Number, and GSR if s is a
+// Sequence Number. See Section 7.6.
+func ExtendSeqNo(s, ref int64) int64 {
+	const mask = 1<<24 - 1
+	s &= mask
+	refLow, refHigh := ref&mask, (ref>>24)&mask
+	// The circular comparison refLow (<) s is true if (s - refLow) mod 2^24 <= 2^23
+	switch {
+	case (s-refLow)&mask <= 1<<23 && s < refLow:
+		return ((refHigh+1)&mask)<<24 | s
+	case (refLow-s)&mask <= 1<<23 && refLow < s:
+		return ((refHigh-1)&mask)<<24 | s
+	}
+	return refHigh<<24 | s
+}
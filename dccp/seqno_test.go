@@ -0,0 +1,120 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"testing"
+)
+
+func TestSeqArithmetic(t *testing.T) {
+	const top = SEQNOMAX
+	if s := SeqAdd(top, 1); s != 0 {
+		t.Errorf("%012x + 1 = %012x", top, s)
+	}
+	if s := SeqAdd(1, -3); s != top-1 {
+		t.Errorf("1 - 3 = %012x", s)
+	}
+	tests := []struct{ s, u, dist int64 }{
+		{5, 9, 4},
+		{9, 5, -4},
+		{top - 1, 2, 4}, // Across the wrap
+		{2, top - 1, -4},
+		{0, 1<<47 - 1, 1<<47 - 1},
+		{0, 1 << 47, -1 << 47}, // Half the space away, t precedes s
+	}
+	for _, tt := range tests {
+		if d := SeqDist(tt.s, tt.u); d != tt.dist {
+			t.Errorf("distance from %012x to %012x: got %d, want %d", tt.s, tt.u, d, tt.dist)
+		}
+		if less := SeqLess(tt.s, tt.u); less != (tt.dist > 0) {
+			t.Errorf("%012x < %012x: got %v", tt.s, tt.u, less)
+		}
+		max := tt.s
+		if tt.dist > 0 {
+			max = tt.u
+		}
+		if m := SeqMax(tt.s, tt.u); m != max {
+			t.Errorf("max of %012x and %012x: got %012x", tt.s, tt.u, m)
+		}
+	}
+	if !SeqLessEq(top, top) || SeqLess(top, top) {
+		t.Errorf("comparing equal sequence numbers")
+	}
+
+	windows := []struct {
+		s, lo, hi int64
+		in        bool
+	}{
+		{top, top - 2, 3, true},
+		{0, top - 2, 3, true},
+		{3, top - 2, 3, true},
+		{4, top - 2, 3, false},
+		{top - 3, top - 2, 3, false},
+		{7, 7, 7, true},
+	}
+	for _, w := range windows {
+		if in := SeqInWindow(w.s, w.lo, w.hi); in != w.in {
+			t.Errorf("%012x in [%012x, %012x]: got %v", w.s, w.lo, w.hi, in)
+		}
+	}
+}
+
+// TestSocketWindowsWrap checks the sequence and acknowledgement number validity windows of a
+// connection, whose sequence numbers wrap
+func TestSocketWindowsWrap(t *testing.T) {
+	var s socket
+	s.SetISS(SEQNOMAX - 1)
+	if s.GetGSS() != SEQNOMAX-2 {
+		t.Fatalf("GSS %012x before the first packet", s.GetGSS())
+	}
+	s.SetGSS(SeqAdd(s.GetISS(), 10))
+	s.SetSWAF(100)
+	s.SetISR(SEQNOMAX - 5)
+	s.SetGSR(SEQNOMAX - 5)
+	s.SetSWBF(100)
+
+	// The windows do not extend before the initial sequence numbers
+	if awl, awh := s.GetAWLH(); awl != SEQNOMAX-1 || awh != 8 {
+		t.Errorf("AWL=%012x AWH=%012x", awl, awh)
+	}
+	if !s.InAckWindow(SEQNOMAX) || !s.InAckWindow(8) || s.InAckWindow(9) || s.InAckWindow(SEQNOMAX-2) {
+		t.Errorf("acknowledgement window misplaced across the wrap")
+	}
+	if swl, swh := s.GetSWLH(); swl != SEQNOMAX-5 || swh != 69 {
+		t.Errorf("SWL=%012x SWH=%012x", swl, swh)
+	}
+
+	// GSR advances across the wrap
+	s.UpdateGSR(3)
+	s.UpdateGSR(SEQNOMAX)
+	if s.GetGSR() != 3 {
+		t.Errorf("GSR %012x", s.GetGSR())
+	}
+	s.UpdateGSR(40)
+	if swl, swh := s.GetSWLH(); swl != 16 || swh != 115 {
+		t.Errorf("SWL=%012x SWH=%012x", swl, swh)
+	}
+}
+
+func TestReorderWrap(t *testing.T) {
+	var b reorderBuffer
+	b.Init(3)
+	var out []int64
+	for _, seqNo := range []int64{SEQNOMAX - 1, 1, SEQNOMAX, 0, 2, 3, 4} {
+		if h := b.PushPop(&Header{SeqNo: seqNo}); h != nil {
+			out = append(out, h.SeqNo)
+		}
+	}
+	want := []int64{SEQNOMAX - 1, SEQNOMAX, 0, 1}
+	if len(out) != len(want) {
+		t.Fatalf("popped %x, want %x", out, want)
+	}
+	for i := range want {
+		if out[i] != want[i] {
+			t.Errorf("popped %x, want %x", out, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+// Handler serves the connections of a service, see Stack.Handle
+type Handler interface {
+
+	// ServeDCCP is called in its own goroutine for every new connection of the service, once
+	// the client's Request has been received. ServeDCCP is responsible for closing c.
+	ServeDCCP(c *Conn)
+}
+
+// HandlerFunc is an adapter that allows the use of ordinary functions as Handlers
+type HandlerFunc func(c *Conn)
+
+// ServeDCCP implements Handler.ServeDCCP by calling f(c)
+func (f HandlerFunc) ServeDCCP(c *Conn) {
+	f(c)
+}
+
+// service is a Service Code registered with a Stack
+type service struct {
+	ccids    []CCID     // Congestion controls of the service, or nil for those of the Stack
+	handler  Handler    // Serves the connections of the service, unless a Listener accepts them
+	accepted chan *Conn // Passes connections to the Listener of the service
+	done     chan int   // Closed when the Listener of the service is closed
+}
+
+// Handle registers handler for the connections that request the Service Code serviceCode,
+// Section 8.1.2. If ccids are given, these connections support them instead of the congestion
+// controls of the Stack.
+//
+// Once a Handler or a Listener is registered, the Stack routes new connections by their
+// Service Code, in the manner of a multiplexer, and Accept can no longer be used. Requests for
+// Service Codes, which are not registered, are answered with Bad Service Code Resets. Each
+// Service Code can be registered only once.
+func (s *Stack) Handle(serviceCode uint32, handler Handler, ccids ...CCID) error {
+	return s.register(serviceCode, &service{ccids: ccids, handler: handler})
+}
+
+// HandleFunc registers the handler function f for the connections that request the Service
+// Code serviceCode. See Handle.
+func (s *Stack) HandleFunc(serviceCode uint32, f func(c *Conn), ccids ...CCID) error {
+	return s.Handle(serviceCode, HandlerFunc(f), ccids...)
+}
+
+// register adds svc to the registered services and starts routing new connections
+func (s *Stack) register(serviceCode uint32, svc *service) error {
+	if !isValidServiceCode(serviceCode) {
+		return ErrInvalid
+	}
+	s.serviceLk.Lock()
+	if s.services == nil {
+		s.services = make(map[uint32]*service)
+	}
+	if _, ok := s.services[serviceCode]; ok {
+		s.serviceLk.Unlock()
+		return ErrInvalid
+	}
+	s.services[serviceCode] = svc
+	s.serviceLk.Unlock()
+
+	s.routeOnce.Do(func() {
+		s.routeDone = make(chan int)
+		go s.routeLoop()
+	})
+	return nil
+}
+
+// unregister removes svc from the registered services. Subsequent requests for its Service
+// Code are rejected.
+func (s *Stack) unregister(serviceCode uint32, svc *service) {
+	s.serviceLk.Lock()
+	defer s.serviceLk.Unlock()
+	if s.services[serviceCode] == svc {
+		delete(s.services, serviceCode)
+	}
+}
+
+// isRouting returns true if new connections are routed to the registered services
+func (s *Stack) isRouting() bool {
+	s.serviceLk.Lock()
+	defer s.serviceLk.Unlock()
+	return s.services != nil
+}
+
+// getServices returns the lookup of the congestion controls of new connections by their
+// Service Code, or nil if no services are registered and all Service Codes are accepted
+func (s *Stack) getServices() func(uint32) []CCID {
+	if !s.isRouting() {
+		return nil
+	}
+	return s.lookupService
+}
+
+// lookupService returns the congestion controls of the service registered for serviceCode, or
+// nil if there is none
+func (s *Stack) lookupService(serviceCode uint32) []CCID {
+	s.serviceLk.Lock()
+	defer s.serviceLk.Unlock()
+	svc := s.services[serviceCode]
+	if svc == nil {
+		return nil
+	}
+	if len(svc.ccids) > 0 {
+		return svc.ccids
+	}
+	return s.ccids
+}
+
+// routeLoop routes new connections to the registered services, until the link is closed
+func (s *Stack) routeLoop() {
+	for {
+		conn, err := s.accept()
+		if err != nil {
+			s.routeErr = err
+			close(s.routeDone)
+			return
+		}
+		go s.route(conn)
+	}
+}
+
+// route waits for the Request of conn and passes the connection to the service it requests
+func (s *Stack) route(conn *Conn) {
+	conn.waitLISTEN()
+	if conn.Error() != nil {
+		// The Request was rejected or never came
+		return
+	}
+	serviceCode := conn.GetServiceCode()
+	s.serviceLk.Lock()
+	svc := s.services[serviceCode]
+	s.serviceLk.Unlock()
+	if svc == nil {
+		// The service was withdrawn after the Request was accepted
+		conn.abortWith(ResetBadServiceCode)
+		return
+	}
+	if svc.handler != nil {
+		svc.handler.ServeDCCP(conn)
+		return
+	}
+	select {
+	case svc.accepted <- conn:
+	case <-svc.done:
+		conn.abortWith(ResetBadServiceCode)
+	}
+}
@@ -4,6 +4,8 @@
 
 package dccp
 
+import "strconv"
+
 // After '8.1.2. Service Codes'
 
 func isValidServiceCode(sc uint32) bool { return sc != 4294967295 }
@@ -34,9 +36,9 @@ func isASCIIServiceCodeChar(c byte) bool {
 
 func serviceCodeToSlice(u uint32) []byte {
 	p := make([]byte, 4)
-	p[0] = byte(u >> 3 * 8)
-	p[1] = byte((u >> 2 * 8) & 0xff)
-	p[2] = byte((u >> 1 * 8) & 0xff)
+	p[0] = byte(u >> 24)
+	p[1] = byte((u >> 16) & 0xff)
+	p[2] = byte((u >> 8) & 0xff)
 	p[3] = byte(u & 0xff)
 	return p
 }
@@ -53,18 +55,43 @@ func sliceToServiceCode(p []byte) uint32 {
 	return s
 }
 
-// TODO: Implement additional string representations according to '8.1.2. Service Codes'
-
+// ServiceCodeString returns the textual representation of a Service Code. Codes whose four
+// bytes are all ASCII Service Code characters are written as "SC:" followed by the four
+// characters, other codes are written as "SC=" followed by the decimal value.
 func ServiceCodeString(code uint32) string {
-	return "SC:" + string(serviceCodeToSlice(code))
+	p := serviceCodeToSlice(code)
+	for _, c := range p {
+		if !isASCIIServiceCodeChar(c) {
+			return "SC=" + strconv.FormatUint(uint64(code), 10)
+		}
+	}
+	return "SC:" + string(p)
 }
 
+// ParseServiceCode parses the textual representation of a Service Code, either "SC:" followed
+// by four ASCII Service Code characters, e.g. "SC:perf", or "SC=" followed by a decimal value,
+// e.g. "SC=1885693542"
 func ParseServiceCode(p []byte) (uint32, error) {
-	if len(p) != 7 {
+	if len(p) < 4 || string(p[:2]) != "SC" {
 		return 0, ErrSyntax
 	}
-	if string(p[:3]) != "SC:" {
-		return 0, ErrSyntax
+	switch p[2] {
+	case ':':
+		if len(p) != 7 {
+			return 0, ErrSyntax
+		}
+		for _, c := range p[3:] {
+			if !isASCIIServiceCodeChar(c) {
+				return 0, ErrSyntax
+			}
+		}
+		return sliceToServiceCode(p[3:7]), nil
+	case '=':
+		code, err := strconv.ParseUint(string(p[3:]), 10, 32)
+		if err != nil || !isValidServiceCode(uint32(code)) {
+			return 0, ErrSyntax
+		}
+		return uint32(code), nil
 	}
-	return sliceToServiceCode(p[3:7]), nil
+	return 0, ErrSyntax
 }
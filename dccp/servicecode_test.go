@@ -0,0 +1,32 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import "testing"
+
+func TestServiceCodeString(t *testing.T) {
+	for _, x := range []struct {
+		code uint32
+		text string
+	}{
+		{0x70657266, "SC:perf"},
+		{0x52545020, "SC:RTP "},
+		{7, "SC=7"},
+		{0xfffffffe, "SC=4294967294"},
+	} {
+		if s := ServiceCodeString(x.code); s != x.text {
+			t.Errorf("service code %d prints as %q, expected %q", x.code, s, x.text)
+		}
+		code, err := ParseServiceCode([]byte(x.text))
+		if err != nil || code != x.code {
+			t.Errorf("%q parses as %d (%v), expected %d", x.text, code, err, x.code)
+		}
+	}
+	for _, text := range []string{"", "SC:", "SC:perfs", "SC:pe!f", "SC=", "SC=x", "SC=4294967295", "SX:perf"} {
+		if _, err := ParseServiceCode([]byte(text)); err == nil {
+			t.Errorf("%q parses", text)
+		}
+	}
+}
@@ -7,7 +7,6 @@ package dccp
 import (
 	"bytes"
 	"fmt"
-	"math/rand"
 )
 
 // socket is a data structure, maintaining the DCCP socket variables.
@@ -50,6 +49,30 @@ type socket struct {
 	// DCCP endpoint (DCCP B)
 	SWBF int64 
 
+	// Send Ack Vector/A Feature, see Section 11.5
+	// When set, the local DCCP endpoint (DCCP A) sends Ack Vector options on its acknowledgements
+	SendAckVector bool
+
+	// ECN is set if the local DCCP endpoint (DCCP A) sends ECN-capable packets, that is if the
+	// link carries ECN codepoints and ECN Incapable/B is zero, see Section 12.1
+	ECN bool
+
+	// Send NDP Count/A Feature, see Section 7.7.2
+	// When set, the local DCCP endpoint (DCCP A) sends NDP Count options
+	SendNDPCount bool
+
+	// Minimum Checksum Coverage/A and Minimum Checksum Coverage/B Features, see Section 9.2.1
+	// When zero, DCCP A (respectively DCCP B) accepts only packets with full checksum coverage.
+	// Otherwise, it also accepts packets whose CsCov is at least the feature value.
+	MinCsCovA byte
+	MinCsCovB byte
+
+	// Allow Short Seqnos/A and Allow Short Seqnos/B Features, see Section 7.6.1
+	// When set, DCCP A (respectively DCCP B) may send Data, Ack and DataAck packets with short
+	// sequence numbers
+	AllowShortSeqNosA bool
+	AllowShortSeqNosB bool
+
 	State       int
 	Server      bool   // True if the endpoint is a server, false if it is a client
 	ServiceCode uint32 // The service code of this connection
@@ -70,8 +93,10 @@ func (s *socket) String() string {
 
 const (
 	SEQWIN_INIT             = 100      // Initial value for SWAF and SWBF, Section 7.5.2
-	SEQWIN_FIXED            = 700      // Large enough constant for SWAF/SWBF until the feature is implemented
-	SEQWIN_MAX              = 2^46 - 1 // Maximum acceptable SWAF and SWBF value
+	SEQWIN_FIXED            = 700      // Default minimum Sequence Window requested by each endpoint
+	SEQWIN_MIN              = 32       // Minimum acceptable SWAF and SWBF value, Section 7.5.2
+	SEQWIN_MAX              = 1<<46 - 1 // Maximum acceptable SWAF and SWBF value
+	SEQWIN_SHORT_MAX        = 1<<22    // Maximum SWAF and SWBF value, for which short sequence numbers are used
 	RoundtripDefault        = 2e8      // 0.2 sec, default Round-Trip Time when no measurement is available
 	RoundtripMin                 = 2e6      // ...
 	MSL                     = 2 * 60e9 // 2 mins in nanoseconds, Maximum Segment Lifetime, Section 3.4
@@ -122,9 +147,30 @@ func ServerString(isServer bool) string {
 	return "Client"
 }
 
+func (s *socket) GetCCIDA() byte  { return s.CCIDA }
 func (s *socket) SetCCIDA(v byte) { s.CCIDA = v }
+func (s *socket) GetCCIDB() byte  { return s.CCIDB }
 func (s *socket) SetCCIDB(v byte) { s.CCIDB = v }
 
+func (s *socket) GetSendAckVector() bool  { return s.SendAckVector }
+func (s *socket) SetSendAckVector(v bool) { s.SendAckVector = v }
+
+func (s *socket) GetECN() bool  { return s.ECN }
+func (s *socket) SetECN(v bool) { s.ECN = v }
+
+func (s *socket) GetSendNDPCount() bool  { return s.SendNDPCount }
+func (s *socket) SetSendNDPCount(v bool) { s.SendNDPCount = v }
+
+func (s *socket) GetMinCsCovA() byte  { return s.MinCsCovA }
+func (s *socket) SetMinCsCovA(v byte) { s.MinCsCovA = v }
+func (s *socket) GetMinCsCovB() byte  { return s.MinCsCovB }
+func (s *socket) SetMinCsCovB(v byte) { s.MinCsCovB = v }
+
+func (s *socket) GetAllowShortSeqNosA() bool  { return s.AllowShortSeqNosA }
+func (s *socket) SetAllowShortSeqNosA(v bool) { s.AllowShortSeqNosA = v }
+func (s *socket) GetAllowShortSeqNosB() bool  { return s.AllowShortSeqNosB }
+func (s *socket) SetAllowShortSeqNosB(v bool) { s.AllowShortSeqNosB = v }
+
 func (s *socket) GetMPS() int32 { return min32(s.CCMPS, s.PMTU) }
 
 func (s *socket) GetPMTU() int32  { return s.PMTU }
@@ -145,15 +191,21 @@ func (s *socket) SetState(v int) { s.State = v }
 func (s *socket) SetServiceCode(v uint32) { s.ServiceCode = v }
 func (s *socket) GetServiceCode() uint32  { return s.ServiceCode }
 
-// ChooseISS chooses a safe Initial Sequence Number
-func (s *socket) ChooseISS() int64 {
-	iss := rand.Int63n(0xffffff-1) + 1
-	s.ISS = iss
-	return iss
-}
 func (s *socket) GetISS() int64 { return s.ISS }
 
-func (s *socket) SetISR(v int64) { s.ISR = v }
+// SetISS sets the Initial Sequence number Sent. GSS is reset to precede it, so that the next
+// packet sent carries ISS.
+func (s *socket) SetISS(v int64) {
+	s.ISS = v
+	s.GSS = SeqAdd(v, -1)
+}
+
+// SetISR sets the Initial Sequence number Received. GSR is reset to precede it, so that the
+// packet carrying ISR becomes the greatest one received.
+func (s *socket) SetISR(v int64) {
+	s.ISR = v
+	s.GSR = SeqAdd(v, -1)
+}
 
 func (s *socket) GetOSR() int64  { return s.OSR }
 func (s *socket) SetOSR(v int64) { s.OSR = v }
@@ -163,28 +215,41 @@ func (s *socket) SetGSS(v int64) { s.GSS = v }
 
 func (s *socket) GetGSR() int64     { return s.GSR }
 func (s *socket) SetGSR(v int64)    { s.GSR = v }
-func (s *socket) UpdateGSR(v int64) { s.GSR = max64(s.GSR, v) }
+func (s *socket) UpdateGSR(v int64) { s.GSR = SeqMax(s.GSR, v) }
 
 func (s *socket) GetGAR() int64     { return s.GAR }
 func (s *socket) SetGAR(v int64)    { s.GAR = v }
-func (s *socket) UpdateGAR(v int64) { s.GAR = max64(s.GAR, v) }
+func (s *socket) UpdateGAR(v int64) { s.GAR = SeqMax(s.GAR, v) }
 
 // TODO: Address the last paragraph of Section 7.5.1 regarding SWL,AWL calculation
 
+func (s *socket) GetSWAF() int64  { return s.SWAF }
 func (s *socket) SetSWAF(v int64) { s.SWAF = v }
+func (s *socket) GetSWBF() int64  { return s.SWBF }
 func (s *socket) SetSWBF(v int64) { s.SWBF = v }
 
 // GetSWLH() computes SWL and SWH, see Section 7.5.1
 func (s *socket) GetSWLH() (SWL int64, SWH int64) {
-	return max64(s.GSR+1-s.SWBF/4, s.ISR), s.GSR + (3*s.SWBF)/4
+	SWL, SWH = SeqAdd(s.GSR, 1-s.SWBF/4), SeqAdd(s.GSR, (3*s.SWBF)/4)
+	// SWL is no earlier than ISR
+	if SeqInWindow(s.ISR, SWL, s.GSR) {
+		SWL = s.ISR
+	}
+	return SWL, SWH
 }
 
 // GetAWLH() computes AWL and AWH, see Section 7.5.1
 func (s *socket) GetAWLH() (AWL int64, AWH int64) {
-	return max64(s.GSS+1-s.SWAF, s.ISS), s.GSS
+	AWL, AWH = SeqAdd(s.GSS, 1-s.SWAF), s.GSS
+	// AWL is no earlier than ISS
+	if SeqInWindow(s.ISS, AWL, s.GSS) {
+		AWL = s.ISS
+	}
+	return AWL, AWH
 }
 
+// InAckWindow returns true if x lies within the Acknowledgement Number validity window
 func (s *socket) InAckWindow(x int64) bool {
 	awl, awh := s.GetAWLH()
-	return awl <= x && x <= awh
+	return SeqInWindow(x, awl, awh)
 }
@@ -0,0 +1,285 @@
+// Copyright 2011-2013 GoDCCP Authors. All rights reserved.
+// Use of this source code is governed by a 
+// license that can be found in the LICENSE file.
+
+package dccp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// AcceptStateless answers the handshake of the client on hc without keeping any connection
+// state, as described in Section 8.1.4. Requests are answered with Responses, which carry the
+// reconciled features and sequence numbers in an Init Cookie issued by jar. Once the client
+// echoes a valid cookie, AcceptStateless creates the server-side connection, which supports
+// the congestion controls ccids, and returns it.
+//
+// A tampered or expired cookie is answered with a Bad Init Cookie Reset, and ErrCookie is
+// returned. If no cookie is echoed within the cookie lifetime, ErrTimeout is returned. In both
+// cases, hc is closed. Since hc already exists when the Request arrives, a server that must not
+// allocate a flow for every Request should use Stack.SetCookieJar instead.
+func AcceptStateless(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, jar *CookieJar) (*Conn, error) {
+	return acceptStateless(env, amb, hc, ccids, jar, nil, nil)
+}
+
+// acceptStateless is like AcceptStateless, except that the congestion controls are chosen by
+// the Service Code of the client's Request, and the connection is configured by cfg, see
+// newConnServer
+func acceptStateless(env *Env, amb *Amb, hc HeaderConn, ccids []CCID, jar *CookieJar, services func(uint32) []CCID, cfg *Config) (*Conn, error) {
+	if len(ccids) == 0 {
+		panic("no congestion control")
+	}
+	lookup := func(serviceCode uint32) []CCID { return lookupService(services, ccids, serviceCode) }
+	deadline := env.Now() + jar.lifetime
+	for {
+		now := env.Now()
+		if now >= deadline {
+			amb.E(EventInfo, "Stateless handshake timeout")
+			hc.Close()
+			return nil, ErrTimeout
+		}
+		if err := hc.SetReadExpire(min64(deadline-now, EXPIRE_INTERVAL)); err != nil {
+			hc.Close()
+			return nil, err
+		}
+		h, err := hc.Read()
+		switch err {
+		case nil:
+		case ErrTimeout:
+			continue
+		case ErrEOF, ErrAbort, ErrBad, ErrIO:
+			hc.Close()
+			return nil, err
+		default:
+			// Drop packets that are unsupported. Intended for forward compatibility.
+			continue
+		}
+		amb.E(EventRead, "Stateless", h)
+		reply, ck, sccids, err := answerStateless(amb, jar, h, lookup, hc.LocalLabel(), hc.RemoteLabel(), env.Now())
+		if reply != nil {
+			writeStateless(amb, hc, reply)
+		}
+		if err != nil {
+			hc.Close()
+			return nil, err
+		}
+		if ck != nil {
+			return newConnServerCookie(env, amb, hc, sccids, ck, h, cfg), nil
+		}
+	}
+	panic("unreach")
+}
+
+// answerStateless processes the packet h, which was received at time now during a stateless
+// handshake on the flow with the given labels. Service Codes are served by the congestion
+// controls returned by lookup, or not accepted if it returns none. A Request is answered with
+// a Response carrying an Init Cookie, and packets that echo no cookie with a No Connection
+// Reset. The answer, if any, is returned as reply. Once h echoes a valid cookie, answerStateless
+// returns the connection state ck, which the cookie carries, and the congestion controls ccids of
+// the requested service. A tampered or expired cookie and a withdrawn service result in an error.
+func answerStateless(amb *Amb, jar *CookieJar, h *Header, lookup func(uint32) []CCID, local, remote Bytes, now int64) (reply *Header, ck *initCookie, ccids []CCID, err error) {
+	switch h.Type {
+	case Request:
+		return jar.respond(h, lookup(h.ServiceCode), local, remote, now), nil, nil, nil
+	case Reset:
+		return nil, nil, nil, nil
+	}
+	// Any other packet needs a valid Init Cookie. Cookies on Data packets are ignored.
+	opt := findInitCookie(h.Options)
+	if opt == nil || h.Type == Data {
+		return placeAbnormalSeqAck(newResetHeader(ResetNoConnection), h), nil, nil, nil
+	}
+	ck, err = jar.decode(opt, now, local, remote)
+	// The cookie must be echoed in acknowledgement of the Response that carried it
+	if err == nil && (!h.HasAckNo() || h.AckNo != ck.ISS) {
+		err = ErrCookie
+	}
+	if err != nil {
+		amb.E(EventWarn, "Bad Init Cookie", h)
+		return placeAbnormalSeqAck(newResetHeader(ResetBadInitCookie), h), nil, nil, err
+	}
+	// The service may have been withdrawn since the Response was sent
+	if ccids = lookup(ck.ServiceCode); len(ccids) == 0 {
+		amb.E(EventWarn, "Bad Service Code", h)
+		return placeAbnormalSeqAck(newResetHeader(ResetBadServiceCode), h), nil, nil, ErrAbort
+	}
+	return nil, ck, ccids, nil
+}
+
+// statelessListener answers connection handshakes on behalf of a mux, without keeping any
+// state for them, see Stack.SetCookieJar. The mux passes it the packets of flows that it has
+// not accepted, and accepts a flow only once its client echoes a valid Init Cookie. Until then,
+// neither a flow nor a connection exists.
+type statelessListener struct {
+	env    *Env
+	amb    *Amb
+	jar    *CookieJar
+	lookup func(uint32) []CCID // Congestion controls of a Service Code, or nil if it is not accepted
+}
+
+// chooseLabel returns the local label of a flow, which has not been accepted, from its remote
+// label. The label is derived from the cookie key, so that the flow of a Response is found
+// again when the client echoes its cookie.
+func (l *statelessListener) chooseLabel(remote *Label) *Label {
+	return ChooseLabelFrom(bytes.NewReader(l.jar.mac([]byte("label"), nil, remote)))
+}
+
+// listen answers the packet h, which arrived on the flow with the given labels. It returns the
+// reply, if any, and whether the flow is to be accepted with h as its first packet.
+func (l *statelessListener) listen(h *Header, local, remote Bytes) (reply *Header, accept bool) {
+	l.amb.E(EventRead, "Stateless", h)
+	reply, ck, _, _ := answerStateless(l.amb, l.jar, h, l.lookup, local, remote, l.env.Now())
+	if reply != nil {
+		l.amb.E(EventWrite, "Stateless", reply)
+	}
+	return reply, ck != nil
+}
+
+// accept creates the server-side connection over hc, which a mux accepted once listen approved
+// its first packet. If that packet no longer passes, for instance because the cookie has
+// expired meanwhile, it is answered as by listen, and hc is closed. The connection is
+// configured by cfg.
+func (l *statelessListener) accept(env *Env, amb *Amb, hc HeaderConn, cfg *Config) (*Conn, error) {
+	if err := hc.SetReadExpire(EXPIRE_INTERVAL); err != nil {
+		hc.Close()
+		return nil, err
+	}
+	h, err := hc.Read()
+	if err != nil {
+		hc.Close()
+		return nil, err
+	}
+	reply, ck, ccids, err := answerStateless(amb, l.jar, h, l.lookup, hc.LocalLabel(), hc.RemoteLabel(), l.env.Now())
+	if reply != nil {
+		writeStateless(amb, hc, reply)
+	}
+	if ck == nil {
+		hc.Close()
+		if err == nil {
+			err = ErrAbort
+		}
+		return nil, err
+	}
+	return newConnServerCookie(env, amb, hc, ccids, ck, h, cfg), nil
+}
+
+// respond reconciles the features requested by the Request h, as a newly created server-side
+// connection would, and returns a Response carrying the resulting state in an Init Cookie.
+// The requested service supports the congestion controls ccids, or none if its Service Code is
+// not accepted. If the Request cannot be accepted, respond returns a Reset instead.
+func (j *CookieJar) respond(h *Header, ccids []CCID, local, remote Bytes, now int64) *Header {
+	if len(ccids) == 0 {
+		return placeAbnormalSeqAck(newResetHeader(ResetBadServiceCode), h)
+	}
+	iss := j.chooseISS(h.SeqNo, local, remote)
+	var f featureSet
+	f.Init()
+	initFeaturePrefs(&f, ccids)
+	f.SetISS(iss)
+	f.SetISR(h.SeqNo)
+	if err := f.OnRead(h, true); err != nil {
+		if fe, ok := err.(FeatureReset); ok {
+			return placeAbnormalSeqAck(newResetHeader(fe.ResetCode()), h)
+		}
+	}
+	// The agreed upon CCIDs must be supported
+	for _, isLocal := range []bool{true, false} {
+		if !hasCCID(ccids, byte(f.Get(FeatureCCID, isLocal))) {
+			return placeAbnormalSeqAck(newResetHeader(ResetMandatoryError), h)
+		}
+	}
+
+	g := &Header{}
+	g.InitResponseHeader(h.ServiceCode)
+	g.SeqNo, g.AckNo = iss, h.SeqNo
+	// Attach the Confirm options for the Change options of the Request
+	f.Write(g, now, RoundtripDefault)
+	ck := &initCookie{
+		Time:        now,
+		ISS:         iss,
+		ISR:         h.SeqNo,
+		ServiceCode: h.ServiceCode,
+		Features:    f.Values(),
+	}
+	opt, err := j.encode(ck, local, remote)
+	if err != nil {
+		panic(fmt.Sprintf("encoding init cookie (%s)", err))
+	}
+	g.Options = append(g.Options, opt)
+	return g
+}
+
+func newResetHeader(resetCode byte) *Header {
+	h := &Header{}
+	h.InitResetHeader(resetCode)
+	return h
+}
+
+// lookupService returns the congestion controls that serve serviceCode, see newConnServer.
+// If services is nil, all Service Codes are served by ccids.
+func lookupService(services func(uint32) []CCID, ccids []CCID, serviceCode uint32) []CCID {
+	if services == nil {
+		return ccids
+	}
+	return services(serviceCode)
+}
+
+// hasCCID returns true if one of the congestion controls ccids implements the given CCID
+func hasCCID(ccids []CCID, id byte) bool {
+	for _, ccid := range ccids {
+		if ccid.GetID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func writeStateless(amb *Amb, hc HeaderConn, h *Header) {
+	amb.E(EventWrite, "Stateless", h)
+	if err := hc.Write(h); err != nil {
+		amb.E(EventWarn, fmt.Sprintf("Stateless write error (%s)", err), h)
+	}
+}
+
+// replayHeaderConn is a HeaderConn that returns a header, which has already been read from the
+// underlying HeaderConn, before any further headers
+type replayHeaderConn struct {
+	HeaderConn
+	Mutex
+	h *Header
+}
+
+func (r *replayHeaderConn) Read() (*Header, error) {
+	r.Lock()
+	h := r.h
+	r.h = nil
+	r.Unlock()
+	if h != nil {
+		return h, nil
+	}
+	return r.HeaderConn.Read()
+}
+
+// IsECNCapable implements ECNHeaderConn.IsECNCapable
+func (r *replayHeaderConn) IsECNCapable() bool {
+	ec, ok := r.HeaderConn.(ECNHeaderConn)
+	return ok && ec.IsECNCapable()
+}
+
+// LocalAddr implements AddrHeaderConn.LocalAddr
+func (r *replayHeaderConn) LocalAddr() net.Addr {
+	if ac, ok := r.HeaderConn.(AddrHeaderConn); ok {
+		return ac.LocalAddr()
+	}
+	return nil
+}
+
+// RemoteAddr implements AddrHeaderConn.RemoteAddr
+func (r *replayHeaderConn) RemoteAddr() net.Addr {
+	if ac, ok := r.HeaderConn.(AddrHeaderConn); ok {
+		return ac.RemoteAddr()
+	}
+	return nil
+}
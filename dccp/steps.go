@@ -25,7 +25,14 @@ func (c *Conn) step3_ProcessLISTEN(h *Header) error {
 		return nil
 	}
 	if h.Type == Request {
-		c.gotoRESPOND(h.ServiceCode, h.SeqNo)
+		if c.services != nil && !c.setServiceCCIDs(c.services(h.ServiceCode)) {
+			c.amb.E(EventWarn, "Bad Service Code", h)
+			c.setError(ErrAbort)
+			c.inject(c.generateAbnormalReset(ResetBadServiceCode, h))
+			c.gotoCLOSED()
+			return ErrDrop
+		}
+		c.gotoRESPOND(h.ServiceCode, c.env.ChooseISS(), h.SeqNo)
 		return nil
 	}
 	// For forward compatibility, if we receive a non-Request packet
@@ -45,6 +52,7 @@ func (c *Conn) step4_PrepSeqNoREQUEST(h *Header) error {
 	inAckWindow := c.socket.InAckWindow(h.AckNo)
 	if (h.Type == Response || h.Type == Reset) && inAckWindow {
 		c.socket.SetISR(h.SeqNo)
+		c.feature.SetISR(h.SeqNo)
 		c.PlaceSeqAck(h)
 		return nil
 	}
@@ -61,7 +69,7 @@ func (c *Conn) step5_PrepSeqNoForSync(h *Header) error {
 		return nil
 	}
 	swl, _ := c.socket.GetSWLH()
-	if c.socket.InAckWindow(h.AckNo) && h.SeqNo >= swl {
+	if c.socket.InAckWindow(h.AckNo) && SeqLessEq(swl, h.SeqNo) {
 		c.socket.UpdateGSR(h.SeqNo)
 		return nil
 	}
@@ -70,9 +78,15 @@ func (c *Conn) step5_PrepSeqNoForSync(h *Header) error {
 
 // Step 6, Section 8.5: Check sequence numbers
 func (c *Conn) step6_CheckSeqNo(h *Header) error {
-	// We don't support short sequence numbers
+	// Short sequence numbers are extended to 48 bits, if they are allowed
 	if !h.X {
-		return ErrDrop
+		if !c.socket.GetAllowShortSeqNosB() {
+			return ErrDrop
+		}
+		h.SeqNo = ExtendSeqNo(h.SeqNo, c.socket.GetGSR())
+		if h.HasAckNo() {
+			h.AckNo = ExtendSeqNo(h.AckNo, c.socket.GetGSS())
+		}
 	}
 
 	swl, swh := c.socket.GetSWLH()
@@ -81,12 +95,14 @@ func (c *Conn) step6_CheckSeqNo(h *Header) error {
 
 	gsr := c.socket.GetGSR()
 	gar := c.socket.GetGAR()
-	if h.Type == CloseReq || h.Type == Close || h.Type == Reset {
-		lswl, lawl = gsr+1, gar
+	// In REQUEST, the Sequence Number of a Reset has just been adopted in Step 4, after its
+	// Acknowledgement Number was validated
+	if (h.Type == CloseReq || h.Type == Close || h.Type == Reset) && c.socket.GetState() != REQUEST {
+		lswl, lawl = SeqAdd(gsr, 1), gar
 	}
 
 	hasAckNo := h.HasAckNo()
-	if (lswl <= h.SeqNo && h.SeqNo <= swh) && (!hasAckNo || (lawl <= h.AckNo && h.AckNo <= awh)) {
+	if SeqInWindow(h.SeqNo, lswl, swh) && (!hasAckNo || SeqInWindow(h.AckNo, lawl, awh)) {
 		c.socket.UpdateGSR(h.SeqNo)
 		if h.Type != Sync {
 			if hasAckNo {
@@ -117,8 +133,8 @@ func (c *Conn) step7_CheckUnexpectedTypes(h *Header) error {
 	if (isServer && h.Type == CloseReq) ||
 		(isServer && h.Type == Response) ||
 		(!isServer && h.Type == Request) ||
-		(state >= OPEN && h.Type == Request && h.SeqNo >= osr) ||
-		(state >= OPEN && h.Type == Response && h.SeqNo >= osr) ||
+		(state >= OPEN && h.Type == Request && SeqLessEq(osr, h.SeqNo)) ||
+		(state >= OPEN && h.Type == Response && SeqLessEq(osr, h.SeqNo)) ||
 		(state == RESPOND && h.Type == Data) {
 		g := c.generateSync()
 		g.AckNo = h.SeqNo
@@ -132,16 +148,57 @@ func (c *Conn) step7_CheckUnexpectedTypes(h *Header) error {
 // Section 7.4: A received packet becomes acknowledgeable when Step 8 is reached.
 func (c *Conn) step8_OptionsAndMarkAckbl(h *Header) error {
 
+	// Process feature negotiation options, Section 6.6.2
+	if err := c.feature.OnRead(h, c.socket.IsServer()); err != nil {
+		if fe, ok := err.(FeatureReset); ok {
+			c.reset(fe.ResetCode(), ErrAbort)
+			return ErrDrop
+		}
+		c.amb.E(EventError, fmt.Sprintf("Feature negotiation error (%s)", err), h)
+	}
+	if !c.syncWithFeatures() && c.feature.IsStable(FeatureCCID, true) && c.feature.IsStable(FeatureCCID, false) {
+		// The agreed upon CCIDs are not supported
+		c.reset(ResetMandatoryError, ErrAbort)
+		return ErrDrop
+	}
+	// Confirm options are sent promptly. Request, Response and Sync packets are answered anyway.
+	if c.feature.PendingConfirms() && h.Type != Request && h.Type != Response && h.Type != Sync && h.Type != Reset {
+		c.inject(c.generateAck())
+	}
+
+	// The Nonce Echo of an Ack Vector must match the nonces of the packets it reports received.
+	// Otherwise, the other side may be concealing congestion marks, Section 12.2.
+	if h.HasAckNo() {
+		if av := FindAckVectorOption(h.Options); av != nil && !c.ecnNonces.Verify(av, h.AckNo) {
+			c.amb.E(EventWarn, "ECN Nonce Echo mismatch", h)
+			c.reset(ResetAgressionPenalty, ErrAbort)
+			return ErrDrop
+		}
+	}
+
+	// Data Dropped options on packets without an Acknowledgement Number are ignored
+	var drops []DataDroppedRange
+	if h.HasAckNo() {
+		if dd := FindDataDroppedOption(h.Options); dd != nil {
+			drops = c.dropReports.OnRead(dd, h.AckNo)
+		}
+	}
+
 	defer c.syncWithCongestionControl()
 	now := c.env.Now()
+	if hasSlowReceiver(h.Options) {
+		c.slowReceiverTime = now
+		c.scc.OnSlowReceiver(now)
+	}
 	rsopts := filterCCIDReceiverToSenderOptions(h.Options)
 	if err := c.scc.OnRead(&FeedbackHeader{
-		Type:    h.Type, 
-		X:       h.X, 
-		SeqNo:   h.SeqNo, 
-		Options: rsopts, 
-		AckNo:   h.AckNo, 
-		Time:    now,
+		Type:        h.Type, 
+		X:           h.X, 
+		SeqNo:       h.SeqNo, 
+		Options:     rsopts, 
+		AckNo:       h.AckNo, 
+		Time:        now,
+		DataDropped: drops,
 	}); err != nil {
 		if re, ok := err.(CongestionReset); ok {
 			c.reset(re.ResetCode(), ErrAbort)
@@ -158,13 +215,15 @@ func (c *Conn) step8_OptionsAndMarkAckbl(h *Header) error {
 	}
 	sropts := filterCCIDSenderToReceiverOptions(h.Options)
 	if err := c.rcc.OnRead(&FeedforwardHeader{
-		Type:    h.Type, 
-		X:       h.X, 
-		SeqNo:   h.SeqNo, 
-		CCVal:   h.CCVal, 
-		Options: sropts, 
-		Time:    now, 
-		DataLen: len(h.Data),
+		Type:     h.Type, 
+		X:        h.X, 
+		SeqNo:    h.SeqNo, 
+		CCVal:    h.CCVal, 
+		Options:  sropts, 
+		Time:     now, 
+		DataLen:  len(h.Data),
+		ECN:      h.ECN,
+		NDPCount: getNDPCount(h.Options),
 	}); err != nil {
 		if re, ok := err.(CongestionReset); ok {
 			c.reset(re.ResetCode(), ErrAbort)
@@ -198,6 +257,12 @@ func (c *Conn) step10_ProcessREQUEST2(h *Header) error {
 	if c.socket.GetState() != REQUEST {
 		return nil
 	}
+	// Init Cookies of the Response are echoed until the handshake completes, Section 8.1.4
+	for _, opt := range h.Options {
+		if opt.Type == OptionInitCookie {
+			c.initCookies = append(c.initCookies, opt)
+		}
+	}
 	c.gotoPARTOPEN()
 
 	return nil
@@ -265,9 +330,8 @@ func (c *Conn) step14_ProcessClose(h *Header) error {
 	}
 	c.setError(ErrEOF) 
 	c.teardownUser()
-	c.gotoCLOSED()
 	c.inject(c.generateReset(ResetClosed))
-	c.teardownWriteLoop()
+	c.gotoCLOSED()
 	return ErrDrop
 }
 
@@ -276,6 +340,9 @@ func (c *Conn) step15_ProcessSync(h *Header) error {
 	if h.Type == Sync {
 		c.inject(c.generateSyncAck(h))
 	}
+	if h.Type == SyncAck && c.pmtu.OnSyncAck(h.AckNo, c.env.Now()) {
+		c.syncPMTU()
+	}
 	return nil
 }
 
@@ -285,8 +352,36 @@ func (c *Conn) step16_ProcessData(h *Header) error {
 	// application, except that the application MUST NOT receive data from
 	// more than one Request or Response
 
+	// Update the acknowledgement buffer with every packet that makes it this far
+	if c.socket.GetSendAckVector() {
+		state := byte(AckVectorReceived)
+		if h.ECN == ECNCE {
+			state = AckVectorECNMarked
+		}
+		c.ackVector.OnRead(h.SeqNo, state, ecnNonce(h.ECN))
+		if h.HasAckNo() {
+			c.ackVector.OnAck(h.AckNo)
+		}
+	}
+	if h.HasAckNo() {
+		c.dataDropped.OnAck(h.AckNo)
+	}
+
 	// REMARK: For now, we accept data only on Data* packets
 	if h.Type != Data && h.Type != DataAck {
+		// Sync and SyncAck packets carry padding, e.g. when they probe the Path MTU, Section 14.1
+		if len(h.Data) > 0 && h.Type != Sync && h.Type != SyncAck {
+			c.dropData(h, DropProtocolConstraints, "Data on non-Data packet")
+		}
+		return nil
+	}
+	c.dataTime = c.env.Now()
+	if !c.isCsCovAcceptable(h) {
+		c.dropData(h, DropProtocolConstraints, "Unacceptable checksum coverage")
+		return nil
+	}
+	if isDataCorrupt(h) {
+		c.dropData(h, DropCorrupt, "Corrupt data")
 		return nil
 	}
 
@@ -297,12 +392,22 @@ func (c *Conn) step16_ProcessData(h *Header) error {
 	c.readAppLk.Lock()
 	if c.readApp != nil {
 		if len(c.readApp) < cap(c.readApp) {
-			c.readApp <- h.Data
+			c.readApp <- &appData{Data: h.Data, CsCov: h.CsCov}
 		} else {
-			c.amb.E(EventDrop, "Slow app", h)
+			c.dropData(h, DropReceiveBuffer, "Slow app")
 		}
+	} else {
+		c.dropData(h, DropAppNotListening, "App not listening")
 	}
 	c.readAppLk.Unlock()
 
 	return nil
 }
+
+// dropData records that the application data of h did not reach the application for the
+// reason dropCode, so that it is reported in Data Dropped options, Section 11.7
+func (c *Conn) dropData(h *Header, dropCode byte, why string) {
+	c.AssertLocked()
+	c.amb.E(EventDrop, why, h)
+	c.dataDropped.OnDrop(h.SeqNo, dropCode)
+}
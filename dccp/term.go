@@ -8,8 +8,9 @@ package dccp
 func (c *Conn) abortWith(resetCode byte) {
 	c.Lock()
 	c.setError(ErrAbort)
-	c.gotoCLOSED()
+	// The Reset must be queued before gotoCLOSED tears down the write loop
 	c.inject(c.generateReset(resetCode))
+	c.gotoCLOSED()
 	c.Unlock()
 	c.teardownUser()
 	c.teardownWriteLoop()
@@ -29,8 +30,8 @@ func (c *Conn) setError(err error) {
 func (c *Conn) reset(resetCode byte, err error) {
 	c.AssertLocked()
 	c.setError(err)
-	c.gotoCLOSED()
 	c.inject(c.generateReset(resetCode))
+	c.gotoCLOSED()
 	c.teardownUser()
 	c.teardownWriteLoop()
 }
@@ -53,6 +54,7 @@ func (c *Conn) teardownUser() {
 		c.readApp = nil
 	}
 	c.readAppLk.Unlock()
+	c.writeDataOnce.Do(func() { close(c.writeDataDone) })
 	c.writeDataLk.Lock()
 	if c.writeData != nil {
 		close(c.writeData)
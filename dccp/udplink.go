@@ -24,6 +24,11 @@ func BindUDPLink(netw string, laddr *net.UDPAddr) (link *UDPLink, err error) {
 
 func (u *UDPLink) GetMTU() int { return 1500 }
 
+// LocalAddr implements AddrLink.LocalAddr
+func (u *UDPLink) LocalAddr() net.Addr {
+	return u.c.LocalAddr()
+}
+
 func (u *UDPLink) SetReadDeadline(t time.Time) error {
 	return u.c.SetReadDeadline(t)
 }
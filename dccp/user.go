@@ -6,6 +6,7 @@ package dccp
 
 import (
 	"fmt"
+	"net"
 )
 
 // This is an approximate upper bound on the size of options that are
@@ -14,34 +15,251 @@ const maxDataOptionSize = 24
 
 // GetMTU() returns the maximum size of an application-level data block that can be passed
 // to Write This is an informative number. Packets are sent anyway, but they may be
-// dropped by the link layer or a router.
+// dropped by the link layer or a router. The MTU follows the Path MTU, as it is discovered
+// by probing the path while the connection is open, Section 14.
 func (c *Conn) GetMTU() int {
 	c.Lock()
 	defer c.Unlock()
 	c.syncWithLink()
-	return int(c.socket.GetMPS()) - maxDataOptionSize - getFixedHeaderSize(DataAck, true)
+	return int(c.socket.GetMPS() - dataHeaderOverhead())
 }
 
-// Write blocks until the slice b is sent.
+// dataHeaderOverhead returns the number of bytes of a data packet, which are taken up by
+// headers and options, rather than application data
+func dataHeaderOverhead() int32 {
+	return int32(maxDataOptionSize + getFixedHeaderSize(DataAck, true))
+}
+
+// GetCCID returns the CCIDs currently in effect for the half-connection sending from this
+// endpoint and for the one sending to it, respectively.
+func (c *Conn) GetCCID() (sender, receiver byte) {
+	c.Lock()
+	defer c.Unlock()
+	return c.socket.GetCCIDA(), c.socket.GetCCIDB()
+}
+
+// SetSequenceWindow sets the minimum width of the Sequence Window that this endpoint
+// negotiates for the packets it sends, Section 7.5.2. The window is widened beyond w
+// when the sending rate requires it. Values are clamped to the valid range.
+func (c *Conn) SetSequenceWindow(w int64) {
+	c.Lock()
+	defer c.Unlock()
+	c.seqWindow = max64(SEQWIN_MIN, min64(w, SEQWIN_MAX))
+	c.syncSequenceWindow()
+}
+
+// GetSequenceWindow returns the Sequence Window values currently in effect for the packets
+// sent by this endpoint and for the ones sent to it, respectively.
+func (c *Conn) GetSequenceWindow() (local, remote int64) {
+	c.Lock()
+	defer c.Unlock()
+	return c.socket.GetSWAF(), c.socket.GetSWBF()
+}
+
+// SetAllowShortSeqNos sets whether this endpoint is willing to use short sequence numbers,
+// Section 7.6. Short sequence numbers save 4 bytes per Data, Ack and DataAck packet, but
+// increase the risk of data injection. They are used in a direction only if both endpoints
+// allow it.
+func (c *Conn) SetAllowShortSeqNos(allow bool) {
+	c.Lock()
+	defer c.Unlock()
+	prefs := []byte{0}
+	if allow {
+		prefs = []byte{1, 0}
+	}
+	for _, local := range []bool{true, false} {
+		c.feature.SetPrefs(FeatureAllowShortSeqNos, local, prefs)
+		c.feature.Change(FeatureAllowShortSeqNos, local, false)
+	}
+}
+
+// GetAllowShortSeqNos returns whether short sequence numbers are currently allowed on the
+// packets sent by this endpoint and on the ones sent to it, respectively.
+func (c *Conn) GetAllowShortSeqNos() (local, remote bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.socket.GetAllowShortSeqNosA(), c.socket.GetAllowShortSeqNosB()
+}
+
+// GetDataDropped returns the number of packets sent by this endpoint, whose data the other side
+// has reported as not delivered to its application, indexed by Drop Code, Section 11.7. A
+// growing count of DropReceiveBuffer indicates that the receiving application cannot keep up.
+func (c *Conn) GetDataDropped() [8]int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.dropReports.Counts()
+}
+
+// SetSlowReceiver declares whether the application is having trouble keeping up with the data
+// sent by the other side, Section 11.6. While slow is true, the other side is asked not to
+// increase its sending rate. Slow Receiver is also signalled, regardless of slow, whenever the
+// application falls behind in reading received data.
+func (c *Conn) SetSlowReceiver(slow bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.slowReceiver = slow
+}
+
+// IsReceiverSlow returns true if the other side has declared itself a slow receiver within
+// the last round-trip time, Section 11.6. A sending application may react by reducing its
+// application-level sending rate.
+func (c *Conn) IsReceiverSlow() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.slowReceiverTime > 0 && c.env.Now()-c.slowReceiverTime < c.socket.GetRTT()
+}
+
+// SetCsCov sets the checksum coverage of the data sent by subsequent calls to Write, Section
+// 9.2. A csCov of zero covers all application data. Otherwise, the checksum covers only the
+// first (csCov-1)*4 bytes of application data, so that bit errors in the rest do not cause
+// the packet to be dropped. Partial coverage is used only if the other side accepts it, see
+// SetMinCsCov. SetCsCov returns ErrCsCov if csCov exceeds 15.
+func (c *Conn) SetCsCov(csCov byte) error {
+	if csCov > 15 {
+		return ErrCsCov
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.csCov = csCov
+	return nil
+}
+
+// SetMinCsCov sets the minimum checksum coverage of packets that this endpoint accepts,
+// Section 9.2.1. When min is zero, only packets with full checksum coverage are accepted.
+// Otherwise, packets are also accepted if their checksum coverage, as given to SetCsCov, is
+// at least min. The reader learns about partially covered data from ReadPartial. SetMinCsCov
+// returns ErrCsCov if min exceeds 15.
+func (c *Conn) SetMinCsCov(min byte) error {
+	if min > 15 {
+		return ErrCsCov
+	}
+	c.Lock()
+	defer c.Unlock()
+	prefs := []byte{0}
+	if min > 0 {
+		prefs = []byte{min, 0}
+	}
+	c.feature.SetPrefs(FeatureMinCsCov, true, prefs)
+	c.feature.Change(FeatureMinCsCov, true, false)
+	return nil
+}
+
+// GetMinCsCov returns the minimum checksum coverage currently in effect for the packets
+// received by this endpoint and for the ones sent by it, respectively.
+func (c *Conn) GetMinCsCov() (local, remote byte) {
+	c.Lock()
+	defer c.Unlock()
+	return c.socket.GetMinCsCovA(), c.socket.GetMinCsCovB()
+}
+
+// Write blocks until the slice b is queued for sending. It returns before b is sent, once the
+// send queue, see Config.WriteBuffer, has room for it. If the write expiration time passes
+// first, see SetWriteExpire, Write returns ErrTimeout and b is not sent. Data queued with
+// WriteExpire can still be discarded as stale before it is sent.
 func (c *Conn) Write(data []byte) error {
+	c.Lock()
+	csCov := c.csCov
+	c.Unlock()
+	return c.WriteCsCov(data, csCov)
+}
 
-	//?
+// WriteCsCov is like Write, except that data is sent with checksum coverage csCov in place of
+// the one set by SetCsCov.
+func (c *Conn) WriteCsCov(data []byte, csCov byte) error {
+	if csCov > 15 {
+		return ErrCsCov
+	}
+	return c.writeApp(&appData{Data: data, CsCov: csCov})
+}
+
+// WriteExpire is like Write, except that data is discarded, rather than sent, if it has not
+// been sent within expire nanoseconds from now. It suits real-time applications, which prefer
+// losing data to sending it late. Write returns once data is queued for sending, so a nil
+// error does not mean that data was sent. See GetStaleCount.
+func (c *Conn) WriteExpire(data []byte, expire int64) error {
+	if expire < 0 {
+		return ErrInvalid
+	}
+	c.Lock()
+	csCov := c.csCov
+	c.Unlock()
+	return c.writeApp(&appData{Data: data, CsCov: csCov, Deadline: c.env.Now() + expire})
+}
+
+// GetStaleCount returns the number of blocks of data, written with WriteExpire, which were
+// discarded because they could not be sent in time
+func (c *Conn) GetStaleCount() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.staleCount
+}
+
+// writeApp blocks until d is queued for sending, or the write expiration time passes. Data
+// with a deadline, which passes while waiting, is discarded as stale, see GetStaleCount.
+func (c *Conn) writeApp(d *appData) error {
+	var stale <-chan struct{}
+	if d.Deadline > 0 {
+		var stopStale func()
+		stale, stopStale = c.env.Timer(d.Deadline - c.env.Now())
+		defer stopStale()
+	}
+	queued, err := c.queueApp(d, stale)
+	if err == nil && !queued {
+		// Stale data is counted after writeDataLk is released, since teardownUser acquires
+		// writeDataLk while holding the lock on c
+		c.dropStale(nil)
+	}
+	return err
+}
 
+// queueApp blocks until d is queued for sending, the write expiration time passes, or the
+// channel stale is closed. It returns false, and no error, in the latter case.
+func (c *Conn) queueApp(d *appData, stale <-chan struct{}) (queued bool, err error) {
 	c.writeDataLk.Lock()
 	defer c.writeDataLk.Unlock()
 	if c.writeData == nil {
-		return ErrBad
+		return false, ErrBad
+	}
+	for {
+		expired, changed, stop := c.writeExpire.Wait()
+		if isExpired(expired) {
+			stop()
+			return false, ErrTimeout
+		}
+		select {
+		case c.writeData <- d:
+			stop()
+			return true, nil
+		case <-c.writeDataDone:
+			stop()
+			return false, ErrBad
+		case <-expired:
+			stop()
+			return false, ErrTimeout
+		case <-stale:
+			stop()
+			return false, nil
+		case <-changed:
+			stop()
+		}
 	}
-	c.writeData <- data
-	return nil
 }
 
 // Read blocks until the next packet of application data is received. Successfuly read data
 // is returned in a slice. The error returned by Read behaves according to io.Reader. If the
 // connection was never established or was aborted, Read returns ErrIO. If the connection
 // was closed normally, Read returns io.EOF. In the event of a non-nil error, successive
-// calls to Read return the same error.
+// calls to Read return the same error. The exception is ErrTimeout, returned when the read
+// expiration time passes, see SetReadExpire, which leaves the connection intact.
 func (c *Conn) Read() (b []byte, err error) {
+	b, _, err = c.ReadPartial()
+	return b, err
+}
+
+// ReadPartial is like Read, and it additionally reports whether the checksum of the packet
+// covered only part of the data, Section 9.2. In that case, the data beyond the coverage may
+// have been damaged in transit.
+func (c *Conn) ReadPartial() (b []byte, partial bool, err error) {
 	c.readAppLk.Lock()
 	readApp := c.readApp
 	c.readAppLk.Unlock()
@@ -49,17 +267,32 @@ func (c *Conn) Read() (b []byte, err error) {
 		if c.Error() == nil {
 			panic("torn connection missing error")
 		}
-		return nil, c.Error()
+		return nil, false, c.Error()
 	}
-	b, ok := <-readApp
-	if !ok {
-		if c.Error() == nil {
-			panic("torn connection missing error")
+	for {
+		expired, changed, stop := c.readExpire.Wait()
+		if isExpired(expired) {
+			stop()
+			return nil, false, ErrTimeout
+		}
+		select {
+		case d, ok := <-readApp:
+			stop()
+			if !ok {
+				if c.Error() == nil {
+					panic("torn connection missing error")
+				}
+				// The connection has been closed
+				return nil, false, c.Error()
+			}
+			return d.Data, isCsCovPartial(d.CsCov, len(d.Data)), nil
+		case <-expired:
+			stop()
+			return nil, false, ErrTimeout
+		case <-changed:
+			stop()
 		}
-		// The connection has been closed
-		return nil, c.Error()
 	}
-	return b, nil
 }
 
 func (c *Conn) Error() error {
@@ -83,6 +316,7 @@ func (c *Conn) Close() error {
 		return nil
 	case RESPOND:
 		c.reset(ResetClosed, ErrEOF)
+		return nil
 	case PARTOPEN, OPEN:
 		c.inject(c.generateClose())
 		c.gotoCLOSING()
@@ -96,6 +330,22 @@ func (c *Conn) Close() error {
 	panic("unknown state")
 }
 
+// CloseReq closes the connection like Close, except that a server asks the client to initiate
+// the close with a CloseReq packet, Section 8.3. This way the client, rather than the server,
+// holds TIMEWAIT state after the connection ends. On the client side, CloseReq is equivalent
+// to Close.
+func (c *Conn) CloseReq() error {
+	c.Lock()
+	state := c.socket.GetState()
+	if !c.socket.IsServer() || (state != PARTOPEN && state != OPEN) {
+		c.Unlock()
+		return c.Close()
+	}
+	defer c.Unlock()
+	c.gotoCLOSEREQ()
+	return nil
+}
+
 func (c *Conn) Abort() {
 	c.abortWith(ResetAborted)
 }
@@ -106,7 +356,69 @@ func (c *Conn) LocalLabel() Bytes { return c.hc.LocalLabel() }
 // RemoteLabel implements SegmentConn.RemoteLabel
 func (c *Conn) RemoteLabel() Bytes { return c.hc.RemoteLabel() }
 
-// SetReadExpire implements SegmentConn.SetReadExpire
+// LocalAddr returns the address of the local endpoint of the connection, consisting of the
+// link-layer address, if the HeaderConn knows it, and the local label
+func (c *Conn) LocalAddr() net.Addr {
+	var link net.Addr
+	if ac, ok := c.hc.(AddrHeaderConn); ok {
+		link = ac.LocalAddr()
+	}
+	return newFlowAddr(link, c.hc.LocalLabel())
+}
+
+// RemoteAddr returns the address of the remote endpoint of the connection, see LocalAddr
+func (c *Conn) RemoteAddr() net.Addr {
+	var link net.Addr
+	if ac, ok := c.hc.(AddrHeaderConn); ok {
+		link = ac.RemoteAddr()
+	}
+	return newFlowAddr(link, c.hc.RemoteLabel())
+}
+
+// GetServiceCode returns the Service Code of the connection, Section 8.1.2. On the server side,
+// it is known once the client's Request has been received.
+func (c *Conn) GetServiceCode() uint32 {
+	c.Lock()
+	defer c.Unlock()
+	return c.socket.GetServiceCode()
+}
+
+// SetReadExpire implements SegmentConn.SetReadExpire. Calls to Read, blocked or made more
+// than nsec nanoseconds from now, return ErrTimeout. A zero nsec removes the expiration time.
 func (c *Conn) SetReadExpire(nsec int64) error {
-	panic("not implemented")
+	if nsec < 0 {
+		return ErrInvalid
+	}
+	c.readExpire.Set(c.expireTime(nsec))
+	return nil
+}
+
+// SetWriteExpire is like SetReadExpire, except that it applies to calls to Write, which block
+// while the congestion control is holding back data.
+func (c *Conn) SetWriteExpire(nsec int64) error {
+	if nsec < 0 {
+		return ErrInvalid
+	}
+	c.writeExpire.Set(c.expireTime(nsec))
+	return nil
+}
+
+// expireTime returns the absolute time, nsec nanoseconds from now, or zero if nsec is zero
+func (c *Conn) expireTime(nsec int64) int64 {
+	if nsec == 0 {
+		return 0
+	}
+	return c.env.Now() + nsec
+}
+
+// SetReadDeadline sets the absolute time t, in the time of the Env, see Env.Now, after which
+// calls to Read return ErrTimeout. A zero t removes the deadline. A deadline in the past
+// cancels blocked calls to Read.
+func (c *Conn) SetReadDeadline(t int64) {
+	c.readExpire.Set(t)
+}
+
+// SetWriteDeadline is like SetReadDeadline, except that it applies to calls to Write.
+func (c *Conn) SetWriteDeadline(t int64) {
+	c.writeExpire.Set(t)
 }
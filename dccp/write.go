@@ -86,6 +86,15 @@ func (gh *Header) getHeaderFootprint(allowShortSeqNoFeature bool) (int, error) {
 	return r, nil
 }
 
+// Len returns the size of the wire-format packet, including app data
+func (gh *Header) Len() (int, error) {
+	n, err := gh.getHeaderFootprint(true)
+	if err != nil {
+		return 0, err
+	}
+	return n + len(gh.Data), nil
+}
+
 // Write() writes the DCCP header to two return buffers.
 // The first one is the header part, and the second one is the data
 // part which simply equals the slice Header.Data
@@ -140,7 +149,7 @@ func (gh *Header) Write(sourceIP, destIP []byte,
 	// Write SeqNo
 	switch gh.X {
 	case false:
-		EncodeUint24(uint32(gh.SeqNo), buf[k:k+3])
+		EncodeUint24(uint32(gh.SeqNo&0xffffff), buf[k:k+3])
 		k += 3
 	case true:
 		buf[k] = 0
@@ -148,7 +157,8 @@ func (gh *Header) Write(sourceIP, destIP []byte,
 		if gh.SeqNo < 0 {
 			panic("seqno < 0")
 		}
-		EncodeUint48(uint64(gh.SeqNo), buf[k:k+6])
+		// Sequence numbers are counted modulo 2^48, Section 3.1
+		EncodeUint48(uint64(gh.SeqNo)&(1<<48-1), buf[k:k+6])
 		k += 6
 	}
 
@@ -159,7 +169,7 @@ func (gh *Header) Write(sourceIP, destIP []byte,
 	case 4:
 		buf[k] = 0
 		k += 1 // Skip over Reserved
-		EncodeUint24(uint32(gh.AckNo), buf[k:k+3])
+		EncodeUint24(uint32(gh.AckNo&0xffffff), buf[k:k+3])
 		k += 3
 	case 8:
 		buf[k], buf[k+1] = 0, 0
@@ -167,7 +177,7 @@ func (gh *Header) Write(sourceIP, destIP []byte,
 		if gh.AckNo < 0 {
 			panic("ackno < 0")
 		}
-		EncodeUint48(uint64(gh.AckNo), buf[k:k+6])
+		EncodeUint48(uint64(gh.AckNo)&(1<<48-1), buf[k:k+6])
 		k += 6
 	default:
 		panic("unreach")